@@ -1,10 +1,10 @@
 package auth
 
 import (
+	"crypto/rand"
 	"encoding/hex"
-	"math/rand"
+	"math/big"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -19,26 +19,40 @@ func NewAuthService(
 	logger contracts.ILogger,
 	userService contracts.IUserService,
 	notification contracts.INotificationService,
+	otpStore contracts.IOTPStore,
 ) contracts.IAuth {
 	return &auth{
 		config:       config,
 		logger:       logger,
 		userService:  userService,
 		notification: notification,
+		otpStore:     otpStore,
 	}
 }
 
-// FindOTPOfAUser implements IAccounting.FindOTPOfAUser
+// FindOTPOfAUser implements IAuth.FindOTPOfAUser
 func (s *auth) FindOTPOfAUser(phone string) (string, error) {
-	otp, ok := s.otpMap.Load(phone)
-	if !ok || otp.(otpType).expireAt.Before(time.Now()) {
+	if s.config.IsProduction() {
+		return "", contracts.ErrNotInProduction
+	}
+
+	code, expireAt, err := s.otpStore.Find(utils.NormalizePhoneNumber(phone))
+	if err != nil {
+		return "", err
+	}
+	if expireAt.Before(time.Now()) {
 		return "", contracts.ErrOTPNotFound
 	}
-	return otp.(otpType).code, nil
+	return code, nil
 }
 
 // SendOTP sends otp to user phone number
 func (s *auth) SendOTP(phone string, app string) error {
+	return s.SendLocalizedOTP(phone, app, "")
+}
+
+// SendLocalizedOTP sends otp to user phone number in the message of the locale
+func (s *auth) SendLocalizedOTP(phone, app, locale string) error {
 	phone = utils.NormalizePhoneNumber(phone)
 
 	// Todo: validate phone number
@@ -47,15 +61,24 @@ func (s *auth) SendOTP(phone string, app string) error {
 		return contracts.ErrUserDeactivated
 	}
 
-	otp := s.generateOTP(phone)
+	otp, err := s.generateOTP(phone)
+	if err != nil {
+		return err
+	}
 	// todo: send it via an event
-	s.notification.SendSMS(user.Phone(), otp)
-	return nil
+	return s.notification.SendOTP(phone, otp, app, locale)
 }
 
 // NoSendOTP generates OTP for a phone number but doesn't send it.
 func (s *auth) NoSendOTP(phone string) string {
-	return s.generateOTP(phone)
+	otp, err := s.generateOTP(phone)
+	if err != nil {
+		s.logger.WithFields(contracts.LogFields{
+			"phone": phone,
+			"error": err.Error(),
+		}).Error("cannot store otp")
+	}
+	return otp
 }
 
 // LoginByOTP either register or login user by authenticating the otp sent in previous step
@@ -63,51 +86,51 @@ func (s *auth) LoginByOTP(phone, code string, guestID int) (token *models.Token,
 	phone = utils.NormalizePhoneNumber(phone)
 
 	// todo: validate phone number
-	otp, ok := s.otpMap.Load(phone)
-	if !ok || otp.(otpType).code != code || otp.(otpType).expireAt.Before(time.Now()) {
+	otp, expireAt, err := s.otpStore.Find(phone)
+	if err != nil || otp != code || expireAt.Before(time.Now()) {
 		return nil, contracts.ErrOTPIsIncorrect
 	}
 
 	user, err := s.userService.FindByPhone(phone)
 	if err != nil {
 		switch err {
-		//case contracts.ErrUserNotFound:
-		//	// register user if not found in database
-		//	user = &models.User{
-		//		Phone: phone,
-		//	}
-		//	err = s.userService.Create(user)
-		//	if err != nil {
-		//		return nil, err
-		//	}
+		case contracts.ErrUserNotFound:
+			// register user if not found in database
+			user, err = s.userService.Create(phone, "", "")
+			if err != nil {
+				return nil, err
+			}
 
 		default:
 			return nil, err
 		}
 	}
 
-	// create s new refresh token for user if not exists or expired
-	if user.RefreshTokenExpiry().Before(time.Now()) {
-		bs := make([]byte, 32)
-		rand.Read(bs)
-		user.SetRefreshToken(hex.EncodeToString(bs))
-		user.SetRefreshTokenExpiry(time.Now().Add(6 * 30 * 24 * time.Hour))
+	if user.DeletedAt() != nil {
+		return nil, contracts.ErrUserDeactivated
+	}
+
+	_ = s.otpStore.Delete(phone)
 
-		// Todo: what to do?
-		//if err = s.storage.Update(user); err != nil {
-		//	return nil, err
-		//}
+	return s.issueToken(user)
+}
+
+// IssueToken creates a pair of access token and refresh token for a user
+func (s *auth) IssueToken(userID int) (*models.Token, error) {
+	if s.config.IsProduction() {
+		return nil, contracts.ErrNotInProduction
 	}
 
-	accessToken, err := s.createJwtToken(user)
+	user, err := s.userService.FindByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.Token{
-		AccessToken:  accessToken,
-		RefreshToken: user.RefreshToken(),
-	}, nil
+	if user.DeletedAt() != nil {
+		return nil, contracts.ErrUserDeactivated
+	}
+
+	return s.issueToken(user)
 }
 
 // RefreshToken return a new access token based on user refresh token
@@ -138,7 +161,7 @@ type otpType struct {
 
 type auth struct {
 	config       contracts.IConfigService
-	otpMap       sync.Map
+	otpStore     contracts.IOTPStore
 	logger       contracts.ILogger
 	userService  contracts.IUserService
 	notification contracts.INotificationService
@@ -147,43 +170,77 @@ type auth struct {
 /********** Helper functions ***********/
 
 // A helper function to generate a random number
-// and store in a map of generated otps
-func (s *auth) generateOTP(phone string) string {
+// and store in the otp store
+func (s *auth) generateOTP(phone string) (string, error) {
 
-	// search for existing otp in map
-	if otp, ok := s.otpMap.Load(phone); ok {
+	// search for existing otp in the store
+	if otp, expireAt, err := s.otpStore.Find(phone); err == nil {
 		// if the existing code expired delete it otherwise return it
-		if otp.(otpType).expireAt.Before(time.Now()) {
-			s.otpMap.Delete(phone)
+		if expireAt.Before(time.Now()) {
+			_ = s.otpStore.Delete(phone)
 		} else {
-			return otp.(otpType).code
+			return otp, nil
 		}
 	}
 
-	// in case of not found otp in map
-	rand.Seed(time.Now().UnixNano())
-	n := rand.Intn(99999-10000) + 10000
-	otp := strconv.Itoa(n)
+	// in case of not found otp in the store
+	n, err := rand.Int(rand.Reader, big.NewInt(90000))
+	if err != nil {
+		return "", err
+	}
+	otp := strconv.FormatInt(n.Int64()+10000, 10)
 
 	if !s.config.IsProduction() && phone == "09120000000" {
 		otp = "00000"
 	}
 
-	s.otpMap.Store(phone, otpType{code: otp, expireAt: time.Now().Add(10 * time.Minute)})
+	return otp, s.otpStore.Save(phone, otp, time.Now().Add(10*time.Minute))
+}
 
-	return otp
+// A helper function to create a new access token and, if the current one is
+// missing or expired, a new refresh token for the user.
+func (s *auth) issueToken(user contracts.IUser) (*models.Token, error) {
+
+	// create s new refresh token for user if not exists or expired
+	if expiry := user.RefreshTokenExpiry(); expiry == nil || expiry.Before(time.Now()) {
+		bs := make([]byte, 32)
+		if _, err := rand.Read(bs); err != nil {
+			return nil, err
+		}
+		user.SetRefreshToken(hex.EncodeToString(bs))
+		user.SetRefreshTokenExpiry(time.Now().Add(6 * 30 * 24 * time.Hour))
+
+		if err := s.userService.Update(user); err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken, err := s.createJwtToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Token{
+		AccessToken:  accessToken,
+		RefreshToken: user.RefreshToken(),
+	}, nil
 }
 
 func (s *auth) createJwtToken(user contracts.IUser) (string, error) {
 
+	expiry := s.config.Int("auth.jwt.expiry")
+	if expiry <= 0 {
+		expiry = 30
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &models.UserClaims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Duration(s.config.Int("auth.jtw.expiry")) * time.Minute).Unix(),
+			ExpiresAt: time.Now().Add(time.Duration(expiry) * time.Minute).Unix(),
 		},
 
 		ID:    user.ID(),
 		Name:  user.FullName(),
-		Roles: []string{"user"},
+		Roles: user.Roles(),
 		Phone: user.Phone(),
 	})
 
@@ -199,7 +256,7 @@ func (s *auth) parseJwtToken(accessToken string) (*models.UserClaims, error) {
 		if t.Method != jwt.SigningMethodHS256 {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return []byte(s.config.String(s.config.String("auth.jwt.secret"))), nil
+		return []byte(s.config.String("auth.jwt.secret")), nil
 	})
 	if err != nil {
 		return nil, err
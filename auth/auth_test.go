@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func TestIssueTokenInProduction(t *testing.T) {
+	cfg := config.NewConfigService()
+	cfg.SetString("environment", "production")
+	s := NewAuthService(cfg, nil, nil, nil, NewMemoryOTPStore())
+
+	if _, err := s.IssueToken(1); err != contracts.ErrNotInProduction {
+		t.Errorf("IssueToken = %v, want %v", err, contracts.ErrNotInProduction)
+	}
+}
+
+func TestGenerateOTP(t *testing.T) {
+	cases := []struct {
+		name        string
+		environment string
+		phone       string
+		want        string
+	}{
+		{name: "Random", environment: "production", phone: "09121111111"},
+		{name: "TestPhone", environment: "development", phone: "09120000000", want: "00000"},
+		{name: "TestPhoneInProduction", environment: "production", phone: "09120000000"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("environment", tc.environment)
+			s := &auth{config: cfg, otpStore: NewMemoryOTPStore()}
+
+			otp, err := s.generateOTP(tc.phone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want != "" && otp != tc.want {
+				t.Errorf("otp = %s, want %s", otp, tc.want)
+			}
+			if n, err := strconv.Atoi(otp); tc.want == "" && (err != nil || n < 10000 || n > 99999) {
+				t.Errorf("otp = %s, want 5 digits", otp)
+			}
+
+			// The pending OTP is sent again rather than a new one.
+			if again, err := s.generateOTP(tc.phone); err != nil || again != otp {
+				t.Errorf("second otp = %s, %v, want %s", again, err, otp)
+			}
+		})
+	}
+}
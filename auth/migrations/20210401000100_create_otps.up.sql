@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS otps(
+    phone VARCHAR(20) PRIMARY KEY,
+    code VARCHAR(10) NOT NULL,
+    expire_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations returns the database migrations of the auth service.
+func Migrations() fs.FS {
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
+}
+
+// NewOTPStore creates the IOTPStore selected by the `auth.otp.store`
+// configuration parameter; either "memory" (default) or "sql".
+//
+// The SQL store is shared between replicas and processes, e.g. the CLI.
+func NewOTPStore(config contracts.IConfigService, db *sql.DB) contracts.IOTPStore {
+	if config.String("auth.otp.store") == "sql" {
+		return NewSQLOTPStore(db)
+	}
+	return NewMemoryOTPStore()
+}
+
+/********** memory store **********/
+
+type memoryOTPStore struct {
+	otpMap sync.Map
+}
+
+// NewMemoryOTPStore creates an IOTPStore which keeps OTPs in memory.
+func NewMemoryOTPStore() contracts.IOTPStore {
+	return &memoryOTPStore{}
+}
+
+// Save implements IOTPStore.Save
+func (s *memoryOTPStore) Save(phone, code string, expireAt time.Time) error {
+	s.otpMap.Store(phone, otpType{code: code, expireAt: expireAt})
+	return nil
+}
+
+// Find implements IOTPStore.Find
+func (s *memoryOTPStore) Find(phone string) (string, time.Time, error) {
+	otp, ok := s.otpMap.Load(phone)
+	if !ok {
+		return "", time.Time{}, contracts.ErrOTPNotFound
+	}
+	return otp.(otpType).code, otp.(otpType).expireAt, nil
+}
+
+// Delete implements IOTPStore.Delete
+func (s *memoryOTPStore) Delete(phone string) error {
+	s.otpMap.Delete(phone)
+	return nil
+}
+
+// HealthCheck implements IHealthChecker.HealthCheck
+func (s *memoryOTPStore) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+/********** sql store **********/
+
+type sqlOTPStore struct {
+	db *sql.DB
+}
+
+// NewSQLOTPStore creates an IOTPStore which keeps OTPs in the `otps` table.
+func NewSQLOTPStore(db *sql.DB) contracts.IOTPStore {
+	return &sqlOTPStore{db: db}
+}
+
+// Save implements IOTPStore.Save
+func (s *sqlOTPStore) Save(phone, code string, expireAt time.Time) error {
+	_, err := s.db.Exec(
+		`INSERT INTO otps(phone, code, expire_at) VALUES($1, $2, $3)
+			ON CONFLICT (phone) DO UPDATE SET code = $2, expire_at = $3`,
+		phone, code, expireAt,
+	)
+	return err
+}
+
+// Find implements IOTPStore.Find
+func (s *sqlOTPStore) Find(phone string) (string, time.Time, error) {
+	var code string
+	var expireAt time.Time
+	err := s.db.QueryRow(
+		`SELECT code, expire_at FROM otps WHERE phone = $1`, phone,
+	).Scan(&code, &expireAt)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, contracts.ErrOTPNotFound
+	}
+	return code, expireAt, err
+}
+
+// Delete implements IOTPStore.Delete
+func (s *sqlOTPStore) Delete(phone string) error {
+	_, err := s.db.Exec(`DELETE FROM otps WHERE phone = $1`, phone)
+	return err
+}
+
+// HealthCheck implements IHealthChecker.HealthCheck
+func (s *sqlOTPStore) HealthCheck(ctx context.Context) error {
+	return s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM otps WHERE phone = ''`).Scan(new(int))
+}
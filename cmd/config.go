@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func dumpConfig(lev contracts.ILeviathan, args []string) error {
+	if _, _, err := subcommand(args, "dump"); err != nil {
+		return err
+	}
+
+	dump := config.Redact(lev.Config().Dump())
+
+	keys := make([]string, 0, len(dump))
+	for key := range dump {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%s = %s\n", key, dump[key])
+	}
+	return nil
+}
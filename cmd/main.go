@@ -1,10 +1,99 @@
 package main
 
-import "github.com/mostafasolati/leviathan"
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/mostafasolati/leviathan"
+	"github.com/mostafasolati/leviathan/contracts"
+
+	// register the postgres database driver
+	_ "github.com/lib/pq"
+)
+
+// command is a subcommand of the command-line tool.
+type command struct {
+	usage string
+	run   func(lev contracts.ILeviathan, args []string) error
+}
+
+var commands = map[string]command{
+	"serve": {
+		usage: "serve                                start the http server",
+		run:   serve,
+	},
+	"migrate": {
+		usage: "migrate up|down|status|create        manage database migrations",
+		run:   migrate,
+	},
+	"user": {
+		usage: "user create|deactivate|grant-role    manage users",
+		run:   manageUser,
+	},
+	"token": {
+		usage: "token issue                          issue a token for a user",
+		run:   token,
+	},
+	"otp": {
+		usage: "otp peek                             show the pending otp of a phone",
+		run:   otp,
+	},
+	"config": {
+		usage: "config dump                          print configuration, hiding secrets",
+		run:   dumpConfig,
+	},
+}
 
 func main() {
-	lev := leviathan.Init()
+	// Serve by default, as the tool always did.
+	name, args := "serve", []string{}
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	lev, err := leviathan.Init()
+	if err != nil {
+		fail(err)
+	}
+
+	if err := cmd.run(lev, args); err != nil {
+		fail(err)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [arguments]\n\ncommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
 
-	lev.Logger().Info("HELLO WORLD!")
-	lev.Server().Run(":8080")
+// subcommand splits args into a subcommand name and its arguments.
+func subcommand(args []string, names ...string) (string, []string, error) {
+	if len(args) > 0 {
+		for _, name := range names {
+			if args[0] == name {
+				return name, args[1:], nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("expected one of %v", names)
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/mostafasolati/leviathan/auth"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/migration"
+	"github.com/mostafasolati/leviathan/pubsub"
+	"github.com/mostafasolati/leviathan/ratelimit"
+	"github.com/mostafasolati/leviathan/user"
+)
+
+func migrate(lev contracts.ILeviathan, args []string) error {
+	name, args, err := subcommand(args, "up", "down", "status", "create")
+	if err != nil {
+		return err
+	}
+
+	dir := lev.Config().String("database.migrations_dir")
+	if dir == "" {
+		dir = "migrations"
+	}
+
+	flags := flag.NewFlagSet("migrate "+name, flag.ExitOnError)
+	flags.StringVar(&dir, "dir", dir, "directory of the application migrations")
+	steps := flags.Int("steps", 1, "number of migrations to roll back")
+	_ = flags.Parse(args)
+
+	if name == "create" {
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: migrate create [-dir DIR] NAME")
+		}
+		up, down, err := migration.Create(dir, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return nil
+	}
+
+	sources := []fs.FS{user.Migrations(), auth.Migrations(), ratelimit.Migrations(), pubsub.Migrations()}
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		sources = append(sources, os.DirFS(dir))
+	}
+
+	migrations, err := migration.Load(sources...)
+	if err != nil {
+		return err
+	}
+	migrator := migration.NewMigrator(lev.DB(), migrations)
+
+	switch name {
+	case "up":
+		done, err := migrator.Up()
+		for _, m := range done {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "down":
+		done, err := migrator.Down(*steps)
+		for _, m := range done {
+			fmt.Printf("rolled back %d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	default:
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-19s  %d_%s\n", appliedAt, s.Version, s.Name)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func otp(lev contracts.ILeviathan, args []string) error {
+	_, args, err := subcommand(args, "peek")
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("otp peek", flag.ExitOnError)
+	phone := flags.String("phone", "", "phone number")
+	_ = flags.Parse(args)
+
+	if *phone == "" {
+		return fmt.Errorf("usage: otp peek -phone PHONE")
+	}
+
+	// OTPs of the memory store live in the server process.
+	if lev.Config().String("auth.otp.store") != "sql" {
+		return fmt.Errorf("otp peek needs the shared otp store, set auth.otp.store to sql")
+	}
+
+	code, err := lev.Auth().FindOTPOfAUser(*phone)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(code)
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func serve(lev contracts.ILeviathan, args []string) error {
+	address := lev.Config().String("server.address")
+	if address == "" {
+		address = ":8080"
+	}
+
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.StringVar(&address, "address", address, "address to listen on")
+	_ = flags.Parse(args)
+
+	lev.Logger().WithFields(contracts.LogFields{
+		"address": address,
+	}).Info("starting http server")
+	return lev.Run(address)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func token(lev contracts.ILeviathan, args []string) error {
+	_, args, err := subcommand(args, "issue")
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("token issue", flag.ExitOnError)
+	userID := flags.Int("user", 0, "user ID")
+	_ = flags.Parse(args)
+
+	if *userID == 0 {
+		return fmt.Errorf("usage: token issue -user ID")
+	}
+
+	t, err := lev.Auth().IssueToken(*userID)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t)
+}
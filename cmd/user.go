@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+func manageUser(lev contracts.ILeviathan, args []string) error {
+	name, args, err := subcommand(args, "create", "deactivate", "grant-role")
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("user "+name, flag.ExitOnError)
+	id := flags.Int("id", 0, "user ID")
+	phone := flags.String("phone", "", "phone number of the new user")
+	firstName := flags.String("first-name", "", "first name of the new user")
+	lastName := flags.String("last-name", "", "last name of the new user")
+	role := flags.String("role", "", "role to grant")
+	_ = flags.Parse(args)
+
+	switch name {
+	case "create":
+		if *phone == "" {
+			return fmt.Errorf("usage: user create -phone PHONE [-first-name NAME] [-last-name NAME]")
+		}
+		u, err := lev.User().Create(utils.NormalizePhoneNumber(*phone), *firstName, *lastName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created user %d\n", u.ID())
+
+	case "deactivate":
+		if *id == 0 {
+			return fmt.Errorf("usage: user deactivate -id ID")
+		}
+		if err := lev.User().Deactivate(*id); err != nil {
+			return err
+		}
+		fmt.Printf("deactivated user %d\n", *id)
+
+	default:
+		if *id == 0 || *role == "" {
+			return fmt.Errorf("usage: user grant-role -id ID -role ROLE")
+		}
+		if err := lev.User().GrantRole(*id, *role); err != nil {
+			return err
+		}
+		fmt.Printf("granted %s to user %d\n", *role, *id)
+	}
+
+	return nil
+}
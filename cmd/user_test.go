@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// recordingUsers records the calls of the user commands.
+type recordingUsers struct {
+	contracts.IUserService
+	calls []string
+}
+
+type createdUser struct {
+	contracts.IUser
+}
+
+func (createdUser) ID() int {
+	return 7
+}
+
+func (s *recordingUsers) Create(phone, firstName, lastName string) (contracts.IUser, error) {
+	s.calls = append(s.calls, fmt.Sprintf("create %s %s %s", phone, firstName, lastName))
+	return createdUser{}, nil
+}
+
+func (s *recordingUsers) Deactivate(id int) error {
+	s.calls = append(s.calls, fmt.Sprintf("deactivate %d", id))
+	return nil
+}
+
+func (s *recordingUsers) GrantRole(id int, role string) error {
+	s.calls = append(s.calls, fmt.Sprintf("grant %d %s", id, role))
+	return nil
+}
+
+type userCommandLeviathan struct {
+	contracts.ILeviathan
+	users *recordingUsers
+}
+
+func (l userCommandLeviathan) User() contracts.IUserService {
+	return l.users
+}
+
+func TestManageUser(t *testing.T) {
+	cases := []struct {
+		name  string
+		args  []string
+		calls []string
+		err   bool
+	}{
+		{name: "Create", args: []string{"create", "-phone", "+989121234567", "-first-name", "Ali"},
+			calls: []string{"create 09121234567 Ali "}},
+		{name: "CreateWithoutPhone", args: []string{"create", "-first-name", "Ali"}, err: true},
+		{name: "Deactivate", args: []string{"deactivate", "-id", "3"}, calls: []string{"deactivate 3"}},
+		{name: "DeactivateWithoutID", args: []string{"deactivate"}, err: true},
+		{name: "GrantRole", args: []string{"grant-role", "-id", "3", "-role", "admin"}, calls: []string{"grant 3 admin"}},
+		{name: "GrantRoleWithoutRole", args: []string{"grant-role", "-id", "3"}, err: true},
+		{name: "Unknown", args: []string{"delete", "-id", "3"}, err: true},
+		{name: "None", err: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			users := &recordingUsers{}
+			err := manageUser(userCommandLeviathan{users: users}, tc.args)
+			if (err != nil) != tc.err {
+				t.Errorf("manageUser = %v, want an error: %v", err, tc.err)
+			}
+			if !reflect.DeepEqual(users.calls, tc.calls) {
+				t.Errorf("calls = %q, want %q", users.calls, tc.calls)
+			}
+		})
+	}
+}
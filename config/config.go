@@ -1,8 +1,11 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
+	"sync"
 
 	"github.com/mostafasolati/leviathan/contracts"
 	"github.com/spf13/viper"
@@ -17,8 +20,19 @@ var envNames = map[string]contracts.Environment{
 	"development": contracts.Development,
 }
 
+// deprecatedKeys maps configuration parameters to the keys they were read
+// from before, which are read if they aren't set, logging a warning. The key
+// of JWTs was `auth.jwt_secret` until the `auth.jwt` section was added.
+var deprecatedKeys = map[string]string{
+	"auth.jwt.secret": "auth.jwt_secret",
+}
+
 type configService struct {
 	backend *viper.Viper
+	loaded  bool
+
+	// warned holds the deprecated keys already warned about
+	warned sync.Map
 }
 
 // NewConfigService creates a new IConfigService.
@@ -29,11 +43,11 @@ type configService struct {
 // accordingly.
 func NewConfigService() contracts.IConfigService {
 	backend := viper.New()
-	addLocalConfigProvider("./config.yml", backend)
-	return &configService{backend: backend}
+	loaded := addLocalConfigProvider("./config.yml", backend)
+	return &configService{backend: backend, loaded: loaded}
 }
 
-func addLocalConfigProvider(filename string, backend *viper.Viper) {
+func addLocalConfigProvider(filename string, backend *viper.Viper) bool {
 	backend.SetConfigName(filename)
 	backend.SetConfigType("yaml")
 	backend.AddConfigPath(".")
@@ -43,11 +57,20 @@ func addLocalConfigProvider(filename string, backend *viper.Viper) {
 		} else {
 			log.Printf("cannot read config file: %v\n", err)
 		}
-		return
+		return false
 	}
 
 	// Watch local config forever.
 	backend.WatchConfig()
+	return true
+}
+
+// HealthCheck implements IHealthChecker.HealthCheck
+func (s *configService) HealthCheck(ctx context.Context) error {
+	if !s.loaded {
+		return errors.New("config file is not loaded")
+	}
+	return nil
 }
 
 // IsDebug implements IConfigService.IsDebug
@@ -101,19 +124,31 @@ func (s *configService) StaticDir() string {
 	return "."
 }
 
+// resolve returns the deprecated key of a parameter if only that is set.
+func (s *configService) resolve(key string) string {
+	deprecated, ok := deprecatedKeys[key]
+	if !ok || s.backend.IsSet(key) || !s.backend.IsSet(deprecated) {
+		return key
+	}
+	if _, warned := s.warned.LoadOrStore(deprecated, true); !warned {
+		log.Printf("config: %s is deprecated, rename it to %s\n", deprecated, key)
+	}
+	return deprecated
+}
+
 // Int implements IConfigService.Int
 func (s *configService) Int(key string) int {
-	return s.backend.GetInt(key)
+	return s.backend.GetInt(s.resolve(key))
 }
 
 // Bool implements IConfigService.Bool
 func (s *configService) Bool(key string) bool {
-	return s.backend.GetBool(key)
+	return s.backend.GetBool(s.resolve(key))
 }
 
 // String implements IConfigService.String
 func (s *configService) String(key string) string {
-	return s.backend.GetString(key)
+	return s.backend.GetString(s.resolve(key))
 }
 
 // SetString implements IConfigService.SetString
@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestDeprecatedKeys(t *testing.T) {
+	cases := []struct {
+		name   string
+		values map[string]string
+		want   string
+	}{
+		{name: "Unset", want: ""},
+		{name: "Current", values: map[string]string{"auth.jwt.secret": "new"}, want: "new"},
+		{name: "Deprecated", values: map[string]string{"auth.jwt_secret": "old"}, want: "old"},
+		{name: "Both", values: map[string]string{"auth.jwt.secret": "new", "auth.jwt_secret": "old"}, want: "new"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewConfigService()
+			for key, value := range tc.values {
+				s.SetString(key, value)
+			}
+			if got := s.String("auth.jwt.secret"); got != tc.want {
+				t.Errorf("String = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import "regexp"
+
+// secretKeyRegex matches the configuration keys which hold credentials.
+var secretKeyRegex = regexp.MustCompile(
+	`(?i)(secret|password|passwd|api_key|apikey|token|dsn|private|credential)`,
+)
+
+// Redact returns a copy of a configuration dump (see IConfigService.Dump) in
+// which the values of secret parameters are masked, so it can be printed or
+// logged safely.
+func Redact(dump map[string]string) map[string]string {
+	redacted := make(map[string]string, len(dump))
+	for key, value := range dump {
+		if value != "" && secretKeyRegex.MatchString(key) {
+			value = "******"
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
@@ -1,14 +1,28 @@
 package contracts
 
-import "github.com/mostafasolati/leviathan/models"
+import (
+	"time"
+
+	"github.com/mostafasolati/leviathan/models"
+)
 
 type IAuth interface {
 	// SendOTP : User send his/her phone number and asks for an otp code
 	SendOTP(phone string, app string) error
 
+	// SendLocalizedOTP sends an otp code like SendOTP, in the message of the
+	// locale, e.g. "en".
+	SendLocalizedOTP(phone, app, locale string) error
+
 	// NoSendOTP generates OTP for a phone number but doesn't send it.
 	NoSendOTP(phone string) string
 
+	// FindOTPOfAUser returns the pending OTP of a phone number. It's meant for
+	// testing and debugging and refuses to work in production.
+	//
+	// It returns ErrOTPNotFound if there is no pending OTP.
+	FindOTPOfAUser(phone string) (string, error)
+
 	// LoginByOTP : User send his/her phone and received otp from previous step
 	// and asks for login. We will register the user if he/she is not registered
 	// before. Then we will send a pair of access token and refresh token to the
@@ -28,4 +42,23 @@ type IAuth interface {
 	//
 	// It returns ErrUnauthorized if the access token is invalid or expired.
 	ParseToken(accessToken string) (*models.UserClaims, error)
+
+	// IssueToken creates a pair of access token and refresh token for a user
+	// without any OTP. It's meant for testing and refuses to work in
+	// production, returning ErrNotInProduction.
+	IssueToken(userID int) (*models.Token, error)
+}
+
+// IOTPStore keeps the generated OTPs until they are used or expired.
+type IOTPStore interface {
+	// Save stores the OTP of a phone number, replacing the previous one.
+	Save(phone, code string, expireAt time.Time) error
+
+	// Find returns the OTP of a phone number and its expiry.
+	//
+	// It returns ErrOTPNotFound if there is no OTP for the phone number.
+	Find(phone string) (code string, expireAt time.Time, err error)
+
+	// Delete removes the OTP of a phone number.
+	Delete(phone string) error
 }
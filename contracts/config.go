@@ -10,6 +10,20 @@ const (
 	Production
 )
 
+var environmentNames = map[Environment]string{
+	Development: "development",
+	Staging:     "staging",
+	Production:  "production",
+}
+
+// Name returns the runtime environment's name.
+func (env Environment) Name() string {
+	if name, ok := environmentNames[env]; ok {
+		return name
+	}
+	return "unknown"
+}
+
 // IConfigService provides access to configuration parameters.
 type IConfigService interface {
 
@@ -0,0 +1,22 @@
+package contracts
+
+import "io"
+
+// IEncoder encodes responses and decodes requests in a format, e.g. JSON.
+type IEncoder interface {
+
+	// ContentType returns the `Content-Type` header of the encoded responses,
+	// e.g. `application/json; charset=UTF-8`.
+	ContentType() string
+
+	// MediaTypes returns the media types which the encoder is chosen for by
+	// the `Accept` and `Content-Type` headers, e.g. `application/json`.
+	MediaTypes() []string
+
+	// Encode writes v to w. It returns ErrNotEncodable if the format can't
+	// represent values like v, e.g. CSV a non-list value.
+	Encode(w io.Writer, v interface{}) error
+
+	// Decode reads r into v, which is a pointer.
+	Decode(r io.Reader, v interface{}) error
+}
@@ -3,13 +3,32 @@ package contracts
 // Define some error constant
 const (
 	// ErrFileDimensionQuota when an invalid dimention request comes to the server
-	// we check the requested dimension to preven resource waste on the server
-	ErrFileDimensionQuota = constError("width or height can't be more than 1000 pixels")
+	// we check the requested dimension to preven resource waste on the server,
+	// up to `image.max_dimension` pixels (1000 by default)
+	ErrFileDimensionQuota = constError("width or height is more than the dimension quota")
 	ErrOTPNotFound        = constError("otp not found")
 	ErrUserDeactivated    = constError("user is deactivated")
 	ErrUserNotFound       = constError("user not found")
 	ErrUnauthorized       = constError("user unauthorized")
 	ErrOTPIsIncorrect     = constError("otp is incorrect")
+	ErrNotInProduction    = constError("not available in production")
+	ErrTooManyRequests    = constError("too many requests")
+	ErrRouteNotFound      = constError("route not found")
+	ErrFileNotFound       = constError("file not found")
+	ErrNotEncodable       = constError("value cannot be encoded in the format")
+	ErrNotAcceptable      = constError("no acceptable response format")
+	ErrInvalidCursor      = constError("invalid cursor")
+	ErrSlowSubscriber     = constError("subscriber is too slow")
+	ErrPubSubClosed       = constError("pubsub is closed")
+	ErrInvalidStorageKey  = constError("invalid storage key")
+	ErrFileTooLarge       = constError("file is too large")
+	ErrFileTypeNotAllowed = constError("file type is not allowed")
+	ErrUnsafeFile         = constError("file content is unsafe")
+	ErrInvalidImageOption = constError("invalid image option")
+	ErrInvalidSignature   = constError("invalid signature")
+	ErrURLExpired         = constError("url is expired")
+	ErrSMSUnavailable     = constError("no sms provider is available")
+	ErrSMSRejected        = constError("sms is rejected")
 )
 
 type constError string
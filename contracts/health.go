@@ -0,0 +1,34 @@
+package contracts
+
+import "context"
+
+// HealthCheck reports an error if a subsystem is not ready to serve.
+type HealthCheck func(ctx context.Context) error
+
+// IHealthChecker is implemented by subsystems which can check their own
+// health, e.g. by pinging a remote service.
+type IHealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// IHealth is a registry of health checks which backs the `/healthz`,
+// `/readyz` and `/version` endpoints.
+type IHealth interface {
+	// AddCheck registers a readiness check. A failing check makes `/readyz`
+	// respond with 503 Service Unavailable.
+	AddCheck(name string, check HealthCheck)
+
+	// Check runs all readiness checks concurrently and returns the errors
+	// of the failed ones by their names. The checks which don't finish in
+	// `server.health.timeout` seconds fail, even if they ignore ctx.
+	Check(ctx context.Context) map[string]error
+
+	// Liveness responds with 200 OK as long as the process is serving.
+	Liveness(server IServer) error
+
+	// Readiness responds with the result of the readiness checks.
+	Readiness(server IServer) error
+
+	// Version responds with the build information of the running binary.
+	Version(server IServer) error
+}
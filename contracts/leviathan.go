@@ -1,9 +1,41 @@
 package contracts
 
+import (
+	"context"
+	"database/sql"
+)
+
+// Hook is a function run when the application starts or stops. The context
+// of stop hooks expires when the drain timeout is over.
+type Hook func(ctx context.Context) error
+
 type ILeviathan interface {
 	Config() IConfigService
 	Server() IServerContainer
 	Logger() ILogger
 	Auth() IAuth
 	User() IUserService
+	DB() *sql.DB
+	Health() IHealth
+	RateLimiter() IRateLimiter
+	PubSub() IPubSub
+	Storage() IStorage
+	Thumbnails() IThumbnailCache
+
+	// OnStart registers a hook to run before the server starts. Hooks run in
+	// the order of registration and a failing hook aborts the start.
+	OnStart(hook Hook)
+
+	// OnStop registers a hook to run after the server stops. Hooks run in the
+	// reverse order of registration, so subsystems registered first, like the
+	// database, are closed last. A stop hook belongs to the start hooks
+	// registered before it, so it's skipped if one of them didn't run.
+	OnStop(hook Hook)
+
+	// Run runs the start hooks and serves HTTP requests on address until
+	// SIGINT or SIGTERM is received. Then it drains in-flight requests for at
+	// most `server.shutdown_timeout` seconds, runs the stop hooks and closes
+	// the logger if it's an io.Closer. A second signal during the drain exits
+	// right away.
+	Run(address string) error
 }
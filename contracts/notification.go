@@ -1,5 +1,34 @@
 package contracts
 
+import "context"
+
 type INotificationService interface {
 	SendSMS(phone, message string) error
+
+	// SendOTP sends a one-time code to phone by the template of the client
+	// app and the locale, which is the configured one if empty.
+	SendOTP(phone, code, app, locale string) error
+}
+
+// ISMSProvider sends text messages through an SMS gateway.
+type ISMSProvider interface {
+	// Name returns the name which the provider is configured by, e.g. its
+	// API key is `notification.sms.<name>.api_key`.
+	Name() string
+
+	// Send sends message to phone from the sender line, which is empty if
+	// the provider has no lines configured. It wraps ErrSMSRejected if the
+	// provider refuses the message itself, e.g. for an invalid phone, as
+	// opposed to failing to send it.
+	Send(ctx context.Context, sender, phone, message string) error
+}
+
+// ISMSLookupProvider is an ISMSProvider which also sends messages by the
+// templates defined on the provider, e.g. Kavenegar's VerifyLookup, which
+// are delivered faster on dedicated lines.
+type ISMSLookupProvider interface {
+	ISMSProvider
+
+	// Lookup sends the message of template filled in by tokens to phone.
+	Lookup(ctx context.Context, phone, template string, tokens ...string) error
 }
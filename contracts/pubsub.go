@@ -0,0 +1,108 @@
+package contracts
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Message is a message published to a topic.
+type Message struct {
+	Topic string `json:"topic"`
+
+	// Data is the published value encoded as JSON, which can be sent as is,
+	// e.g. as the data of a server-sent event.
+	Data json.RawMessage `json:"data"`
+}
+
+// Backpressure is what happens to subscribers which don't keep up with the
+// messages of their topic, once their buffer is full.
+type Backpressure int
+
+// List of backpressure policies.
+const (
+	// DropOldest drops the oldest buffered message to make room for the new
+	// one.
+	DropOldest Backpressure = iota
+
+	// Disconnect closes the subscription with ErrSlowSubscriber.
+	Disconnect
+)
+
+// SubscribeOptions configure a subscription.
+type SubscribeOptions struct {
+	// Buffer is the number of messages buffered for the subscriber, which
+	// defaults to `pubsub.buffer` or 64.
+	Buffer int
+
+	// Backpressure defaults to `pubsub.backpressure`, either "drop_oldest"
+	// (default) or "disconnect".
+	Backpressure *Backpressure
+
+	// Member is tracked as present in the topic while subscribed, e.g. the
+	// ID of the user.
+	Member string
+}
+
+// SubscribeOption configures a subscription while subscribing.
+type SubscribeOption func(options *SubscribeOptions)
+
+// WithBuffer sets the number of messages buffered for the subscriber.
+func WithBuffer(size int) SubscribeOption {
+	return func(options *SubscribeOptions) {
+		options.Buffer = size
+	}
+}
+
+// WithBackpressure sets what happens when the subscriber doesn't keep up.
+func WithBackpressure(policy Backpressure) SubscribeOption {
+	return func(options *SubscribeOptions) {
+		options.Backpressure = &policy
+	}
+}
+
+// AsMember tracks member as present in the topic while subscribed.
+func AsMember(member string) SubscribeOption {
+	return func(options *SubscribeOptions) {
+		options.Member = member
+	}
+}
+
+// ISubscription receives the messages of a topic.
+type ISubscription interface {
+	// Messages returns the channel of messages, which is closed when the
+	// subscription is.
+	Messages() <-chan Message
+
+	// Err returns why the subscription was closed, e.g. ErrSlowSubscriber,
+	// or nil if it's open or was closed by Close or its context.
+	Err() error
+
+	// Close unsubscribes.
+	Close() error
+}
+
+// IPubSub fans messages out to the subscribers of topics, e.g. the event
+// streams and WebSockets of clients.
+type IPubSub interface {
+	// Publish sends v, encoded as JSON, to the subscribers of topic. It
+	// returns ErrPubSubClosed once the pubsub is closed.
+	Publish(ctx context.Context, topic string, v interface{}) error
+
+	// Subscribe subscribes to topic until ctx is done or the subscription is
+	// closed.
+	Subscribe(ctx context.Context, topic string, options ...SubscribeOption) (ISubscription, error)
+
+	// PublishToUser sends v to the subscribers of the channel of a user,
+	// keyed by UserClaims.ID.
+	PublishToUser(ctx context.Context, userID int, v interface{}) error
+
+	// SubscribeUser subscribes to the channel of a user, like Subscribe.
+	SubscribeUser(ctx context.Context, userID int, options ...SubscribeOption) (ISubscription, error)
+
+	// Presence returns the members present in topic, across replicas if the
+	// backend is shared.
+	Presence(ctx context.Context, topic string) ([]string, error)
+
+	// Close closes all the subscriptions and releases the backend.
+	Close() error
+}
@@ -0,0 +1,76 @@
+package contracts
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimitAlgorithm is the data-type for rate limiting algorithms.
+type RateLimitAlgorithm int
+
+// List of rate limiting algorithms.
+const (
+	// TokenBucket allows bursts of up to RateLimit.Burst requests and refills
+	// at a steady rate of RateLimit.Requests per RateLimit.Period.
+	TokenBucket RateLimitAlgorithm = iota
+
+	// SlidingWindow allows RateLimit.Requests in any RateLimit.Period,
+	// approximating the window by weighting the previous fixed window.
+	SlidingWindow
+)
+
+// RateLimitKey extracts the key which requests are counted by, e.g. the
+// client IP or the user ID.
+type RateLimitKey func(server IServer) string
+
+// RateLimit describes how many requests are allowed in a period.
+type RateLimit struct {
+	// Name separates the counters of different limits sharing a key, e.g.
+	// the route name.
+	Name      string
+	Requests  int
+	Period    time.Duration
+	Burst     int
+	Algorithm RateLimitAlgorithm
+
+	// Key defaults to the client IP.
+	Key RateLimitKey
+}
+
+// RateLimitResult is the outcome of counting a request.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Reset is the time until the quota is fully restored.
+	Reset time.Duration
+
+	// RetryAfter is the time until the next request may be allowed.
+	RetryAfter time.Duration
+}
+
+// IRateLimitStore keeps the counters of rate limits. Implementations must
+// update the counters atomically, since replicas share them.
+type IRateLimitStore interface {
+	// Take counts a request against the limit for key.
+	Take(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
+
+// IRateLimiter enforces rate limits on routes.
+type IRateLimiter interface {
+	// Limit wraps a handler so requests beyond limit are rejected with 429
+	// Too Many Requests. `RateLimit-*` headers are sent on every response.
+	// Routes are limited by the WithRateLimit option, which uses it.
+	Limit(limit RateLimit, handler Handler) Handler
+
+	// Global wraps a handler with the default limit of all routes, which is
+	// configured by `server.ratelimit.requests`, `period` (in seconds),
+	// `burst`, `algorithm` ("token_bucket" or "sliding_window") and `key`
+	// ("ip" or "user"). Handlers are returned as is if no default is set.
+	Global(handler Handler) Handler
+
+	// Allow counts a request against limit for a custom key, e.g. a phone
+	// number.
+	Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
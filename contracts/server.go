@@ -1,6 +1,7 @@
 package contracts
 
 import (
+	"context"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -11,12 +12,17 @@ import (
 // Handler is a function to handle http requests
 type Handler func(server IServer) error
 
+// Middleware wraps a handler, e.g. to log requests or to check permissions.
+// It may stop the request by returning without calling next.
+type Middleware func(next Handler) Handler
+
 // IServer is an abstraction of underneath server implementation like echo, fast http, etc.
 type IServer interface {
 	Request() *http.Request
 	ResponseWriter() http.ResponseWriter
 
-	// Bind converts an http request data to a struct
+	// Bind converts an http request data to a struct. The body is decoded by
+	// the encoder of the container handling its `Content-Type`.
 	Bind(in interface{}) error
 
 	// RawBody reads the raw body of the request.
@@ -40,7 +46,11 @@ type IServer interface {
 	// Redirect redirects the client to a url
 	Redirect(status int, url string) error
 
-	// Upload uploads a file from http request
+	// Upload stores a file uploaded in the request in the storage of the
+	// container, returning its ID. Files are named by the SHA-256 of their
+	// content, so uploading the same content again returns the same ID.
+	// The file is rejected if it exceeds the size limit or isn't of the
+	// types allowed for the field, as detected by its content.
 	Upload(field string) (string, error)
 
 	// User returns logged user if present
@@ -64,23 +74,272 @@ type IServer interface {
 	// UploadedFile returns an uploaded file.
 	UploadedFile(name string) (*multipart.FileHeader, error)
 
-	// Response send the final response to the client with a status code
+	// Response send the final response to the client with a status code, in
+	// the format preferred by the `Accept` header among the encoders of the
+	// container. Other formats than JSON are sent only if preferred as much
+	// as any media range of the header, e.g. not to browsers preferring HTML
+	// over XML, and 406 Not Acceptable if JSON is excluded by `q=0` and no
+	// format accepted can encode v.
 	Response(status int, v interface{}) error
 
 	// SetHeader sets http headers for response
 	SetHeader(key, value string)
+
+	// Page reads the requested page from the `page` and `per_page` query
+	// parameters. The page size defaults to `server.pagination.per_page` and
+	// is limited to `server.pagination.max_per_page`.
+	Page() models.Page
+
+	// Cursor decodes the `cursor` query parameter into v, leaving v as is on
+	// the first page, and returns the page size like Page. It returns
+	// ErrInvalidCursor if the cursor wasn't signed by PaginateCursor.
+	Cursor(v interface{}) (int, error)
+
+	// Paginate sends a page like Response, linking the first, previous, next
+	// and last pages in the `Link` header.
+	Paginate(status int, p *models.Pagination) error
+
+	// PaginateCursor sends data, a page of perPage items, like Response. next
+	// is the position after the page, e.g. the sort keys of its last item,
+	// which is signed into the cursor of the next page and linked in the
+	// `Link` header. next is nil on the last page.
+	PaginateCursor(status, perPage int, data, next interface{}) error
+
+	// Stream responds with server-sent events, which handler sends until
+	// it returns. A comment is sent every `server.stream.heartbeat` seconds
+	// (15 by default) to keep the connection open, and handler should stop
+	// once the stream is done.
+	Stream(handler func(stream IEventStream) error) error
+
+	// Upgrade upgrades the connection to a WebSocket, which is closed when
+	// the handler returns. The `Origin` of browsers must be allowed by
+	// `server.cors.allow_origins`. Routes are authenticated like any other,
+	// and since browsers can't set the `Authorization` header of WebSocket
+	// requests, the token may be sent in the `access_token` parameter.
+	Upgrade() (IWebSocket, error)
+}
+
+// IUploadProcessor processes the files uploaded by IServer.Upload, e.g. to
+// resize images.
+type IUploadProcessor interface {
+	// Process returns the content to store instead of an uploaded file of
+	// contentType, as detected by its content, and its type. It runs before
+	// the file is named, so the same upload is still stored once.
+	Process(ctx context.Context, field string, content []byte, contentType string) ([]byte, string, error)
+
+	// Uploaded runs once the file is stored, e.g. to render its variants.
+	Uploaded(ctx context.Context, file *models.File) error
+}
+
+// IDeleteProcessor is implemented by the upload processors which clean up
+// after the files deleted by IServerContainer.DeleteFile, e.g. to purge the
+// variants of images.
+type IDeleteProcessor interface {
+	// Deleted runs once the content of the file of id is deleted, i.e. no
+	// user has uploaded it anymore.
+	Deleted(ctx context.Context, id string) error
+}
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Name   string `json:"name,omitempty"`
+
+	// Authenticated is true if the route requires a logged in user
+	Authenticated bool `json:"authenticated"`
+
+	// Roles lists the roles allowed to access the route
+	Roles []string `json:"roles,omitempty"`
+
+	// Middleware wraps the route handler, outermost first
+	Middleware []Middleware `json:"-"`
+
+	// Summary and Tags describe the route in the generated API docs
+	Summary string   `json:"summary,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+
+	// Request is a value of the type which the route binds the request to
+	Request interface{} `json:"-"`
+
+	// Responses are values of the types which the route responds with, by
+	// their status codes
+	Responses map[int]interface{} `json:"-"`
+
+	// Hidden routes are left out of the generated API docs
+	Hidden bool `json:"hidden,omitempty"`
+
+	// RateLimit limits the requests of the route on top of the global limit
+	RateLimit *RateLimit `json:"-"`
+
+	// Unlimited exempts the route from the global limit
+	Unlimited bool `json:"-"`
+}
+
+// RouteOption configures a route while registering it
+type RouteOption func(route *RouteInfo)
+
+// Named names a route, so its URL can be built by IServerContainer.URL
+func Named(name string) RouteOption {
+	return func(route *RouteInfo) {
+		route.Name = name
+	}
+}
+
+// WithMiddleware wraps a route, or every route of a group, with middleware
+func WithMiddleware(middleware ...Middleware) RouteOption {
+	return func(route *RouteInfo) {
+		route.Middleware = append(route.Middleware, middleware...)
+	}
+}
+
+// WithRoles restricts a route, or every route of a group, to logged in users
+// having any of the roles
+func WithRoles(roles ...string) RouteOption {
+	return func(route *RouteInfo) {
+		route.Authenticated = true
+		route.Roles = append(route.Roles, roles...)
+	}
+}
+
+// WithSummary describes a route in the generated API docs
+func WithSummary(summary string) RouteOption {
+	return func(route *RouteInfo) {
+		route.Summary = summary
+	}
+}
+
+// WithTags groups a route, or every route of a group, in the generated API
+// docs
+func WithTags(tags ...string) RouteOption {
+	return func(route *RouteInfo) {
+		route.Tags = append(route.Tags, tags...)
+	}
+}
+
+// WithRequest declares the type which the route binds the request to, e.g.
+// WithRequest(&CreateOrderRequest{})
+func WithRequest(v interface{}) RouteOption {
+	return func(route *RouteInfo) {
+		route.Request = v
+	}
+}
+
+// WithResponse declares the type which the route responds with for a status
+// code, e.g. WithResponse(http.StatusOK, &models.Order{})
+func WithResponse(status int, v interface{}) RouteOption {
+	return func(route *RouteInfo) {
+		if route.Responses == nil {
+			route.Responses = make(map[int]interface{})
+		}
+		route.Responses[status] = v
+	}
+}
+
+// WithRateLimit limits the requests of a route, or of each route of a group,
+// on top of the global limit. Unless the limit is named, each route counts
+// its requests separately.
+func WithRateLimit(limit RateLimit) RouteOption {
+	return func(route *RouteInfo) {
+		route.RateLimit = &limit
+	}
+}
+
+// WithoutGlobalRateLimit exempts a route, or every route of a group, from
+// the global rate limit, e.g. health probes which mustn't fail under load.
+// Limits of WithRateLimit still apply.
+func WithoutGlobalRateLimit() RouteOption {
+	return func(route *RouteInfo) {
+		route.Unlimited = true
+	}
+}
+
+// Hidden leaves a route, or every route of a group, out of the generated API
+// docs
+func Hidden() RouteOption {
+	return func(route *RouteInfo) {
+		route.Hidden = true
+	}
+}
+
+// IRouter registers routes and middleware
+type IRouter interface {
+
+	// Route registers a route to a corresponding handler. Any HTTP method is
+	// supported, and an empty method means GET.
+	Route(method, path string, handler Handler, options ...RouteOption)
+
+	// Use adds middleware to the routes. Middleware of the container wraps
+	// all routes, while middleware of a group wraps the routes registered on
+	// the group afterwards. Middleware runs after authentication, so
+	// IServer.User is available.
+	Use(middleware ...Middleware)
+
+	// Group returns a sub-router whose routes are prefixed by prefix and
+	// configured by options, e.g. WithMiddleware or WithRoles.
+	Group(prefix string, options ...RouteOption) IRouter
 }
 
 // IServerContainer is responsible to fire the http server and register the handlers
 type IServerContainer interface {
+	IRouter
 
-	// Route registers a route to a corresponding handler
-	Route(method, path string, handler Handler)
-
+	// SecureRoutes restricts access to the routes under the given path
+	// prefixes to the given roles. It must be called before registering the
+	// routes.
 	SecureRoutes(routes map[string][]string)
 
-	// Run starts http server
-	Run(address string)
+	// URL builds the path of a named route, filling its `:param` and `*`
+	// segments with params in order.
+	//
+	// It returns ErrRouteNotFound if there is no route with the name.
+	URL(name string, params ...string) (string, error)
+
+	// Routes lists the registered routes in the order of registration
+	Routes() []RouteInfo
+
+	// RegisterEncoder adds formats of the responses sent by IServer.Response
+	// and the requests decoded by IServer.Bind. An encoder replaces the
+	// registered one handling the same media type. JSON, XML, MessagePack,
+	// CSV and protobuf are registered by default.
+	RegisterEncoder(encoders ...IEncoder)
+
+	// Storage returns the storage of the files uploaded by IServer.Upload
+	Storage() IStorage
+
+	// File looks up the metadata of a file uploaded by IServer.Upload as
+	// recorded for uploaderID, returning ErrFileNotFound if the user didn't
+	// upload a file of the ID.
+	File(ctx context.Context, id string, uploaderID int) (*models.File, error)
+
+	// DeleteFile deletes a file uploaded by IServer.Upload as recorded for
+	// uploaderID, and its content once no user has uploaded it, running the
+	// upload processors implementing IDeleteProcessor. It returns
+	// ErrFileNotFound if the user didn't upload a file of the ID.
+	DeleteFile(ctx context.Context, id string, uploaderID int) error
+
+	// RegisterUploadProcessor adds processors of the files uploaded by
+	// IServer.Upload, which run in the order of registration.
+	RegisterUploadProcessor(processors ...IUploadProcessor)
+
+	// ResumableUpload registers the routes of resumable uploads of files in
+	// field at path, following the tus protocol (https://tus.io): POST path
+	// creates an upload of `Upload-Length` bytes, PATCH path/:id appends a
+	// chunk at `Upload-Offset`, HEAD path/:id reports the offset, and
+	// DELETE path/:id discards the upload. Uploads expire after
+	// `server.uploads.expiry` seconds (a day by default) without a chunk.
+	// Complete files are validated, processed and stored like
+	// IServer.Upload, then handler, which may be nil, gets them during the
+	// last PATCH. Uploads may only be resumed by the user who created them.
+	ResumableUpload(path, field string, handler func(server IServer, file *models.File) error, options ...RouteOption)
+
+	// Run starts http server and blocks until it stops. It returns nil if the
+	// server is stopped by Shutdown.
+	Run(address string) error
+
+	// Shutdown stops accepting new connections and waits for in-flight
+	// requests to finish or ctx to expire.
+	Shutdown(ctx context.Context) error
 
 	// TestServer returns an HTTP server for testing purposes.
 	TestServer() *httptest.Server
@@ -0,0 +1,65 @@
+package contracts
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// FileInfo describes a stored file.
+type FileInfo struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	ModTime     time.Time `json:"mod_time"`
+	ETag        string    `json:"etag,omitempty"`
+}
+
+// IStorage stores files by keys like `original/photo.png`. Keys are paths
+// separated by `/`, and can't be absolute or contain `..`.
+type IStorage interface {
+	// Put stores the content of r under key, replacing any file stored
+	// under it.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+
+	// Get opens the file stored under key, which the caller must close. It
+	// returns ErrFileNotFound if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error)
+
+	// Delete deletes the file stored under key, if any.
+	Delete(ctx context.Context, key string) error
+
+	// Stat describes the file stored under key. It returns ErrFileNotFound
+	// if there is none.
+	Stat(ctx context.Context, key string) (*FileInfo, error)
+
+	// List describes the files whose keys start with prefix, ordered by key.
+	List(ctx context.Context, prefix string) ([]FileInfo, error)
+
+	// SignedURL returns a URL which downloads the file stored under key
+	// until it expires.
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// IRangeStorage is implemented by the storages which read part of a file
+// without the rest, e.g. S3, so ranges of large files are served without
+// reading them whole.
+type IRangeStorage interface {
+	// GetRange opens length bytes of the file stored under key from offset,
+	// or the rest of it if length is negative. It returns ErrFileNotFound if
+	// there is none.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// IThumbnailCache keeps the thumbnails of uploaded images, i.e. the images
+// resized on request, in the storage, evicting the least recently used ones
+// beyond its size.
+type IThumbnailCache interface {
+	// Get returns the thumbnail of key, which render encodes if it isn't
+	// cached. It's rendered once however many requests ask for it at once.
+	Get(ctx context.Context, key string, render func() ([]byte, error)) (io.ReadCloser, *FileInfo, error)
+
+	// Purge deletes the thumbnails of an uploaded file, e.g. once the
+	// original is deleted.
+	Purge(ctx context.Context, id string) error
+}
@@ -0,0 +1,61 @@
+package contracts
+
+import "time"
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is sent back by the client in the `Last-Event-ID` header when it
+	// reconnects, so the stream can resume after it.
+	ID string
+
+	// Event is the type of the event, which is "message" if empty.
+	Event string
+
+	// Data is sent as is if it's a string or []byte, and as JSON otherwise.
+	Data interface{}
+
+	// Retry tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// IEventStream sends server-sent events to a client.
+type IEventStream interface {
+	// Send sends an event and flushes it to the client.
+	Send(event Event) error
+
+	// LastEventID returns the ID of the last event the client received
+	// before reconnecting, or "" on the first connection.
+	LastEventID() string
+
+	// Done is closed when the client disconnects or the server shuts down.
+	Done() <-chan struct{}
+}
+
+// Types of WebSocket messages.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// IWebSocket is a WebSocket connection. Its methods may be called
+// concurrently, except for the reads.
+type IWebSocket interface {
+	// ReadMessage reads the next message, returning its type and data.
+	ReadMessage() (int, []byte, error)
+
+	// ReadJSON reads the next message as JSON into v.
+	ReadJSON(v interface{}) error
+
+	// WriteMessage sends a message of a type.
+	WriteMessage(messageType int, data []byte) error
+
+	// WriteJSON sends v as a JSON text message.
+	WriteJSON(v interface{}) error
+
+	// Close closes the connection.
+	Close() error
+
+	// Done is closed when the connection is closed, e.g. the client goes
+	// away or the server shuts down.
+	Done() <-chan struct{}
+}
@@ -1,6 +1,10 @@
 package contracts
 
-import "time"
+import (
+	"time"
+
+	"github.com/mostafasolati/leviathan/models"
+)
 
 type IUser interface {
 	ID() int
@@ -9,6 +13,8 @@ type IUser interface {
 	FirstName() string
 	LastName() string
 	Phone() string
+	Roles() []string
+	CreatedAt() time.Time
 	RefreshTokenExpiry() *time.Time
 	RefreshToken() string
 	SetRefreshToken(token string)
@@ -16,7 +22,35 @@ type IUser interface {
 }
 
 type IUserService interface {
+	// FindByID returns the user with the given ID.
+	//
+	// It returns ErrUserNotFound if there is no such user.
+	FindByID(id int) (IUser, error)
+
+	// FindByPhone returns the user owning the phone number.
+	//
+	// It returns ErrUserNotFound if there is no such user.
 	FindByPhone(phone string) (IUser, error)
+
+	// FindByRefreshToken returns the user owning the refresh token.
+	//
+	// It returns ErrUserNotFound if there is no such user.
 	FindByRefreshToken(token string) (IUser, error)
-	Create() error
+
+	// Create registers a new user with the default "user" role.
+	Create(phone, firstName, lastName string) (IUser, error)
+
+	// Update persists the changes made to the user, e.g. a new refresh token.
+	Update(user IUser) error
+
+	// Deactivate soft-deletes the user. Deactivated users cannot login.
+	Deactivate(id int) error
+
+	// GrantRole adds a role to the user's roles.
+	GrantRole(id int, role string) error
+
+	// List returns a page of the users matching the filters of q in its
+	// order, having only the fields selected by q, and the number of all
+	// the matching users.
+	List(q *models.Query, page models.Page) ([]IUser, int, error)
 }
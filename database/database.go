@@ -0,0 +1,38 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// NewDatabase opens the database described by the `database.driver` and
+// `database.dsn` configuration parameters.
+//
+// The driver itself must be registered by the application, e.g. by importing
+// `github.com/lib/pq`. Opening the database doesn't connect to it; the first
+// query does.
+func NewDatabase(config contracts.IConfigService) (*sql.DB, error) {
+	driver := config.String("database.driver")
+	if driver == "" {
+		driver = "postgres"
+	}
+
+	db, err := sql.Open(driver, config.String("database.dsn"))
+	if err != nil {
+		return nil, err
+	}
+
+	if n := config.Int("database.max_open_conns"); n > 0 {
+		db.SetMaxOpenConns(n)
+	}
+	if n := config.Int("database.max_idle_conns"); n > 0 {
+		db.SetMaxIdleConns(n)
+	}
+	if n := config.Int("database.conn_max_lifetime"); n > 0 {
+		db.SetConnMaxLifetime(time.Duration(n) * time.Second)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,270 @@
+package encoder
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+type csvEncoder struct{}
+
+// NewCSV creates an encoder of `text/csv`, which encodes lists only: slices
+// of structs, of string-keyed maps and of string slices. The first row holds
+// the column names, which are taken from the `csv` tags of struct fields,
+// falling back to their `json` tags. Cells which spreadsheets would run as
+// formulas are prefixed with `'`, which decoding removes.
+func NewCSV() contracts.IEncoder {
+	return csvEncoder{}
+}
+
+// ContentType implements IEncoder.ContentType
+func (csvEncoder) ContentType() string {
+	return "text/csv; charset=UTF-8"
+}
+
+// MediaTypes implements IEncoder.MediaTypes
+func (csvEncoder) MediaTypes() []string {
+	return []string{"text/csv"}
+}
+
+// Encode implements IEncoder.Encode
+func (csvEncoder) Encode(w io.Writer, v interface{}) error {
+	list := reflect.ValueOf(v)
+	for list.Kind() == reflect.Ptr && !list.IsNil() {
+		list = list.Elem()
+	}
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return contracts.ErrNotEncodable
+	}
+
+	rows, err := csvRows(list)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if isFormula(cell) {
+				row[i] = "'" + cell
+			}
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// csvRows converts the items of a list to rows.
+func csvRows(list reflect.Value) ([][]string, error) {
+	elemType := list.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	switch {
+	case elemType.Kind() == reflect.Struct:
+		fields := csvFields(elemType)
+		header := make([]string, len(fields))
+		for i, field := range fields {
+			header[i] = field.name
+		}
+		rows := [][]string{header}
+
+		for i := 0; i < list.Len(); i++ {
+			item := reflect.Indirect(list.Index(i))
+			row := make([]string, len(fields))
+			if item.IsValid() {
+				for j, field := range fields {
+					cell, err := formatValue(item.FieldByIndex(field.index))
+					if err != nil {
+						return nil, contracts.ErrNotEncodable
+					}
+					row[j] = cell
+				}
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+
+	case elemType.Kind() == reflect.Map && elemType.Key().Kind() == reflect.String:
+		columns := make(map[string]bool)
+		for i := 0; i < list.Len(); i++ {
+			for _, key := range reflect.Indirect(list.Index(i)).MapKeys() {
+				columns[key.String()] = true
+			}
+		}
+		header := make([]string, 0, len(columns))
+		for column := range columns {
+			header = append(header, column)
+		}
+		sort.Strings(header)
+		rows := [][]string{header}
+
+		for i := 0; i < list.Len(); i++ {
+			item := reflect.Indirect(list.Index(i))
+			row := make([]string, len(header))
+			for j, column := range header {
+				value := item.MapIndex(reflect.ValueOf(column).Convert(elemType.Key()))
+				if !value.IsValid() {
+					continue
+				}
+				cell, err := formatValue(value)
+				if err != nil {
+					return nil, contracts.ErrNotEncodable
+				}
+				row[j] = cell
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+
+	case elemType.Kind() == reflect.Slice && elemType.Elem().Kind() == reflect.String:
+		rows := make([][]string, list.Len())
+		for i := range rows {
+			row := list.Index(i)
+			rows[i] = make([]string, row.Len())
+			for j := range rows[i] {
+				rows[i][j] = row.Index(j).String()
+			}
+		}
+		return rows, nil
+	}
+
+	return nil, contracts.ErrNotEncodable
+}
+
+// Decode implements IEncoder.Decode
+//
+// v points to a slice of structs, whose fields are matched by the header
+// row, or to a slice of string slices holding all the rows.
+func (csvEncoder) Decode(r io.Reader, v interface{}) error {
+	list := reflect.ValueOf(v)
+	if list.Kind() != reflect.Ptr || list.Elem().Kind() != reflect.Slice {
+		return contracts.ErrNotEncodable
+	}
+	list = list.Elem()
+
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if strings.HasPrefix(cell, "'") && isFormula(cell[1:]) {
+				row[i] = cell[1:]
+			}
+		}
+	}
+
+	elemType := list.Type().Elem()
+	if elemType.Kind() == reflect.Slice && elemType.Elem().Kind() == reflect.String {
+		list.Set(reflect.ValueOf(rows).Convert(list.Type()))
+		return nil
+	}
+
+	structType := elemType
+	for structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct || len(rows) == 0 {
+		return contracts.ErrNotEncodable
+	}
+
+	fields := csvFields(structType)
+	columns := make([][]int, len(rows[0]))
+	for i, name := range rows[0] {
+		for _, field := range fields {
+			if strings.EqualFold(field.name, strings.TrimSpace(name)) {
+				columns[i] = field.index
+				break
+			}
+		}
+	}
+
+	items := reflect.MakeSlice(list.Type(), 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		item := reflect.New(structType).Elem()
+		for i, cell := range row {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+			if err := ParseValue(item.FieldByIndex(columns[i]), cell); err != nil {
+				return err
+			}
+		}
+		for t := elemType; t.Kind() == reflect.Ptr; t = t.Elem() {
+			ptr := reflect.New(item.Type())
+			ptr.Elem().Set(item)
+			item = ptr
+		}
+		items = reflect.Append(items, item)
+	}
+	list.Set(items)
+	return nil
+}
+
+// isFormula reports whether spreadsheets would run a cell as a formula, i.e.
+// it starts with `=`, `+`, `-`, `@`, a tab or a carriage return and isn't a
+// number, so Encode prefixes it with `'`. Cells which would be taken for
+// prefixed ones are prefixed too, so Decode removes the prefixes exactly.
+func isFormula(cell string) bool {
+	if cell == "" {
+		return false
+	}
+	switch cell[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		_, err := strconv.ParseFloat(cell, 64)
+		return err != nil
+	case '\'':
+		return isFormula(cell[1:])
+	}
+	return false
+}
+
+type csvField struct {
+	name  string
+	index []int
+}
+
+// csvFields returns the columns of a struct, flattening embedded structs.
+func csvFields(t reflect.Type) []csvField {
+	var fields []csvField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("csv"), ",")[0]
+		if name == "" {
+			name = strings.Split(field.Tag.Get("json"), ",")[0]
+		}
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for _, embedded := range csvFields(field.Type) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, csvField{name: name, index: []int{i}})
+	}
+	return fields
+}
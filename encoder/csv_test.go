@@ -0,0 +1,51 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+func TestCSVFormulas(t *testing.T) {
+	cases := []struct {
+		name string
+		cell string
+		want string
+	}{
+		{name: "Text", cell: "hello", want: "hello"},
+		{name: "Equals", cell: "=HYPERLINK(\"http://evil\")", want: "'=HYPERLINK(\"http://evil\")"},
+		{name: "Plus", cell: "+cmd|' /C calc'!A0", want: "'+cmd|' /C calc'!A0"},
+		{name: "Minus", cell: "-2+3", want: "'-2+3"},
+		{name: "At", cell: "@SUM(A1)", want: "'@SUM(A1)"},
+		{name: "Tab", cell: "\t=1", want: "'\t=1"},
+		{name: "CarriageReturn", cell: "\r=1", want: "'\r=1"},
+		{name: "Negative", cell: "-5", want: "-5"},
+		{name: "Signed", cell: "+1.5", want: "+1.5"},
+		{name: "Quoted", cell: "'hello", want: "'hello"},
+		{name: "QuotedFormula", cell: "'=1", want: "''=1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewCSV().Encode(&buf, [][]string{{tc.cell}}); err != nil {
+				t.Fatal(err)
+			}
+			written, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(written) != 1 || written[0][0] != tc.want {
+				t.Errorf("wrote %q, want %q", written, tc.want)
+			}
+
+			var decoded [][]string
+			if err := NewCSV().Decode(&buf, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, [][]string{{tc.cell}}) {
+				t.Errorf("decoded %q, want %q", decoded, tc.cell)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Package encoder provides the formats of responses and requests, which are
+// registered on IServerContainer.
+package encoder
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// Defaults returns the encoders registered on the server containers by
+// default. JSON comes first, so it's sent to clients accepting any format.
+func Defaults() []contracts.IEncoder {
+	return []contracts.IEncoder{
+		NewJSON(),
+		NewXML(),
+		NewMessagePack(),
+		NewCSV(),
+		NewProtobuf(),
+	}
+}
+
+type jsonEncoder struct{}
+
+// NewJSON creates an encoder of `application/json`.
+func NewJSON() contracts.IEncoder {
+	return jsonEncoder{}
+}
+
+// ContentType implements IEncoder.ContentType
+func (jsonEncoder) ContentType() string {
+	return "application/json; charset=UTF-8"
+}
+
+// MediaTypes implements IEncoder.MediaTypes
+func (jsonEncoder) MediaTypes() []string {
+	return []string{"application/json"}
+}
+
+// Encode implements IEncoder.Encode
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Decode implements IEncoder.Decode
+func (jsonEncoder) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type xmlEncoder struct{}
+
+// NewXML creates an encoder of `application/xml`. Maps can't be encoded.
+func NewXML() contracts.IEncoder {
+	return xmlEncoder{}
+}
+
+// ContentType implements IEncoder.ContentType
+func (xmlEncoder) ContentType() string {
+	return "application/xml; charset=UTF-8"
+}
+
+// MediaTypes implements IEncoder.MediaTypes
+func (xmlEncoder) MediaTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+// Encode implements IEncoder.Encode
+func (xmlEncoder) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	err := xml.NewEncoder(w).Encode(v)
+	if _, ok := err.(*xml.UnsupportedTypeError); ok {
+		return contracts.ErrNotEncodable
+	}
+	return err
+}
+
+// Decode implements IEncoder.Decode
+func (xmlEncoder) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,97 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/contracts"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type item struct {
+	Name  string   `json:"name" xml:"name"`
+	Count int      `json:"count" xml:"count"`
+	Tags  []string `json:"tags" xml:"tags"`
+}
+
+type row struct {
+	Name  string `csv:"name" json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		name    string
+		encoder contracts.IEncoder
+		value   interface{}
+		want    string
+		err     error
+	}{
+		{name: "JSON", encoder: NewJSON(), value: &item{Name: "a", Count: 2}, want: `{"name":"a","count":2,"tags":null}` + "\n"},
+		{name: "XML", encoder: NewXML(), value: &item{Name: "a", Count: 2}, want: xml.Header + "<item><name>a</name><count>2</count></item>"},
+		{name: "MessagePack", encoder: NewMessagePack(), value: &item{Name: "a", Count: 2}, want: "\x83\xa4name\xa1a\xa5count\x02\xa4tags\xc0"},
+		{name: "CSV", encoder: NewCSV(), value: []row{{Name: "a", Count: 1}, {Name: "b,c", Count: 2}}, want: "name,count\na,1\n\"b,c\",2\n"},
+		{name: "CSVNotList", encoder: NewCSV(), value: &item{Name: "a"}, err: contracts.ErrNotEncodable},
+		{name: "Protobuf", encoder: NewProtobuf(), value: wrapperspb.String("a"), want: "\x0a\x01a"},
+		{name: "ProtobufNotMessage", encoder: NewProtobuf(), value: &item{Name: "a"}, err: contracts.ErrNotEncodable},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := tc.encoder.Encode(&buf, tc.value)
+			if err != tc.err {
+				t.Fatalf("Encode = %v, want %v", err, tc.err)
+			}
+			if err == nil && buf.String() != tc.want {
+				t.Errorf("Encode = %q, want %q", buf.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		name    string
+		encoder contracts.IEncoder
+		body    string
+		value   interface{}
+		want    interface{}
+	}{
+		{name: "JSON", encoder: NewJSON(), body: `{"name":"a","count":2,"tags":["x","y"]}`,
+			value: &item{}, want: &item{Name: "a", Count: 2, Tags: []string{"x", "y"}}},
+		{name: "XML", encoder: NewXML(), body: "<item><name>a</name><count>2</count><tags>x</tags></item>",
+			value: &item{}, want: &item{Name: "a", Count: 2, Tags: []string{"x"}}},
+		{name: "MessagePack", encoder: NewMessagePack(), body: "\x82\xa4name\xa1a\xa4tags\x91\xa1x",
+			value: &item{}, want: &item{Name: "a", Tags: []string{"x"}}},
+		// Columns are matched by the header row.
+		{name: "CSV", encoder: NewCSV(), body: "count,name\n1,a\n2,b\n",
+			value: &[]row{}, want: &[]row{{Name: "a", Count: 1}, {Name: "b", Count: 2}}},
+		{name: "CSVPointers", encoder: NewCSV(), body: "name\na\n",
+			value: &[]*row{}, want: &[]*row{{Name: "a"}}},
+		{name: "CSVRows", encoder: NewCSV(), body: "a,'=1\n",
+			value: &[][]string{}, want: &[][]string{{"a", "=1"}}},
+		{name: "Protobuf", encoder: NewProtobuf(), body: "\x0a\x01a",
+			value: &wrapperspb.StringValue{}, want: wrapperspb.String("a")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.encoder.Decode(strings.NewReader(tc.body), tc.value); err != nil {
+				t.Fatal(err)
+			}
+			if message, ok := tc.want.(proto.Message); ok {
+				if !proto.Equal(tc.value.(proto.Message), message) {
+					t.Errorf("Decode = %v, want %v", tc.value, tc.want)
+				}
+				return
+			}
+			if !reflect.DeepEqual(tc.value, tc.want) {
+				t.Errorf("Decode = %+v, want %+v", tc.value, tc.want)
+			}
+		})
+	}
+}
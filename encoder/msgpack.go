@@ -0,0 +1,41 @@
+package encoder
+
+import (
+	"io"
+
+	"github.com/mostafasolati/leviathan/contracts"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type messagePackEncoder struct{}
+
+// NewMessagePack creates an encoder of `application/msgpack`. Struct fields
+// are named by their `json` tags, like in JSON.
+func NewMessagePack() contracts.IEncoder {
+	return messagePackEncoder{}
+}
+
+// ContentType implements IEncoder.ContentType
+func (messagePackEncoder) ContentType() string {
+	return "application/msgpack"
+}
+
+// MediaTypes implements IEncoder.MediaTypes
+func (messagePackEncoder) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack"}
+}
+
+// Encode implements IEncoder.Encode
+func (messagePackEncoder) Encode(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// Decode implements IEncoder.Decode
+func (messagePackEncoder) Decode(r io.Reader, v interface{}) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
@@ -0,0 +1,55 @@
+package encoder
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/mostafasolati/leviathan/contracts"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type protobufEncoder struct{}
+
+// NewProtobuf creates an encoder of `application/x-protobuf`, which encodes
+// proto messages only.
+func NewProtobuf() contracts.IEncoder {
+	return protobufEncoder{}
+}
+
+// ContentType implements IEncoder.ContentType
+func (protobufEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// MediaTypes implements IEncoder.MediaTypes
+func (protobufEncoder) MediaTypes() []string {
+	return []string{"application/x-protobuf", "application/protobuf"}
+}
+
+// Encode implements IEncoder.Encode
+func (protobufEncoder) Encode(w io.Writer, v interface{}) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return contracts.ErrNotEncodable
+	}
+	b, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Decode implements IEncoder.Decode
+func (protobufEncoder) Decode(r io.Reader, v interface{}) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return contracts.ErrNotEncodable
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, message)
+}
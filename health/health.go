@@ -0,0 +1,147 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+)
+
+type health struct {
+	config contracts.IConfigService
+
+	mu     sync.RWMutex
+	checks map[string]contracts.HealthCheck
+}
+
+// NewHealthService creates a new IHealth.
+func NewHealthService(config contracts.IConfigService) contracts.IHealth {
+	return &health{
+		config: config,
+		checks: make(map[string]contracts.HealthCheck),
+	}
+}
+
+// AddCheck implements IHealth.AddCheck
+func (s *health) AddCheck(name string, check contracts.HealthCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[name] = check
+}
+
+// Check implements IHealth.Check
+func (s *health) Check(ctx context.Context) map[string]error {
+	timeout := time.Duration(s.config.Int("server.health.timeout")) * time.Second
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	s.mu.RLock()
+	checks := make(map[string]contracts.HealthCheck, len(s.checks))
+	for name, check := range s.checks {
+		checks[name] = check
+	}
+	s.mu.RUnlock()
+
+	type result struct {
+		name string
+		err  error
+	}
+	// The channel is buffered, so checks ignoring ctx and finishing after
+	// the timeout don't block forever.
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func(name string, check contracts.HealthCheck) {
+			results <- result{name: name, err: check(ctx)}
+		}(name, check)
+	}
+
+	failures := make(map[string]error)
+	for range checks {
+		select {
+		case r := <-results:
+			delete(checks, r.name)
+			if r.err != nil {
+				failures[r.name] = r.err
+			}
+		case <-ctx.Done():
+			// The checks which haven't finished yet fail by the timeout.
+			for name := range checks {
+				failures[name] = ctx.Err()
+			}
+			return failures
+		}
+	}
+	return failures
+}
+
+// Liveness implements IHealth.Liveness
+func (s *health) Liveness(server contracts.IServer) error {
+	server.SetHeader("Cache-Control", "no-store")
+	return server.JSON(http.StatusOK, &models.Health{Status: "ok"})
+}
+
+// Readiness implements IHealth.Readiness
+func (s *health) Readiness(server contracts.IServer) error {
+	failures := s.Check(server.Request().Context())
+
+	s.mu.RLock()
+	checks := make(map[string]string, len(s.checks))
+	for name := range s.checks {
+		checks[name] = "ok"
+	}
+	s.mu.RUnlock()
+
+	status := http.StatusOK
+	response := &models.Health{Status: "ok", Checks: checks}
+	for name, err := range failures {
+		status = http.StatusServiceUnavailable
+		response.Status = "unavailable"
+		// Hide the details from the outside world in production.
+		checks[name] = "failed"
+		if !s.config.IsProduction() {
+			checks[name] = err.Error()
+		}
+	}
+
+	server.SetHeader("Cache-Control", "no-store")
+	return server.JSON(status, response)
+}
+
+// Version implements IHealth.Version
+func (s *health) Version(server contracts.IServer) error {
+	return server.JSON(http.StatusOK, buildInfo())
+}
+
+func buildInfo() *models.BuildInfo {
+	info := &models.BuildInfo{Version: "unknown"}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = bi.GoVersion
+	info.Path = bi.Main.Path
+	if bi.Main.Version != "" {
+		info.Version = bi.Main.Version
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.Time = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
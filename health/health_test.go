@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func TestCheck(t *testing.T) {
+	failed := errors.New("failed")
+	block := make(chan struct{})
+	defer close(block)
+
+	cases := []struct {
+		name   string
+		checks map[string]contracts.HealthCheck
+		want   map[string]error
+	}{
+		{
+			name: "Healthy",
+			checks: map[string]contracts.HealthCheck{
+				"a": func(ctx context.Context) error { return nil },
+				"b": func(ctx context.Context) error { return nil },
+			},
+			want: map[string]error{},
+		},
+		{
+			name: "Failed",
+			checks: map[string]contracts.HealthCheck{
+				"a": func(ctx context.Context) error { return nil },
+				"b": func(ctx context.Context) error { return failed },
+			},
+			want: map[string]error{"b": failed},
+		},
+		{
+			name: "IgnoresContext",
+			checks: map[string]contracts.HealthCheck{
+				"a": func(ctx context.Context) error { return failed },
+				"b": func(ctx context.Context) error {
+					<-block
+					return nil
+				},
+			},
+			want: map[string]error{"a": failed, "b": context.DeadlineExceeded},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("server.health.timeout", "1")
+			s := NewHealthService(cfg)
+			for name, check := range tc.checks {
+				s.AddCheck(name, check)
+			}
+
+			start := time.Now()
+			got := s.Check(context.Background())
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Check took %v, want at most the timeout", elapsed)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("Check = %v, want %v", got, tc.want)
+			}
+			for name, err := range tc.want {
+				if got[name] != err {
+					t.Errorf("Check[%s] = %v, want %v", name, got[name], err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckUnlocked(t *testing.T) {
+	s := NewHealthService(config.NewConfigService())
+	// Checks run without the lock, so they may register others.
+	s.AddCheck("register", func(ctx context.Context) error {
+		s.AddCheck("registered", func(ctx context.Context) error { return nil })
+		return nil
+	})
+
+	if got := s.Check(context.Background()); len(got) != 0 {
+		t.Errorf("Check = %v, want no failure", got)
+	}
+}
@@ -0,0 +1,230 @@
+package images
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+// DefaultCacheSize is the size of the thumbnails kept, in bytes, unless
+// configured by `image.cache.max_size`.
+const DefaultCacheSize = 1 << 30
+
+// variantsPrefix is the prefix of the keys of all thumbnails.
+const variantsPrefix = "variants/"
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// flight is a thumbnail being rendered, which other requests wait for.
+type flight struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+type thumbnailCache struct {
+	storage contracts.IStorage
+	maxSize int64
+
+	mu      sync.Mutex
+	loaded  bool
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+	flights map[string]*flight
+}
+
+// NewThumbnailCache creates an IThumbnailCache keeping thumbnails in storage,
+// up to `image.cache.max_size` bytes. The storage writes files atomically,
+// e.g. aside and renamed on disk, so thumbnails are never read partially
+// written. Recency is tracked by each node, starting from the modification
+// time of the thumbnails found in the storage.
+func NewThumbnailCache(config contracts.IConfigService, storage contracts.IStorage) contracts.IThumbnailCache {
+	return &thumbnailCache{
+		storage: storage,
+		maxSize: int64(intOr(config.Int("image.cache.max_size"), DefaultCacheSize)),
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		flights: make(map[string]*flight),
+	}
+}
+
+// Get implements IThumbnailCache.Get
+func (c *thumbnailCache) Get(ctx context.Context, key string, render func() ([]byte, error)) (io.ReadCloser, *contracts.FileInfo, error) {
+	if err := c.load(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	r, info, err := c.storage.Get(ctx, key)
+	if err == nil {
+		c.touch(key, info.Size)
+		return r, info, nil
+	}
+	if err != contracts.ErrFileNotFound {
+		return nil, nil, err
+	}
+
+	// The rendered thumbnail is sent as it is, since it may be evicted from
+	// the storage meanwhile.
+	data, err := c.render(ctx, key, render)
+	if err != nil {
+		return nil, nil, err
+	}
+	info = &contracts.FileInfo{
+		Key:         key,
+		Size:        int64(len(data)),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		ModTime:     time.Now(),
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), info, nil
+}
+
+// render renders and stores the thumbnail of key, or waits for the request
+// rendering it already, returning its content.
+func (c *thumbnailCache) render(ctx context.Context, key string, render func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if f, ok := c.flights[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.data, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+
+	// The thumbnail is stored even if the request is canceled meanwhile,
+	// since others may be waiting for it.
+	ctx = context.WithoutCancel(ctx)
+	data, err := render()
+	if err == nil {
+		err = c.storage.Put(ctx, key, bytes.NewReader(data), "")
+	}
+	if err != nil {
+		f.err = err
+		return nil, err
+	}
+	f.data = data
+	c.touch(key, int64(len(data)))
+	c.evict(ctx)
+	return data, nil
+}
+
+// Purge implements IThumbnailCache.Purge
+func (c *thumbnailCache) Purge(ctx context.Context, id string) error {
+	files, err := c.storage.List(ctx, utils.VariantsPrefix(id))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := c.storage.Delete(ctx, file.Key); err != nil {
+			return err
+		}
+		c.remove(file.Key)
+	}
+	return nil
+}
+
+// load tracks the thumbnails in the storage, from the least recently
+// modified, the first time the cache is used. The storage is listed without
+// the lock, so requests of thumbnails tracked meanwhile aren't held up.
+func (c *thumbnailCache) load(ctx context.Context) error {
+	c.mu.Lock()
+	loaded := c.loaded
+	c.mu.Unlock()
+	if loaded {
+		return nil
+	}
+
+	files, err := c.storage.List(ctx, variantsPrefix)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded {
+		return nil
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime.Before(files[j].ModTime)
+	})
+	for _, file := range files {
+		if _, ok := c.entries[file.Key]; !ok {
+			c.entries[file.Key] = c.lru.PushFront(&cacheEntry{key: file.Key, size: file.Size})
+			c.size += file.Size
+		}
+	}
+	c.loaded = true
+	return nil
+}
+
+// touch marks a thumbnail as the most recently used.
+func (c *thumbnailCache) touch(key string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		c.size += size - entry.size
+		entry.size = size
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: size})
+	c.size += size
+}
+
+func (c *thumbnailCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.size -= e.Value.(*cacheEntry).size
+		c.lru.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// evict deletes the least recently used thumbnails until the cache fits in
+// its size, keeping the most recent one however large.
+func (c *thumbnailCache) evict(ctx context.Context) {
+	for {
+		c.mu.Lock()
+		back := c.lru.Back()
+		if c.size <= c.maxSize || back == nil || back == c.lru.Front() {
+			c.mu.Unlock()
+			return
+		}
+		entry := back.Value.(*cacheEntry)
+		c.size -= entry.size
+		c.lru.Remove(back)
+		delete(c.entries, entry.key)
+		c.mu.Unlock()
+
+		// A thumbnail failing to be deleted is left untracked, until the
+		// cache is loaded again on the next start.
+		_ = c.storage.Delete(ctx, entry.key)
+	}
+}
@@ -0,0 +1,204 @@
+package images
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/storage"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+func newTestCache(maxSize string, s contracts.IStorage) *thumbnailCache {
+	cfg := config.NewConfigService()
+	cfg.SetString("image.cache.max_size", maxSize)
+	return NewThumbnailCache(cfg, s).(*thumbnailCache)
+}
+
+// get gets the thumbnail of key rendered as data, counting the renders.
+func get(t *testing.T, c *thumbnailCache, key, data string, renders *int32) {
+	t.Helper()
+	r, info, err := c.Get(context.Background(), key, func() ([]byte, error) {
+		atomic.AddInt32(renders, 1)
+		return []byte(data), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data || info.Size != int64(len(data)) {
+		t.Errorf("Get(%s) = %q of %d bytes, want %q", key, got, info.Size, data)
+	}
+}
+
+// stored returns the keys of the thumbnails in s.
+func stored(t *testing.T, s contracts.IStorage) string {
+	t.Helper()
+	files, err := s.List(context.Background(), variantsPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, file := range files {
+		keys = append(keys, strings.TrimPrefix(file.Key, variantsPrefix))
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestThumbnailCacheSingleFlight(t *testing.T) {
+	c := newTestCache("100", storage.NewMemoryStorage(config.NewConfigService()))
+	var renders int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, c, variantsPrefix+"a.png", "aaa", &renders)
+		}()
+	}
+	wg.Wait()
+
+	if renders != 1 {
+		t.Errorf("rendered %d times, want once", renders)
+	}
+}
+
+func TestThumbnailCacheEviction(t *testing.T) {
+	cases := []struct {
+		name    string
+		maxSize string
+		gets    []string
+		renders int32
+		stored  string
+	}{
+		{name: "Fits", maxSize: "6", gets: []string{"a", "b", "a"}, renders: 2, stored: "a.png,b.png"},
+		{name: "LeastRecent", maxSize: "6", gets: []string{"a", "b", "a", "c"}, renders: 3, stored: "a.png,c.png"},
+		{name: "Refetched", maxSize: "6", gets: []string{"a", "b", "c", "a"}, renders: 4, stored: "a.png,c.png"},
+		// The most recent thumbnail is kept however large.
+		{name: "Larger", maxSize: "1", gets: []string{"a", "b"}, renders: 2, stored: "b.png"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := storage.NewMemoryStorage(config.NewConfigService())
+			c := newTestCache(tc.maxSize, s)
+			var renders int32
+			for _, name := range tc.gets {
+				get(t, c, variantsPrefix+name+".png", strings.Repeat(name, 3), &renders)
+			}
+
+			if renders != tc.renders {
+				t.Errorf("rendered %d times, want %d", renders, tc.renders)
+			}
+			if got := stored(t, s); got != tc.stored {
+				t.Errorf("stored %s, want %s", got, tc.stored)
+			}
+		})
+	}
+}
+
+func TestThumbnailCacheLoad(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(config.NewConfigService())
+	// Thumbnails of a previous run, the oldest first.
+	for _, name := range []string{"a", "b"} {
+		if err := s.Put(ctx, variantsPrefix+name+".png", strings.NewReader("xxx"), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := newTestCache("6", s)
+	var renders int32
+	get(t, c, variantsPrefix+"a.png", "xxx", &renders)
+	get(t, c, variantsPrefix+"c.png", "ccc", &renders)
+
+	if renders != 1 {
+		t.Errorf("rendered %d times, want once", renders)
+	}
+	if got := stored(t, s); got != "a.png,c.png" {
+		t.Errorf("stored %s, want the loaded thumbnail used last evicted", got)
+	}
+}
+
+func TestThumbnailCachePurge(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(config.NewConfigService())
+	c := newTestCache("100", s)
+	first, second := "aaaa1111.png", "bbbb2222.png"
+	var renders int32
+	get(t, c, utils.VariantsPrefix(first)+"1.png", "11", &renders)
+	get(t, c, utils.VariantsPrefix(first)+"2.png", "22", &renders)
+	get(t, c, utils.VariantsPrefix(second)+"1.png", "33", &renders)
+
+	if err := c.Purge(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if got := stored(t, s); got != "bb/bb/bbbb2222.png/1.png" {
+		t.Errorf("stored %s, want the thumbnail of the other file", got)
+	}
+	if c.size != 2 || c.lru.Len() != 1 {
+		t.Errorf("tracking %d thumbnails of %d bytes, want 1 of 2", c.lru.Len(), c.size)
+	}
+
+	// Purged thumbnails are rendered again.
+	get(t, c, utils.VariantsPrefix(first)+"1.png", "11", &renders)
+	if renders != 4 {
+		t.Errorf("rendered %d times, want 4", renders)
+	}
+}
+
+// evictingStorage deletes the files once they're stored, as if they were
+// evicted by another request right away.
+type evictingStorage struct {
+	contracts.IStorage
+}
+
+func (s evictingStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if err := s.IStorage.Put(ctx, key, r, contentType); err != nil {
+		return err
+	}
+	return s.IStorage.Delete(ctx, key)
+}
+
+func TestThumbnailCacheEvictedWhileServed(t *testing.T) {
+	c := newTestCache("100", evictingStorage{storage.NewMemoryStorage(config.NewConfigService())})
+	var renders int32
+	get(t, c, variantsPrefix+"a.png", "aaa", &renders)
+
+	r, info, err := c.Get(context.Background(), variantsPrefix+"b.png", func() ([]byte, error) {
+		return []byte("bbb"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if info.ContentType != "image/png" {
+		t.Errorf("content type = %s, want image/png", info.ContentType)
+	}
+}
+
+func TestProcessorDeleted(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(config.NewConfigService())
+	c := newTestCache("100", s)
+	id := "aaaa1111.png"
+	var renders int32
+	get(t, c, utils.VariantsPrefix(id)+"1.png", "11", &renders)
+
+	p := NewProcessor(config.NewConfigService(), s, c)
+	if err := p.(contracts.IDeleteProcessor).Deleted(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if got := stored(t, s); got != "" {
+		t.Errorf("stored %s, want the thumbnails purged", got)
+	}
+}
@@ -0,0 +1,129 @@
+package images
+
+import (
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// Format encodes images of a media type
+type Format struct {
+	MediaType string
+
+	// Lossy formats may replace the sources of any format, and lossless ones
+	// only the sources of lossless formats, which they can't bloat.
+	Lossy bool
+
+	// Encode encodes img at quality, from 1 to 100, which lossless formats
+	// ignore.
+	Encode func(w io.Writer, img image.Image, quality int) error
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]Format{
+		"image/jpeg": {
+			MediaType: "image/jpeg",
+			Lossy:     true,
+			Encode: func(w io.Writer, img image.Image, quality int) error {
+				return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+			},
+		},
+		"image/png": {
+			MediaType: "image/png",
+			Encode: func(w io.Writer, img image.Image, quality int) error {
+				return png.Encode(w, img)
+			},
+		},
+		"image/gif": {
+			MediaType: "image/gif",
+			Encode: func(w io.Writer, img image.Image, quality int) error {
+				return gif.Encode(w, img, nil)
+			},
+		},
+		// WebP images are encoded lossless, unless a lossy encoder is
+		// registered.
+		"image/webp": {
+			MediaType: "image/webp",
+			Encode: func(w io.Writer, img image.Image, quality int) error {
+				return nativewebp.Encode(w, img, nil)
+			},
+		},
+	}
+
+	// preferred lists the formats served to the clients which accept them
+	// explicitly, e.g. by `Accept: image/avif,image/webp,*/*`, in the order of
+	// preference.
+	preferred = []string{"image/avif", "image/webp"}
+)
+
+// RegisterFormat adds a format of the images served, replacing the format of
+// the same media type. AVIF images are served once a format of `image/avif`
+// is registered, e.g. by an encoder binding libavif, and WebP images are
+// encoded lossy once a lossy format of `image/webp` is registered.
+func RegisterFormat(format Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[format.MediaType] = format
+}
+
+func lookupFormat(mediaType string) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	format, ok := formats[mediaType]
+	return format, ok
+}
+
+// preferredFormats returns the preferred formats registered which may replace
+// images of the source type.
+func preferredFormats(source string) []Format {
+	sourceFormat, ok := lookupFormat(source)
+	lossySource := !ok || sourceFormat.Lossy
+	var formats []Format
+	for _, mediaType := range preferred {
+		if format, ok := lookupFormat(mediaType); ok && (format.Lossy || !lossySource) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// negotiate returns the format of an image of the source type served to a
+// client sending the `Accept` header; a preferred format it accepts which may
+// replace the source, or else the source format, falling back to PNG.
+func negotiate(accept, source string) (Format, bool) {
+	for _, format := range preferredFormats(source) {
+		if accepts(accept, format.MediaType) {
+			return format, true
+		}
+	}
+	if format, ok := lookupFormat(source); ok {
+		return format, true
+	}
+	return lookupFormat("image/png")
+}
+
+// accepts reports whether an `Accept` header lists mediaType explicitly,
+// rather than by a wildcard.
+func accepts(accept, mediaType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || accepted != mediaType {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
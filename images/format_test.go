@@ -0,0 +1,64 @@
+package images
+
+import (
+	"image"
+	"io"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	avif := Format{
+		MediaType: "image/avif",
+		Lossy:     true,
+		Encode: func(w io.Writer, img image.Image, quality int) error {
+			return nil
+		},
+	}
+	cases := []struct {
+		name     string
+		register []Format
+		accept   string
+		source   string
+		want     string
+	}{
+		{name: "Source", accept: "*/*", source: "image/png", want: "image/png"},
+		{name: "WebP", accept: "image/webp,*/*", source: "image/png", want: "image/webp"},
+		{name: "WebPRefused", accept: "image/webp;q=0,*/*", source: "image/png", want: "image/png"},
+		// Lossless WebP would bloat photos.
+		{name: "LosslessWebPOfJPEG", accept: "image/webp,*/*", source: "image/jpeg", want: "image/jpeg"},
+		{name: "UnknownSource", accept: "*/*", source: "image/bmp", want: "image/png"},
+		{name: "AVIFUnregistered", accept: "image/avif,*/*", source: "image/png", want: "image/png"},
+		{name: "AVIF", register: []Format{avif}, accept: "image/avif,image/webp,*/*", source: "image/jpeg",
+			want: "image/avif"},
+		{name: "AVIFNotAccepted", register: []Format{avif}, accept: "image/webp,*/*", source: "image/png",
+			want: "image/webp"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer restoreFormats()()
+			for _, format := range tc.register {
+				RegisterFormat(format)
+			}
+
+			format, ok := negotiate(tc.accept, tc.source)
+			if !ok || format.MediaType != tc.want {
+				t.Errorf("negotiate = %s, %v, want %s", format.MediaType, ok, tc.want)
+			}
+		})
+	}
+}
+
+// restoreFormats returns a function restoring the registered formats.
+func restoreFormats() func() {
+	formatsMu.Lock()
+	saved := make(map[string]Format, len(formats))
+	for mediaType, format := range formats {
+		saved[mediaType] = format
+	}
+	formatsMu.Unlock()
+	return func() {
+		formatsMu.Lock()
+		formats = saved
+		formatsMu.Unlock()
+	}
+}
@@ -0,0 +1,367 @@
+// Package images serves uploaded images, resized on request.
+package images
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/utils"
+
+	"github.com/disintegration/imaging"
+
+	// Uploaded WebP images are decoded.
+	_ "golang.org/x/image/webp"
+)
+
+// Defaults of the configuration parameters of the images
+const (
+	DefaultQuality      = 85
+	DefaultCacheMaxAge  = 365 * 24 * 60 * 60
+	DefaultBannerWidth  = 1200
+	DefaultBannerHeight = 400
+	DefaultAvatarSize   = 256
+	DefaultMaxPixels    = 50 * 1000 * 1000
+)
+
+// Preset is a size of the images, whose variants are rendered once images
+// are uploaded.
+type Preset struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Presets returns the presets of banners, of `image.banner.width` by
+// `image.banner.height`, and of avatars, of `image.avatar.size` squared.
+func Presets(config contracts.IConfigService) []Preset {
+	avatar := intOr(config.Int("image.avatar.size"), DefaultAvatarSize)
+	return []Preset{
+		{
+			Name:   "banner",
+			Width:  intOr(config.Int("image.banner.width"), DefaultBannerWidth),
+			Height: intOr(config.Int("image.banner.height"), DefaultBannerHeight),
+		},
+		{Name: "avatar", Width: avatar, Height: avatar},
+	}
+}
+
+// Register registers the routes serving the images uploaded to the storage of
+// container, which utils.ImageURL, utils.BannerURL and utils.AvatarURL link
+// to, resized and cached in thumbnails:
+//
+//	GET /v1/image/:file?w=&h=&fit=&fx=&fy=&q=
+//	GET /v1/image/banner/:file?fx=&fy=&q=
+//	GET /v1/image/avatar/:file?fx=&fy=&q=
+//
+// w and h are limited to `image.max_dimension`, fit is fill (default), fit or
+// crop, fx and fy are the focal point, from 0 to 1 by steps of 0.01, and q is
+// the quality, which defaults to `image.quality`. Banners and avatars are
+// filled to the size of their preset. Images are sent as AVIF or WebP to the
+// clients accepting them, when a format of the type may replace the source
+// (see RegisterFormat), and cached for `image.cache_max_age` seconds.
+//
+// Uploaded images are processed by the pipeline of NewProcessor, and the
+// variants of the presets are rendered once they're uploaded.
+func Register(config contracts.IConfigService, container contracts.IServerContainer, thumbnails contracts.IThumbnailCache) {
+	h := &handler{config: config, storage: container.Storage(), thumbnails: thumbnails}
+
+	container.Route(http.MethodGet, "/v1/image/:file", func(server contracts.IServer) error {
+		options, err := parseOptions(config, server)
+		if err != nil {
+			return err
+		}
+		if max := utils.MaxImageDimension(config); options.Width > max || options.Height > max {
+			return contracts.ErrFileDimensionQuota
+		}
+		return h.serve(server, server.Param("file"), options)
+	}, contracts.Named("image"), contracts.WithTags("files"))
+
+	for _, preset := range Presets(config) {
+		preset := preset
+		container.Route(http.MethodGet, "/v1/image/"+preset.Name+"/:file", func(server contracts.IServer) error {
+			options, err := parseOptions(config, server)
+			if err != nil {
+				return err
+			}
+			options.Width, options.Height, options.Fit = preset.Width, preset.Height, Fill
+			return h.serve(server, server.Param("file"), options)
+		}, contracts.Named(preset.Name), contracts.WithTags("files"))
+	}
+
+	container.RegisterUploadProcessor(NewProcessor(config, container.Storage(), thumbnails))
+}
+
+// Thumb returns the storage key of a thumbnail of an uploaded image, which is
+// cropped to fill w x h, in the format of the image.
+func Thumb(ctx context.Context, config contracts.IConfigService, storage contracts.IStorage, thumbnails contracts.IThumbnailCache, id string, w, h int) (string, error) {
+	if max := utils.MaxImageDimension(config); w > max || h > max {
+		return "", contracts.ErrFileDimensionQuota
+	}
+	file, err := utils.LookupFile(ctx, storage, id)
+	if err != nil {
+		return "", err
+	}
+	format, ok := lookupFormat(file.ContentType)
+	if !ok {
+		return "", contracts.ErrFileTypeNotAllowed
+	}
+
+	options := defaultOptions(config)
+	options.Width, options.Height = w, h
+	key, _, err := variantKey(id, format, options)
+	if err != nil {
+		return "", err
+	}
+	r, _, err := thumbnails.Get(ctx, key, func() ([]byte, error) {
+		return render(ctx, config, storage, id, format, options)
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, r.Close()
+}
+
+// defaultOptions returns the options of images filled around their center.
+func defaultOptions(config contracts.IConfigService) Options {
+	return Options{
+		Fit:     Fill,
+		FocusX:  0.5,
+		FocusY:  0.5,
+		Quality: intOr(config.Int("image.quality"), DefaultQuality),
+	}
+}
+
+// parseOptions reads the options of an image from the query.
+func parseOptions(config contracts.IConfigService, server contracts.IServer) (Options, error) {
+	var err error
+	options := defaultOptions(config)
+	if options.Width, err = intParam(server, "w", 0, 1<<16); err != nil {
+		return options, err
+	}
+	if options.Height, err = intParam(server, "h", 0, 1<<16); err != nil {
+		return options, err
+	}
+	if q := server.Query("q"); q != "" {
+		if options.Quality, err = intParam(server, "q", 1, 100); err != nil {
+			return options, err
+		}
+	}
+	if options.FocusX, err = floatParam(server, "fx", options.FocusX); err != nil {
+		return options, err
+	}
+	if options.FocusY, err = floatParam(server, "fy", options.FocusY); err != nil {
+		return options, err
+	}
+
+	switch fit := server.Query("fit"); fit {
+	case "":
+	case Fill, Fit, Crop:
+		options.Fit = fit
+	default:
+		return options, contracts.ErrInvalidImageOption
+	}
+	return options, nil
+}
+
+type handler struct {
+	config     contracts.IConfigService
+	storage    contracts.IStorage
+	thumbnails contracts.IThumbnailCache
+}
+
+// serve sends the image of an uploaded file resized by the options.
+func (h *handler) serve(server contracts.IServer, id string, options Options) error {
+	ctx := server.Request().Context()
+	file, err := utils.LookupFile(ctx, h.storage, id)
+	if err == contracts.ErrFileNotFound {
+		return server.JSON(http.StatusNotFound, &models.Error{
+			Message: err.Error(),
+			Code:    http.StatusNotFound,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Vector images are sent as they are.
+	if file.ContentType == "image/svg+xml" {
+		server.SetHeader("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+		return h.send(server, file.ContentType, etag(id), func() (io.ReadCloser, *contracts.FileInfo, error) {
+			return h.storage.Get(ctx, utils.OriginalKey(id))
+		})
+	}
+
+	format, ok := negotiate(server.Request().Header.Get("Accept"), file.ContentType)
+	if !ok {
+		return contracts.ErrFileTypeNotAllowed
+	}
+	server.SetHeader("Vary", "Accept")
+	if options.original() && format.MediaType == file.ContentType {
+		return h.send(server, file.ContentType, etag(id), func() (io.ReadCloser, *contracts.FileInfo, error) {
+			return h.storage.Get(ctx, utils.OriginalKey(id))
+		})
+	}
+
+	key, tag, err := variantKey(id, format, options)
+	if err != nil {
+		return err
+	}
+	return h.send(server, format.MediaType, tag, func() (io.ReadCloser, *contracts.FileInfo, error) {
+		return h.thumbnails.Get(ctx, key, func() ([]byte, error) {
+			return render(ctx, h.config, h.storage, id, format, options)
+		})
+	})
+}
+
+// send sends the file of contentType which get opens, unless the client has it already.
+func (h *handler) send(server contracts.IServer, contentType, tag string, get func() (io.ReadCloser, *contracts.FileInfo, error)) error {
+	if notModified(server, h.config, tag) {
+		return nil
+	}
+	r, info, err := get()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cacheHeaders(server, h.config, tag)
+	server.SetHeader("Content-Type", contentType)
+	server.SetHeader("Content-Length", strconv.FormatInt(info.Size, 10))
+	server.ResponseWriter().WriteHeader(http.StatusOK)
+	_, err = io.Copy(server.ResponseWriter(), r)
+	return err
+}
+
+// variantKey returns the storage key and the ETag of an image resized by the
+// options, in format.
+func variantKey(id string, format Format, options Options) (string, string, error) {
+	tag := etag(id, options.Width, options.Height, options.Fit, options.FocusX, options.FocusY,
+		options.Quality, format.MediaType)
+	ext, err := utils.ExtFromMIMEType(format.MediaType)
+	if err != nil {
+		return "", "", err
+	}
+	return utils.VariantsPrefix(id) + strings.Trim(tag, `"`) + "." + ext, tag, nil
+}
+
+// render resizes the image of an uploaded file, encoded in format.
+func render(ctx context.Context, config contracts.IConfigService, storage contracts.IStorage, id string, format Format, options Options) ([]byte, error) {
+	r, _, err := storage.Get(ctx, utils.OriginalKey(id))
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := decode(config, content)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := format.Encode(&buf, transform(img, options), options.Quality); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkPixels fails with ErrFileTooLarge if an image has more pixels than
+// `image.upload.max_pixels` (50 million by default), by its header, so
+// images which are small files but huge once decoded aren't allocated.
+func checkPixels(config contracts.IConfigService, content []byte) error {
+	c, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return contracts.ErrFileTypeNotAllowed
+	}
+	max := intOr(config.Int("image.upload.max_pixels"), DefaultMaxPixels)
+	if int64(c.Width)*int64(c.Height) > int64(max) {
+		return contracts.ErrFileTooLarge
+	}
+	return nil
+}
+
+// decode decodes an image once checkPixels lets it.
+func decode(config contracts.IConfigService, content []byte, options ...imaging.DecodeOption) (image.Image, error) {
+	if err := checkPixels(config, content); err != nil {
+		return nil, err
+	}
+	return imaging.Decode(bytes.NewReader(content), options...)
+}
+
+// notModified responds with 304 Not Modified if the client has the image of
+// tag already, reporting whether it has.
+func notModified(server contracts.IServer, config contracts.IConfigService, tag string) bool {
+	for _, match := range strings.Split(server.Request().Header.Get("If-None-Match"), ",") {
+		if match = strings.TrimSpace(match); match == tag || match == "*" {
+			cacheHeaders(server, config, tag)
+			server.ResponseWriter().WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// cacheHeaders lets clients cache images for good, since they're named by
+// their content.
+func cacheHeaders(server contracts.IServer, config contracts.IConfigService, tag string) {
+	maxAge := intOr(config.Int("image.cache_max_age"), DefaultCacheMaxAge)
+	server.SetHeader("ETag", tag)
+	server.SetHeader("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", maxAge))
+}
+
+// etag returns a strong ETag of an image by its file and options.
+func etag(id string, options ...interface{}) string {
+	key := id
+	for _, option := range options {
+		key += fmt.Sprintf("|%v", option)
+	}
+	hash := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+func intParam(server contracts.IServer, key string, min, max int) (int, error) {
+	value := server.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, contracts.ErrInvalidImageOption
+	}
+	return n, nil
+}
+
+// floatParam reads a fraction from 0 to 1, rounded to 2 decimals so that the
+// variants of about the same focal point are rendered and cached once.
+func floatParam(server contracts.IServer, key string, fallback float64) (float64, error) {
+	value := server.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > 1 {
+		return 0, contracts.ErrInvalidImageOption
+	}
+	return math.Round(f*100) / 100, nil
+}
+
+func intOr(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,283 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/images"
+	"github.com/mostafasolati/leviathan/logger"
+	"github.com/mostafasolati/leviathan/ratelimit"
+	services "github.com/mostafasolati/leviathan/server"
+	"github.com/mostafasolati/leviathan/storage"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+// pngImage is a 40x20 PNG image, and contentID the ID of it once uploaded.
+var (
+	pngImage = func() []byte {
+		var buf bytes.Buffer
+		_ = png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)))
+		return buf.Bytes()
+	}()
+	contentID = fmt.Sprintf("%x.png", sha256.Sum256(pngImage))
+)
+
+// exifJPEG returns a 40x20 JPEG photo having an EXIF orientation.
+func exifJPEG(orientation byte) []byte {
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil)
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08" +
+		"\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00" + string(orientation) + "\x00\x00" +
+		"\x00\x00\x00\x00")
+	segment := append([]byte{0xff, 0xe1, 0, byte(len(exif) + 2)}, exif...)
+	content := buf.Bytes()
+	return append(append(append([]byte{}, content[:2]...), segment...), content[2:]...)
+}
+
+// newContainer creates a container serving the images of its storage, under
+// the configuration of key-value pairs.
+func newContainer(t *testing.T, pairs ...string) (contracts.IServerContainer, contracts.IConfigService) {
+	cfg := config.NewConfigService()
+	cfg.SetString("static-dir", t.TempDir())
+	for i := 0; i+1 < len(pairs); i += 2 {
+		cfg.SetString(pairs[i], pairs[i+1])
+	}
+	l := logger.NewLogger(cfg)
+	c := services.NewHTTPServerContainer(cfg, l, ratelimit.NewRateLimiter(cfg, l, ratelimit.NewMemoryStore()),
+		storage.NewMemoryStorage(cfg))
+	images.Register(cfg, c, images.NewThumbnailCache(cfg, c.Storage()))
+	return c, cfg
+}
+
+func TestRegister(t *testing.T) {
+	cases := []struct {
+		name         string
+		config       []string
+		path         string
+		header       map[string]string
+		status       int
+		contentType  string
+		width        int
+		height       int
+		cacheControl string
+	}{
+		{name: "Original", path: contentID, header: map[string]string{"Accept": "*/*"}, status: http.StatusOK,
+			contentType: "image/png", width: 40, height: 20, cacheControl: "public, max-age=31536000, immutable"},
+		{name: "Fill", path: contentID + "?w=10&h=10&fx=0&fy=0", status: http.StatusOK, contentType: "image/png", width: 10, height: 10},
+		{name: "Fit", path: contentID + "?w=10&h=10&fit=fit", status: http.StatusOK, contentType: "image/png", width: 10, height: 5},
+		{name: "Crop", path: contentID + "?w=10&h=30&fit=crop", status: http.StatusOK, contentType: "image/png", width: 10, height: 20},
+		{name: "Width", path: contentID + "?w=20", status: http.StatusOK, contentType: "image/png", width: 20, height: 10},
+		{name: "WebP", path: contentID + "?w=10", header: map[string]string{"Accept": "image/webp,*/*"}, status: http.StatusOK,
+			contentType: "image/webp", width: 10, height: 5},
+		{name: "Banner", config: []string{"image.banner.width", "30", "image.banner.height", "10"}, path: "banner/" + contentID,
+			status: http.StatusOK, contentType: "image/png", width: 30, height: 10},
+		{name: "NotModified", path: contentID + "?w=10", header: map[string]string{"If-None-Match": "*"}, status: http.StatusNotModified},
+		{name: "Quota", config: []string{"image.max_dimension", "20"}, path: contentID + "?w=30", status: http.StatusBadRequest},
+		{name: "TooManyPixels", config: []string{"image.upload.max_pixels", "100"}, path: contentID + "?w=10", status: http.StatusBadRequest},
+		{name: "InvalidFit", path: contentID + "?w=10&fit=stretch", status: http.StatusBadRequest},
+		{name: "NotFound", path: fmt.Sprintf("%x.png", sha256.Sum256(nil)), status: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newContainer(t, tc.config...)
+			if _, err := utils.StoreFile(context.Background(), c.Storage(), "photo.png", bytes.NewReader(pngImage), "image/png", 0); err != nil {
+				t.Fatal(err)
+			}
+			ts := c.TestServer()
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/image/"+tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "image/png")
+			for key, value := range tc.header {
+				req.Header.Set(key, value)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tc.status {
+				t.Fatalf("status = %d, want %d (body %q)", res.StatusCode, tc.status, body)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			if got := res.Header.Get("Content-Type"); got != tc.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tc.contentType)
+			}
+			if !strings.HasPrefix(res.Header.Get("ETag"), `"`) {
+				t.Errorf("ETag = %q, want a strong ETag", res.Header.Get("ETag"))
+			}
+			if cache := res.Header.Get("Cache-Control"); tc.cacheControl != "" && cache != tc.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", cache, tc.cacheControl)
+			}
+			if vary := res.Header.Get("Vary"); tc.contentType == "image/webp" && vary != "Accept" {
+				t.Errorf("Vary = %q, want Accept", vary)
+			}
+			config, _, err := image.DecodeConfig(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Width != tc.width || config.Height != tc.height {
+				t.Errorf("size = %dx%d, want %dx%d", config.Width, config.Height, tc.width, tc.height)
+			}
+		})
+	}
+}
+
+// upload uploads content in the field `image` of a form to the route of c
+// responding with the ID of the stored file.
+func upload(t *testing.T, c contracts.IServerContainer, filename, contentType string, content []byte) (string, int) {
+	t.Helper()
+	c.Route(http.MethodPost, "/upload", func(server contracts.IServer) error {
+		id, err := server.Upload("image")
+		if err != nil {
+			return err
+		}
+		return server.String(http.StatusOK, id)
+	})
+	ts := c.TestServer()
+	defer ts.Close()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="image"; filename="` + filename + `"`},
+		"Content-Type":        {contentType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	w.Close()
+	res, err := http.Post(ts.URL+"/upload", w.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	id, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(id), res.StatusCode
+}
+
+// original returns the content of an uploaded file.
+func original(t *testing.T, c contracts.IServerContainer, id string) []byte {
+	t.Helper()
+	r, _, err := c.Storage().Get(context.Background(), utils.OriginalKey(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestUploadProcessed(t *testing.T) {
+	c, _ := newContainer(t, "image.upload.max_dimension", "30", "image.variants.lazy", "true")
+	id, status := upload(t, c, "photo.jpg", "image/jpeg", exifJPEG(6))
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	// The photo is rotated by its orientation, from 40x20 to 20x40, and
+	// scaled down to 15x30.
+	content := original(t, c, id)
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || config.Width != 15 || config.Height != 30 {
+		t.Errorf("stored %s of %dx%d, want jpeg of 15x30", format, config.Width, config.Height)
+	}
+	if bytes.Contains(content, []byte("Exif")) {
+		t.Error("stored the EXIF metadata")
+	}
+}
+
+func TestUploadWatermarked(t *testing.T) {
+	c, cfg := newContainer(t, "image.watermark.path", "watermark.png", "image.watermark.opacity", "100",
+		"image.watermark.margin", "1", "image.variants.lazy", "true")
+	mark := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(mark, mark.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, mark); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cfg.StaticDir(), "watermark.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	id, status := upload(t, c, "photo.png", "image/png", pngImage)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	img, err := png.Decode(bytes.NewReader(original(t, c, id)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := func(x, y int) bool {
+		r, g, _, _ := img.At(x, y).RGBA()
+		return r == 0xffff && g == 0
+	}
+	// The watermark is at the bottom right corner.
+	if !red(37, 17) || red(2, 2) {
+		t.Error("the watermark isn't at the bottom right corner")
+	}
+}
+
+func TestUploadVariants(t *testing.T) {
+	c, _ := newContainer(t)
+	id, status := upload(t, c, "photo.png", "image/png", pngImage)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	// Banners and avatars, as PNG and WebP, are rendered in the background.
+	var files []contracts.FileInfo
+	for deadline := time.Now().Add(5 * time.Second); len(files) < 4 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var err error
+		if files, err = c.Storage().List(context.Background(), utils.VariantsPrefix(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(files) != 4 {
+		t.Errorf("rendered %d variants, want 4", len(files))
+	}
+}
+
+func TestUploadTooManyPixels(t *testing.T) {
+	c, _ := newContainer(t, "image.upload.max_pixels", "100")
+	if _, status := upload(t, c, "photo.png", "image/png", pngImage); status != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
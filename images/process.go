@@ -0,0 +1,247 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/gif"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+
+	"github.com/disintegration/imaging"
+)
+
+// Defaults of the configuration parameters of the processing of uploads
+const (
+	DefaultUploadMaxDimension = 4096
+	DefaultUploadQuality      = 90
+	DefaultWatermarkOpacity   = 50
+	DefaultWatermarkScale     = 25
+	DefaultWatermarkMargin    = 16
+	DefaultVariantsWorkers    = 2
+)
+
+// processor is the pipeline of uploaded images.
+type processor struct {
+	config     contracts.IConfigService
+	storage    contracts.IStorage
+	thumbnails contracts.IThumbnailCache
+
+	// workers limits the variants rendered at once, and rendering waits for
+	// the variants in progress.
+	workers   chan struct{}
+	rendering sync.WaitGroup
+
+	watermarkOnce sync.Once
+	watermark     image.Image
+	watermarkErr  error
+}
+
+// NewProcessor creates the IUploadProcessor of images, which Register
+// registers. Uploaded images are:
+//
+//   - rotated by their EXIF orientation, e.g. photos taken by phones held
+//     sideways,
+//   - rejected with ErrFileTooLarge if they have more pixels than
+//     `image.upload.max_pixels` (50 million by default),
+//   - encoded again, which strips their metadata, e.g. the GPS position,
+//   - scaled down to `image.upload.max_dimension` (4096 by default),
+//   - watermarked by the image at `image.watermark.path`, if any, which is
+//     relative to the static directory unless absolute.
+//
+// The watermark is scaled down to `image.watermark.scale` percent of the
+// width of images, and placed at `image.watermark.position`, one of
+// top-left, top-right, bottom-left, bottom-right (default) and center, with
+// `image.watermark.opacity` percent. `image.watermark.fields` lists the form
+// fields watermarked, all by default. Animated GIF and SVG images are kept as
+// they are.
+//
+// The variants of Presets are rendered in the background once images are
+// uploaded, by up to `image.variants.workers` (2 by default) at once, unless
+// `image.variants.lazy` is set. Variants which fail to render are rendered on
+// request instead.
+func NewProcessor(config contracts.IConfigService, storage contracts.IStorage, thumbnails contracts.IThumbnailCache) contracts.IUploadProcessor {
+	return &processor{
+		config:     config,
+		storage:    storage,
+		thumbnails: thumbnails,
+		workers:    make(chan struct{}, intOr(config.Int("image.variants.workers"), DefaultVariantsWorkers)),
+	}
+}
+
+// Process implements IUploadProcessor.Process
+func (p *processor) Process(ctx context.Context, field string, content []byte, contentType string) ([]byte, string, error) {
+	format, ok := lookupFormat(contentType)
+	if !ok {
+		return content, contentType, nil
+	}
+	if contentType == "image/gif" {
+		if err := checkPixels(p.config, content); err != nil {
+			return nil, "", err
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(content))
+		if err != nil {
+			return nil, "", contracts.ErrFileTypeNotAllowed
+		}
+		if len(g.Image) > 1 {
+			return content, contentType, nil
+		}
+	}
+
+	img, err := decode(p.config, content, imaging.AutoOrientation(true))
+	if err == contracts.ErrFileTooLarge {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", contracts.ErrFileTypeNotAllowed
+	}
+	max := intOr(p.config.Int("image.upload.max_dimension"), DefaultUploadMaxDimension)
+	if bounds := img.Bounds(); bounds.Dx() > max || bounds.Dy() > max {
+		img = imaging.Fit(img, max, max, imaging.Lanczos)
+	}
+	if p.watermarked(field) {
+		mark, err := p.loadWatermark()
+		if err != nil {
+			return nil, "", err
+		}
+		img = p.applyWatermark(img, mark)
+	}
+
+	var buf bytes.Buffer
+	quality := intOr(p.config.Int("image.upload.quality"), DefaultUploadQuality)
+	if err := format.Encode(&buf, img, quality); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// Uploaded implements IUploadProcessor.Uploaded
+func (p *processor) Uploaded(ctx context.Context, file *models.File) error {
+	if p.config.Bool("image.variants.lazy") || file.ContentType == "image/svg+xml" {
+		return nil
+	}
+	p.rendering.Add(1)
+	go func() {
+		defer p.rendering.Done()
+		p.workers <- struct{}{}
+		defer func() { <-p.workers }()
+		// The upload request may be over, so its context isn't used.
+		_ = p.renderVariants(context.Background(), file)
+	}()
+	return nil
+}
+
+// renderVariants renders the variants of Presets of an uploaded image.
+func (p *processor) renderVariants(ctx context.Context, file *models.File) error {
+	formats := variantFormats(file.ContentType)
+	for _, preset := range Presets(p.config) {
+		options := defaultOptions(p.config)
+		options.Width, options.Height = preset.Width, preset.Height
+		for _, format := range formats {
+			format, options := format, options
+			key, _, err := variantKey(file.ID, format, options)
+			if err != nil {
+				return err
+			}
+			r, _, err := p.thumbnails.Get(ctx, key, func() ([]byte, error) {
+				return render(ctx, p.config, p.storage, file.ID, format, options)
+			})
+			if err != nil {
+				return err
+			}
+			r.Close()
+		}
+	}
+	return nil
+}
+
+// Deleted implements IDeleteProcessor.Deleted
+func (p *processor) Deleted(ctx context.Context, id string) error {
+	return p.thumbnails.Purge(ctx, id)
+}
+
+// variantFormats returns the formats which the variants of an image of
+// contentType may be served in.
+func variantFormats(contentType string) []Format {
+	var formats []Format
+	if format, ok := lookupFormat(contentType); ok {
+		formats = append(formats, format)
+	}
+	for _, format := range preferredFormats(contentType) {
+		if !containsFormat(formats, format.MediaType) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+func containsFormat(formats []Format, mediaType string) bool {
+	for _, format := range formats {
+		if format.MediaType == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// watermarked reports whether the images uploaded in field are watermarked.
+func (p *processor) watermarked(field string) bool {
+	if p.config.String("image.watermark.path") == "" {
+		return false
+	}
+	fields := p.config.String("image.watermark.fields")
+	if fields == "" {
+		return true
+	}
+	for _, f := range strings.Split(fields, ",") {
+		if strings.TrimSpace(f) == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *processor) loadWatermark() (image.Image, error) {
+	p.watermarkOnce.Do(func() {
+		path := p.config.String("image.watermark.path")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.config.StaticDir(), path)
+		}
+		p.watermark, p.watermarkErr = imaging.Open(path)
+	})
+	return p.watermark, p.watermarkErr
+}
+
+// applyWatermark draws mark over img.
+func (p *processor) applyWatermark(img, mark image.Image) image.Image {
+	bounds := img.Bounds()
+	scale := intOr(p.config.Int("image.watermark.scale"), DefaultWatermarkScale)
+	if width := bounds.Dx() * scale / 100; mark.Bounds().Dx() > width && width > 0 {
+		mark = imaging.Resize(mark, width, 0, imaging.Lanczos)
+	}
+
+	margin := intOr(p.config.Int("image.watermark.margin"), DefaultWatermarkMargin)
+	left, top := margin, margin
+	right := bounds.Dx() - mark.Bounds().Dx() - margin
+	bottom := bounds.Dy() - mark.Bounds().Dy() - margin
+	var position image.Point
+	switch p.config.String("image.watermark.position") {
+	case "top-left":
+		position = image.Pt(left, top)
+	case "top-right":
+		position = image.Pt(right, top)
+	case "bottom-left":
+		position = image.Pt(left, bottom)
+	case "center":
+		position = image.Pt((bounds.Dx()-mark.Bounds().Dx())/2, (bounds.Dy()-mark.Bounds().Dy())/2)
+	default:
+		position = image.Pt(right, bottom)
+	}
+
+	opacity := intOr(p.config.Int("image.watermark.opacity"), DefaultWatermarkOpacity)
+	return imaging.Overlay(img, mark, bounds.Min.Add(position), float64(opacity)/100)
+}
@@ -0,0 +1,146 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/storage"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+// encodeGIF encodes a GIF of frames of 40x20.
+func encodeGIF(t *testing.T, frames int) []byte {
+	t.Helper()
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 40, 20), color.Palette{color.Black, color.White})
+		frame.SetColorIndex(i, 0, 1)
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessGIF(t *testing.T) {
+	cases := []struct {
+		name   string
+		frames int
+		kept   bool
+	}{
+		{name: "Static", frames: 1},
+		{name: "Animated", frames: 3, kept: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("image.upload.max_dimension", "10")
+			p := NewProcessor(cfg, storage.NewMemoryStorage(cfg), nil)
+			content := encodeGIF(t, tc.frames)
+
+			processed, contentType, err := p.Process(context.Background(), "image", content, "image/gif")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType != "image/gif" {
+				t.Errorf("content type = %s, want image/gif", contentType)
+			}
+			if kept := bytes.Equal(processed, content); kept != tc.kept {
+				t.Errorf("kept as it is: %v, want %v", kept, tc.kept)
+			}
+			g, err := gif.DecodeAll(bytes.NewReader(processed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(g.Image) != tc.frames {
+				t.Errorf("%d frames, want %d", len(g.Image), tc.frames)
+			}
+		})
+	}
+
+	p := NewProcessor(config.NewConfigService(), storage.NewMemoryStorage(config.NewConfigService()), nil)
+	if _, _, err := p.Process(context.Background(), "image", []byte("GIF89a"), "image/gif"); err != contracts.ErrFileTypeNotAllowed {
+		t.Errorf("Process of a broken GIF = %v, want %v", err, contracts.ErrFileTypeNotAllowed)
+	}
+}
+
+// blockingCache renders thumbnails once released, recording how many it
+// renders at once.
+type blockingCache struct {
+	contracts.IThumbnailCache
+	release chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	max      int
+	keys     []string
+}
+
+func (c *blockingCache) Get(ctx context.Context, key string, render func() ([]byte, error)) (io.ReadCloser, *contracts.FileInfo, error) {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.max {
+		c.max = c.inFlight
+	}
+	c.keys = append(c.keys, key)
+	c.mu.Unlock()
+
+	<-c.release
+	data, err := render()
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), &contracts.FileInfo{Size: int64(len(data))}, nil
+}
+
+func TestUploadedVariants(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.NewConfigService()
+	cfg.SetString("image.variants.workers", "2")
+	s := storage.NewMemoryStorage(cfg)
+	c := &blockingCache{release: make(chan struct{})}
+	p := NewProcessor(cfg, s, c).(*processor)
+
+	var files []*models.File
+	for i := 0; i < 3; i++ {
+		file, err := utils.StoreFile(ctx, s, "a.gif", bytes.NewReader(encodeGIF(t, i+1)), "image/gif", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	// Uploads don't wait for their variants.
+	for _, file := range files {
+		if err := p.Uploaded(ctx, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(c.release)
+	p.rendering.Wait()
+
+	if c.max > 2 {
+		t.Errorf("rendered %d variants at once, want at most 2", c.max)
+	}
+	// Banners and avatars, as GIF and WebP.
+	if len(c.keys) != len(files)*4 {
+		t.Errorf("rendered %s, want 4 variants of each file", strings.Join(c.keys, ", "))
+	}
+}
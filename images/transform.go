@@ -0,0 +1,100 @@
+package images
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Fit modes of the resized images
+const (
+	// Fill scales the image to cover the size, cropping it around the focal
+	// point.
+	Fill = "fill"
+
+	// Fit scales the image down to fit in the size, keeping all of it.
+	Fit = "fit"
+
+	// Crop cuts the size out of the image around the focal point, without
+	// scaling it.
+	Crop = "crop"
+)
+
+// Options of a resized image
+type Options struct {
+	// Width and Height of the image; either may be zero to keep the aspect
+	// ratio.
+	Width  int
+	Height int
+	Fit    string
+
+	// FocusX and FocusY are the focal point, from 0 to 1 of the width and
+	// height, which fill and crop keep in the image.
+	FocusX float64
+	FocusY float64
+
+	// Quality of lossy formats, from 1 to 100.
+	Quality int
+}
+
+// original reports whether the options keep the size of the image.
+func (o Options) original() bool {
+	return o.Width == 0 && o.Height == 0
+}
+
+// transform resizes img by the options.
+func transform(img image.Image, o Options) image.Image {
+	bounds := img.Bounds()
+	width, height := o.Width, o.Height
+	if width == 0 {
+		width = bounds.Dx()
+	}
+	if height == 0 {
+		height = bounds.Dy()
+	}
+
+	switch {
+	case o.original():
+		return img
+	case o.Fit == Crop:
+		return cropAt(img, width, height, o.FocusX, o.FocusY)
+	case o.Fit == Fit || o.Width == 0 || o.Height == 0:
+		return imaging.Fit(img, width, height, imaging.Lanczos)
+	}
+
+	// The image is scaled to cover the size, then cropped.
+	scale := math.Max(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	img = imaging.Resize(img,
+		int(math.Ceil(float64(bounds.Dx())*scale)),
+		int(math.Ceil(float64(bounds.Dy())*scale)),
+		imaging.Lanczos,
+	)
+	return cropAt(img, width, height, o.FocusX, o.FocusY)
+}
+
+// cropAt cuts width x height out of img, centered on the focal point as far
+// as the image allows.
+func cropAt(img image.Image, width, height int, focusX, focusY float64) image.Image {
+	bounds := img.Bounds()
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	if height > bounds.Dy() {
+		height = bounds.Dy()
+	}
+	x := clamp(int(focusX*float64(bounds.Dx()))-width/2, 0, bounds.Dx()-width)
+	y := clamp(int(focusY*float64(bounds.Dy()))-height/2, 0, bounds.Dy()-height)
+	min := bounds.Min.Add(image.Pt(x, y))
+	return imaging.Crop(img, image.Rectangle{Min: min, Max: min.Add(image.Pt(width, height))})
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,151 @@
+package leviathan
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// exit is called on the second signal, when the drain is cut short.
+var exit = os.Exit
+
+// stopHook is a stop hook with the number of start hooks registered before
+// it. It runs only if all of those started.
+type stopHook struct {
+	hook  contracts.Hook
+	after int
+}
+
+// OnStart implements ILeviathan.OnStart
+func (s *leviathan) OnStart(hook contracts.Hook) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.startHooks = append(s.startHooks, hook)
+}
+
+// OnStop implements ILeviathan.OnStop
+func (s *leviathan) OnStop(hook contracts.Hook) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.stopHooks = append(s.stopHooks, stopHook{hook: hook, after: len(s.startHooks)})
+}
+
+// Run implements ILeviathan.Run
+func (s *leviathan) Run(address string) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go s.watchSignals(signals, cancel, done)
+
+	if err := s.start(ctx); err != nil {
+		s.shutdown()
+		return err
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Server().Run(address)
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+		// The server failed to start or stopped on its own.
+	case <-ctx.Done():
+		s.logger.Info("shutting down")
+	}
+
+	if shutdownErr := s.shutdown(); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// watchSignals cancels the context of Run on the first signal and exits on
+// the second one, without waiting for the drain, until done is closed.
+func (s *leviathan) watchSignals(signals <-chan os.Signal, cancel context.CancelFunc, done <-chan struct{}) {
+	select {
+	case <-signals:
+		cancel()
+	case <-done:
+		return
+	}
+
+	select {
+	case sig := <-signals:
+		s.logger.WithFields(contracts.LogFields{
+			"signal": sig.String(),
+		}).Error("forced exit")
+		exit(1)
+	case <-done:
+	}
+}
+
+// start runs the start hooks in order until one fails, and records how many
+// of them ran successfully.
+func (s *leviathan) start(ctx context.Context) error {
+	s.hooksMu.Lock()
+	hooks := append([]contracts.Hook(nil), s.startHooks...)
+	s.hooksMu.Unlock()
+
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			return err
+		}
+		s.hooksMu.Lock()
+		s.started++
+		s.hooksMu.Unlock()
+	}
+	return nil
+}
+
+// shutdown drains the server and runs the stop hooks of the started
+// components in reverse order, all within the drain timeout. Then it closes
+// the log sinks. It returns the first error but keeps running the remaining
+// hooks.
+func (s *leviathan) shutdown() error {
+	timeout := time.Duration(s.config.Int("server.shutdown_timeout")) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := s.Server().Shutdown(ctx)
+
+	s.hooksMu.Lock()
+	hooks := append([]stopHook(nil), s.stopHooks...)
+	started := s.started
+	s.hooksMu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hooks[i].after > started {
+			continue
+		}
+		if hookErr := hooks[i].hook(ctx); hookErr != nil {
+			s.logger.WithFields(contracts.LogFields{
+				"error": hookErr.Error(),
+			}).Error("stop hook failed")
+			if err == nil {
+				err = hookErr
+			}
+		}
+	}
+
+	if closer, ok := s.logger.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package leviathan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/logger"
+)
+
+// stoppedServer is a server container which was never run.
+type stoppedServer struct {
+	contracts.IServerContainer
+}
+
+func (stoppedServer) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// closingLogger records whether it was closed.
+type closingLogger struct {
+	contracts.ILogger
+	closed bool
+}
+
+func (l *closingLogger) Close() error {
+	l.closed = true
+	return nil
+}
+
+func newTestLeviathan() *leviathan {
+	cfg := config.NewConfigService()
+	return &leviathan{
+		config:          cfg,
+		logger:          &closingLogger{ILogger: logger.NewLogger(cfg)},
+		serverContainer: stoppedServer{},
+	}
+}
+
+func TestShutdownAfterStart(t *testing.T) {
+	failed := errors.New("failed")
+	cases := []struct {
+		name    string
+		failAt  int
+		err     error
+		stopped []string
+	}{
+		{name: "Started", failAt: -1, stopped: []string{"b", "a", "db"}},
+		{name: "FirstFailed", failAt: 0, err: failed, stopped: []string{"db"}},
+		{name: "SecondFailed", failAt: 1, err: failed, stopped: []string{"a", "db"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestLeviathan()
+			var stopped []string
+			stop := func(name string) contracts.Hook {
+				return func(ctx context.Context) error {
+					stopped = append(stopped, name)
+					return nil
+				}
+			}
+			start := func(i int) contracts.Hook {
+				return func(ctx context.Context) error {
+					if i == tc.failAt {
+						return failed
+					}
+					return nil
+				}
+			}
+
+			s.OnStop(stop("db"))
+			s.OnStart(start(0))
+			s.OnStop(stop("a"))
+			s.OnStart(start(1))
+			s.OnStop(stop("b"))
+
+			if err := s.start(context.Background()); err != tc.err {
+				t.Fatalf("start = %v, want %v", err, tc.err)
+			}
+			if err := s.shutdown(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stopped, tc.stopped) {
+				t.Errorf("stopped %v, want %v", stopped, tc.stopped)
+			}
+			if !s.logger.(*closingLogger).closed {
+				t.Error("logger is open after shutdown")
+			}
+		})
+	}
+}
+
+func TestWatchSignals(t *testing.T) {
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	cases := []struct {
+		name    string
+		signals int
+		exit    bool
+	}{
+		{name: "None"},
+		{name: "First", signals: 1},
+		{name: "Second", signals: 2, exit: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestLeviathan()
+			signals := make(chan os.Signal, 2)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan struct{})
+			watched := make(chan struct{})
+			go func() {
+				s.watchSignals(signals, cancel, done)
+				close(watched)
+			}()
+
+			for i := 0; i < tc.signals; i++ {
+				signals <- syscall.SIGTERM
+			}
+			if tc.signals > 0 {
+				<-ctx.Done()
+			}
+			select {
+			case code := <-exited:
+				if !tc.exit || code != 1 {
+					t.Errorf("exited with %d", code)
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tc.exit {
+					t.Error("didn't exit on the second signal")
+				}
+			}
+
+			close(done)
+			<-watched
+			if tc.signals == 0 && ctx.Err() != nil {
+				t.Error("canceled without a signal")
+			}
+		})
+	}
+}
@@ -2,42 +2,79 @@ package logger
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sync"
 
 	"github.com/mostafasolati/leviathan/contracts"
 )
 
 type logger struct {
+	mu  sync.Mutex
+	out io.Writer
 }
 
-// NewLogger creates a new ILogger.
+// NewLogger creates a new ILogger. It writes to the file at `logger.file`,
+// appending to it, or to the standard output if there is none. The file is
+// closed by Close.
 func NewLogger(configService contracts.IConfigService) contracts.ILogger {
-	return &logger{}
+	s := &logger{out: os.Stdout}
+	if path := configService.String("logger.file"); path != "" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			s.WithFields(contracts.LogFields{
+				"error": err.Error(),
+			}).Error("cannot open log file")
+			return s
+		}
+		s.out = file
+	}
+	return s
+}
+
+// Close closes the log file, if any. Entries logged afterwards are written to
+// the standard output.
+func (s *logger) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	closer, ok := s.out.(io.Closer)
+	if !ok || s.out == os.Stdout {
+		return nil
+	}
+	s.out = os.Stdout
+	return closer.Close()
 }
 
 func (s *logger) Trace(message string) {
-	fmt.Println(message)
+	s.print(message)
 }
 
 func (s *logger) Debug(message string) {
-	fmt.Println(message)
+	s.print(message)
 }
 
 func (s *logger) Info(message string) {
-	fmt.Println(message)
+	s.print(message)
 }
 
 func (s *logger) Warn(message string) {
-	fmt.Println(message)
+	s.print(message)
 }
 
 func (s *logger) Error(message string) {
-	fmt.Println(message)
+	s.print(message)
 }
 
 func (s *logger) Fatal(message string) {
-	fmt.Println(message)
+	s.print(message)
 }
 
 func (s *logger) WithFields(fields contracts.LogFields) contracts.ILogger {
 	return s
 }
+
+func (s *logger) print(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintln(s.out, message)
+}
@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+)
+
+func TestLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg := config.NewConfigService()
+	cfg.SetString("logger.file", path)
+
+	l := NewLogger(cfg).(*logger)
+	l.Info("first")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("after close")
+	if err := l.Close(); err != nil {
+		t.Errorf("second Close = %v, want nil", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first\n" {
+		t.Errorf("log file = %q, want %q", data, "first\n")
+	}
+}
@@ -3,27 +3,48 @@
 package leviathan
 
 import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+
 	"github.com/google/wire"
 	"github.com/mostafasolati/leviathan/auth"
 	"github.com/mostafasolati/leviathan/config"
 	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/database"
+	"github.com/mostafasolati/leviathan/health"
+	"github.com/mostafasolati/leviathan/images"
 	"github.com/mostafasolati/leviathan/logger"
+	"github.com/mostafasolati/leviathan/models"
 	"github.com/mostafasolati/leviathan/notification"
+	"github.com/mostafasolati/leviathan/openapi"
+	"github.com/mostafasolati/leviathan/pubsub"
+	"github.com/mostafasolati/leviathan/ratelimit"
 	server "github.com/mostafasolati/leviathan/server"
+	"github.com/mostafasolati/leviathan/storage"
 	"github.com/mostafasolati/leviathan/user"
 )
 
-func Init() contracts.ILeviathan {
+func Init() (contracts.ILeviathan, error) {
 	wire.Build(
 		config.NewConfigService,
-		notification.NewKavenegar,
+		database.NewDatabase,
+		notification.NewNotificationService,
 		logger.NewLogger,
-		server.NewEchoServerContainer,
+		server.NewServerContainer,
+		auth.NewOTPStore,
 		auth.NewAuthService,
+		health.NewHealthService,
+		ratelimit.NewStore,
+		ratelimit.NewRateLimiter,
+		pubsub.NewPubSub,
+		storage.NewStorage,
+		images.NewThumbnailCache,
 		NewLeviathan,
 		user.NewUserService,
 	)
-	return &leviathan{}
+	return &leviathan{}, nil
 }
 
 type leviathan struct {
@@ -32,6 +53,17 @@ type leviathan struct {
 	serverContainer contracts.IServerContainer
 	user            contracts.IUserService
 	auth            contracts.IAuth
+	db              *sql.DB
+	health          contracts.IHealth
+	rateLimiter     contracts.IRateLimiter
+	pubSub          contracts.IPubSub
+	storage         contracts.IStorage
+	thumbnails      contracts.IThumbnailCache
+
+	hooksMu    sync.Mutex
+	startHooks []contracts.Hook
+	stopHooks  []stopHook
+	started    int
 }
 
 func NewLeviathan(
@@ -40,13 +72,72 @@ func NewLeviathan(
 	serverContainer contracts.IServerContainer,
 	userService contracts.IUserService,
 	auth contracts.IAuth,
+	db *sql.DB,
+	healthService contracts.IHealth,
+	otpStore contracts.IOTPStore,
+	notification contracts.INotificationService,
+	rateLimiter contracts.IRateLimiter,
+	pubSub contracts.IPubSub,
+	fileStorage contracts.IStorage,
+	thumbnails contracts.IThumbnailCache,
 ) contracts.ILeviathan {
-	return &leviathan{
-		config: config,
-		logger: logger,
-		user:   userService,
-		auth:   auth,
+	lev := &leviathan{
+		config:          config,
+		logger:          logger,
+		serverContainer: serverContainer,
+		user:            userService,
+		auth:            auth,
+		db:              db,
+		health:          healthService,
+		rateLimiter:     rateLimiter,
+		pubSub:          pubSub,
+		storage:         fileStorage,
+		thumbnails:      thumbnails,
+	}
+
+	healthService.AddCheck("database", db.PingContext)
+	subsystems := map[string]interface{}{
+		"config":    config,
+		"otp_store": otpStore,
+		"sms":       notification,
+	}
+	for name, subsystem := range subsystems {
+		if checker, ok := subsystem.(contracts.IHealthChecker); ok {
+			healthService.AddCheck(name, checker.HealthCheck)
+		}
 	}
+
+	serverContainer.Route(http.MethodGet, "/healthz", healthService.Liveness,
+		contracts.Named("healthz"),
+		contracts.WithTags("health"),
+		contracts.WithoutGlobalRateLimit(),
+		contracts.WithResponse(http.StatusOK, &models.Health{}),
+	)
+	serverContainer.Route(http.MethodGet, "/readyz", healthService.Readiness,
+		contracts.Named("readyz"),
+		contracts.WithTags("health"),
+		contracts.WithoutGlobalRateLimit(),
+		contracts.WithResponse(http.StatusOK, &models.Health{}),
+		contracts.WithResponse(http.StatusServiceUnavailable, &models.Health{}),
+	)
+	serverContainer.Route(http.MethodGet, "/version", healthService.Version,
+		contracts.Named("version"),
+		contracts.WithTags("health"),
+		contracts.WithResponse(http.StatusOK, &models.BuildInfo{}),
+	)
+	openapi.Register(config, serverContainer)
+	images.Register(config, serverContainer, thumbnails)
+	storage.Register(config, serverContainer)
+
+	// The database is registered first, so it's closed last.
+	lev.OnStop(func(ctx context.Context) error {
+		return db.Close()
+	})
+	lev.OnStop(func(ctx context.Context) error {
+		return pubSub.Close()
+	})
+
+	return lev
 }
 
 func (s *leviathan) Auth() contracts.IAuth {
@@ -64,9 +155,33 @@ func (s *leviathan) Config() contracts.IConfigService {
 	return s.config
 }
 
+func (s *leviathan) DB() *sql.DB {
+	return s.db
+}
+
+func (s *leviathan) Health() contracts.IHealth {
+	return s.health
+}
+
+func (s *leviathan) RateLimiter() contracts.IRateLimiter {
+	return s.rateLimiter
+}
+
+func (s *leviathan) PubSub() contracts.IPubSub {
+	return s.pubSub
+}
+
+func (s *leviathan) Storage() contracts.IStorage {
+	return s.storage
+}
+
+func (s *leviathan) Thumbnails() contracts.IThumbnailCache {
+	return s.thumbnails
+}
+
 func (s *leviathan) Server() contracts.IServerContainer {
 	if s.serverContainer == nil {
-		s.serverContainer = server.NewEchoServerContainer(s.config, s.logger)
+		s.serverContainer = server.NewServerContainer(s.config, s.logger, s.rateLimiter, s.storage)
 	}
 	return s.serverContainer
 }
@@ -0,0 +1,255 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is a versioned pair of up and down SQL scripts.
+//
+// Migrations are read from files named `<version>_<name>.up.sql` and
+// `<version>_<name>.down.sql`. The version is usually a timestamp in the
+// `20060102150405` layout, which keeps migrations of different sources in
+// chronological order.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied to the database.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back migrations, keeping track of them in the
+// `schema_migrations` table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+var (
+	fileNameRegex = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+	nameRegex     = regexp.MustCompile(`^\w+$`)
+)
+
+// Load reads migrations from the given sources, e.g. an `os.DirFS` of the
+// application migrations and the embedded migrations of framework packages.
+// Migrations are sorted by their versions.
+func Load(sources ...fs.FS) ([]Migration, error) {
+	byVersion := make(map[int64]*Migration)
+
+	for _, source := range sources {
+		entries, err := fs.ReadDir(source, ".")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			match := fileNameRegex.FindStringSubmatch(entry.Name())
+			if entry.IsDir() || match == nil {
+				continue
+			}
+
+			version, _ := strconv.ParseInt(match[1], 10, 64)
+			content, err := fs.ReadFile(source, entry.Name())
+			if err != nil {
+				return nil, err
+			}
+
+			m, ok := byVersion[version]
+			if !ok {
+				m = &Migration{Version: version, Name: match[2]}
+				byVersion[version] = m
+			} else if m.Name != match[2] {
+				return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, m.Name, match[2])
+			}
+
+			if match[3] == "up" {
+				m.Up = string(content)
+			} else {
+				m.Down = string(content)
+			}
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Create writes an empty pair of migration files for name into dir and
+// returns their paths.
+func Create(dir, name string) (up, down string, err error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if !nameRegex.MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q", name)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+
+	prefix := fmt.Sprintf("%s_%s", time.Now().UTC().Format("20060102150405"), name)
+	up = filepath.Join(dir, prefix+".up.sql")
+	down = filepath.Join(dir, prefix+".down.sql")
+
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+
+	return up, down, nil
+}
+
+// NewMigrator creates a new Migrator.
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// Up applies all pending migrations in order and returns them.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.exec(migration.Up,
+			`INSERT INTO schema_migrations(version, name, applied_at) VALUES($1, $2, $3)`,
+			migration.Version, migration.Name, time.Now(),
+		)
+		if err != nil {
+			return done, fmt.Errorf("cannot apply migration %d_%s: %v", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations and returns them.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.exec(migration.Down,
+			`DELETE FROM schema_migrations WHERE version = $1`,
+			migration.Version,
+		)
+		if err != nil {
+			return done, fmt.Errorf("cannot roll back migration %d_%s: %v", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Status lists all migrations alongside the time they were applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// exec runs a migration script and its bookkeeping query in a transaction.
+func (m *Migrator) exec(script, query string, args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(script) != "" {
+		if _, err := tx.Exec(script); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applied returns the applied migration versions alongside the time they were
+// applied.
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations(
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,234 @@
+package migration
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "one", Up: "CREATE TABLE one", Down: "DROP TABLE one"},
+	{Version: 2, Name: "two", Up: "CREATE TABLE two", Down: "DROP TABLE two"},
+	{Version: 3, Name: "three", Up: "CREATE TABLE three", Down: ""},
+}
+
+var appliedAt = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// expectApplied expects the applied migrations to be read, returning
+// versions.
+func expectApplied(mock sqlmock.Sqlmock, versions ...int64) {
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, version := range versions {
+		rows.AddRow(version, appliedAt)
+	}
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(rows)
+}
+
+func names(migrations []Migration) []string {
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func TestMigratorUp(t *testing.T) {
+	failed := errors.New("failed")
+	cases := []struct {
+		name    string
+		applied []int64
+		fail    string
+		want    []string
+		err     bool
+	}{
+		{name: "All", want: []string{"one", "two", "three"}},
+		{name: "Pending", applied: []int64{1}, want: []string{"two", "three"}},
+		{name: "Gap", applied: []int64{1, 3}, want: []string{"two"}},
+		{name: "None", applied: []int64{1, 2, 3}},
+		{name: "Failed", fail: "CREATE TABLE two", want: []string{"one"}, err: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			expectApplied(mock, tc.applied...)
+			for _, m := range testMigrations {
+				if !contains(tc.want, m.Name) && m.Up != tc.fail {
+					continue
+				}
+				mock.ExpectBegin()
+				if m.Up == tc.fail {
+					mock.ExpectExec(regexp.QuoteMeta(m.Up)).WillReturnError(failed)
+					mock.ExpectRollback()
+					break
+				}
+				mock.ExpectExec(regexp.QuoteMeta(m.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO schema_migrations").
+					WithArgs(m.Version, m.Name, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			done, err := NewMigrator(db, testMigrations).Up()
+			if (err != nil) != tc.err {
+				t.Errorf("Up = %v, want an error: %v", err, tc.err)
+			}
+			if got := names(done); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Up applied %v, want %v", got, tc.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMigratorDown(t *testing.T) {
+	cases := []struct {
+		name    string
+		applied []int64
+		steps   int
+		want    []string
+	}{
+		{name: "Last", applied: []int64{1, 2, 3}, steps: 1, want: []string{"three"}},
+		{name: "Steps", applied: []int64{1, 2, 3}, steps: 2, want: []string{"three", "two"}},
+		{name: "Gap", applied: []int64{1, 3}, steps: 2, want: []string{"three", "one"}},
+		{name: "Beyond", applied: []int64{1}, steps: 5, want: []string{"one"}},
+		{name: "None", steps: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			expectApplied(mock, tc.applied...)
+			for i := len(testMigrations) - 1; i >= 0; i-- {
+				m := testMigrations[i]
+				if !contains(tc.want, m.Name) {
+					continue
+				}
+				mock.ExpectBegin()
+				// Empty scripts aren't run.
+				if m.Down != "" {
+					mock.ExpectExec(regexp.QuoteMeta(m.Down)).WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+				mock.ExpectExec("DELETE FROM schema_migrations").
+					WithArgs(m.Version).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			done, err := NewMigrator(db, testMigrations).Down(tc.steps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(done); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Down rolled back %v, want %v", got, tc.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMigratorStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	expectApplied(mock, 1, 3)
+
+	statuses, err := NewMigrator(db, testMigrations).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != len(testMigrations) {
+		t.Fatalf("Status = %d migrations, want %d", len(statuses), len(testMigrations))
+	}
+	for i, status := range statuses {
+		applied := status.AppliedAt != nil
+		if status.Name != testMigrations[i].Name || applied != (i != 1) {
+			t.Errorf("Status[%d] = %s applied %v", i, status.Name, applied)
+		}
+		if applied && !status.AppliedAt.Equal(appliedAt) {
+			t.Errorf("Status[%d] applied at %v, want %v", i, status.AppliedAt, appliedAt)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	app := fstest.MapFS{
+		"2_two.up.sql":   {Data: []byte("up two")},
+		"2_two.down.sql": {Data: []byte("down two")},
+		"README.md":      {Data: []byte("ignored")},
+	}
+	framework := fstest.MapFS{
+		"1_one.up.sql": {Data: []byte("up one")},
+	}
+
+	migrations, err := Load(app, framework)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "one", Up: "up one"},
+		{Version: 2, Name: "two", Up: "up two", Down: "down two"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("Load = %+v, want %+v", migrations, want)
+	}
+
+	duplicate := fstest.MapFS{"2_other.up.sql": {Data: []byte("up other")}}
+	if _, err := Load(app, duplicate); err == nil {
+		t.Error("Load of a duplicate version succeeded")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	up, down, err := Create(dir, "Add Users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 1 || migrations[0].Name != "add_users" {
+		t.Errorf("Load = %+v, want add_users", migrations)
+	}
+	if filepath.Dir(up) != dir || filepath.Dir(down) != dir {
+		t.Errorf("Create = %s, %s, want files in %s", up, down, dir)
+	}
+
+	if _, _, err := Create(dir, "drop-users"); err == nil {
+		t.Error("Create of an invalid name succeeded")
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
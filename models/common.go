@@ -36,6 +36,22 @@ type Error struct {
 	Code    int    `json:"code"`
 }
 
+// Health is the response of the health endpoints
+type Health struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	GoVersion string `json:"go_version"`
+	Path      string `json:"path"`
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified"`
+}
+
 // Pagination is an object contain pagination data
 // The actual data stores in Data property other properties
 // contain information about the pagination
@@ -49,6 +65,26 @@ type Pagination struct {
 	Data        interface{} `json:"data"`
 }
 
+// Page is a requested page of a list
+type Page struct {
+	Number  int `json:"page"`
+	PerPage int `json:"per_page"`
+}
+
+// Offset returns the number of items before the page
+func (p Page) Offset() int {
+	return (p.Number - 1) * p.PerPage
+}
+
+// CursorPagination is a page of a list which is walked by cursors rather
+// than page numbers, so items added meanwhile don't shift the pages. The
+// cursors are opaque to clients.
+type CursorPagination struct {
+	PerPage    int         `json:"per_page"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Data       interface{} `json:"data"`
+}
+
 // Base contains base properties common between all data models
 type Base struct {
 	ID        int        `json:"id"`
@@ -79,3 +115,15 @@ func (t *Timestamp) MarshalJSON() ([]byte, error) {
 	d := time.Time(*t)
 	return []byte(fmt.Sprintf(`"%d-%02d-%02d"`, d.Year(), d.Month(), d.Day())), nil
 }
+
+// File is the metadata of an uploaded file
+type File struct {
+	// ID is the SHA-256 of the content followed by the extension, so the
+	// same content is stored once.
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	UploaderID  int       `json:"uploader_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
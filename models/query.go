@@ -0,0 +1,343 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Op is a comparison operator of a filter, given as `filter[field][op]`.
+// `filter[field]` compares by Eq.
+type Op string
+
+// Operators of filters. The value of In is a comma-separated list, the
+// value of Null is either "true" or "false", and Like matches values which
+// contain the given value, case-insensitively.
+const (
+	Eq   Op = "eq"
+	Ne   Op = "ne"
+	Lt   Op = "lt"
+	Lte  Op = "lte"
+	Gt   Op = "gt"
+	Gte  Op = "gte"
+	Like Op = "like"
+	In   Op = "in"
+	Null Op = "null"
+)
+
+// FieldType is the type of the values of a field, which filter values are
+// parsed as.
+type FieldType int
+
+// Types of fields. TimeField values are either RFC 3339 timestamps or dates
+// like 2006-01-02.
+const (
+	StringField FieldType = iota
+	IntField
+	FloatField
+	BoolField
+	TimeField
+)
+
+// Field declares what clients may do with a field of a resource.
+type Field struct {
+	// Column is the SQL expression of the field, which defaults to the name
+	// of the field. It's put in queries as is, so it must never come from
+	// clients.
+	Column string
+
+	// Type is the type of the field's values.
+	Type FieldType
+
+	// Ops are the operators the field may be filtered by. The field can't
+	// be filtered by if there is none.
+	Ops []Op
+
+	// Sortable allows sorting by the field.
+	Sortable bool
+
+	// Selectable allows selecting the field by the `fields` parameter.
+	Selectable bool
+}
+
+// Spec is the allowlist of the fields of a resource, by name, which parses
+// the filtering, sorting and field selection parameters of list endpoints,
+// e.g.
+//
+//	?filter[status]=active&filter[age][gte]=18&sort=-created_at,name&fields=id,name
+//
+// into a Query building parameterized SQL fragments from them. Specs are
+// built by NewSpec.
+type Spec struct {
+	Fields map[string]Field
+
+	// Sort is the sort order applied when the `sort` parameter is missing,
+	// e.g. "-created_at,id".
+	Sort string
+
+	// MaxInValues limits the number of values of In filters. It defaults
+	// to 100.
+	MaxInValues int
+}
+
+// NewSpec returns the spec of fields, sorted by sort when the `sort`
+// parameter is missing. It panics if a field allows an operator which isn't
+// one of the Op constants, or Like on values which aren't strings, so
+// invalid specs fail at startup rather than building invalid SQL.
+func NewSpec(fields map[string]Field, sort string) *Spec {
+	s := &Spec{Fields: fields, Sort: sort}
+	for _, name := range s.names() {
+		field := s.Fields[name]
+		for _, op := range field.Ops {
+			if !knownOp(op) {
+				panic(fmt.Sprintf("field %q allows the unknown operator %q", name, op))
+			}
+			if op == Like && field.Type != StringField {
+				panic(fmt.Sprintf("field %q allows %q on values which aren't strings", name, op))
+			}
+		}
+	}
+	return s
+}
+
+// Condition is a parsed filter.
+type Condition struct {
+	Field  string
+	Column string
+	Op     Op
+	Values []interface{}
+}
+
+// Sort is a parsed sort key.
+type Sort struct {
+	Field  string
+	Column string
+	Desc   bool
+}
+
+// Query is the parsed filtering, sorting and field selection of a request.
+type Query struct {
+	Conditions []Condition
+	Sort       []Sort
+
+	// Fields are the names of the selected fields, which are all the
+	// selectable fields if the `fields` parameter is missing.
+	Fields  []string
+	columns []string
+}
+
+// QueryError reports an invalid or disallowed query parameter.
+type QueryError struct {
+	Param  string
+	Reason string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query parameter %s: %s", e.Param, e.Reason)
+}
+
+var filterParam = regexp.MustCompile(`^filter\[([^\[\]]+)\](?:\[([^\[\]]+)\])?$`)
+
+// Parse parses the `filter[...]`, `sort` and `fields` parameters of
+// params, e.g. IServer.QueryParams. It returns a *QueryError if a parameter
+// names a field or an operator the spec doesn't allow, or a value can't be
+// parsed.
+func (s *Spec) Parse(params map[string]string) (*Query, error) {
+	q := &Query{}
+
+	for key, value := range params {
+		if key != "filter" && !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		m := filterParam.FindStringSubmatch(key)
+		if m == nil {
+			return nil, &QueryError{Param: key, Reason: "expected filter[field] or filter[field][op]"}
+		}
+		condition, err := s.condition(m[1], Op(m[2]), value)
+		if err != nil {
+			return nil, &QueryError{Param: key, Reason: err.Error()}
+		}
+		q.Conditions = append(q.Conditions, condition)
+	}
+	// Maps aren't ordered, but the placeholders of the same query should be.
+	sort.Slice(q.Conditions, func(i, j int) bool {
+		a, b := q.Conditions[i], q.Conditions[j]
+		return a.Field < b.Field || a.Field == b.Field && a.Op < b.Op
+	})
+
+	order, ok := params["sort"]
+	if !ok {
+		order = s.Sort
+	}
+	if err := s.parseSort(q, order); err != nil {
+		return nil, &QueryError{Param: "sort", Reason: err.Error()}
+	}
+
+	if err := s.parseFields(q, params["fields"]); err != nil {
+		return nil, &QueryError{Param: "fields", Reason: err.Error()}
+	}
+	return q, nil
+}
+
+func (s *Spec) condition(name string, op Op, value string) (Condition, error) {
+	field, ok := s.Fields[name]
+	if !ok || len(field.Ops) == 0 {
+		return Condition{}, fmt.Errorf("can't filter by %q", name)
+	}
+	if op == "" {
+		op = Eq
+	}
+	if !field.allows(op) || !knownOp(op) {
+		return Condition{}, fmt.Errorf("can't filter %q by %q", name, op)
+	}
+
+	condition := Condition{Field: name, Column: field.column(name), Op: op}
+	switch op {
+	case Null:
+		isNull, err := strconv.ParseBool(value)
+		if err != nil {
+			return Condition{}, fmt.Errorf("expected true or false")
+		}
+		condition.Values = []interface{}{isNull}
+	case Like:
+		if field.Type != StringField {
+			return Condition{}, fmt.Errorf("can't match %q by %q", name, op)
+		}
+		condition.Values = []interface{}{"%" + escapeLike(value) + "%"}
+	case In:
+		values := strings.Split(value, ",")
+		max := s.MaxInValues
+		if max <= 0 {
+			max = 100
+		}
+		if len(values) > max {
+			return Condition{}, fmt.Errorf("more than %d values", max)
+		}
+		for _, v := range values {
+			parsed, err := field.parse(v)
+			if err != nil {
+				return Condition{}, err
+			}
+			condition.Values = append(condition.Values, parsed)
+		}
+	default:
+		parsed, err := field.parse(value)
+		if err != nil {
+			return Condition{}, err
+		}
+		condition.Values = []interface{}{parsed}
+	}
+	return condition, nil
+}
+
+func (s *Spec) parseSort(q *Query, order string) error {
+	for _, key := range strings.Split(order, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		desc := strings.HasPrefix(key, "-")
+		name := strings.TrimPrefix(strings.TrimPrefix(key, "-"), "+")
+		field, ok := s.Fields[name]
+		if !ok || !field.Sortable {
+			return fmt.Errorf("can't sort by %q", name)
+		}
+		q.Sort = append(q.Sort, Sort{Field: name, Column: field.column(name), Desc: desc})
+	}
+	return nil
+}
+
+func (s *Spec) parseFields(q *Query, fields string) error {
+	if fields == "" {
+		for _, name := range s.names() {
+			if field := s.Fields[name]; field.Selectable {
+				q.Fields = append(q.Fields, name)
+				q.columns = append(q.columns, field.column(name))
+			}
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(fields, ",") {
+		name = strings.TrimSpace(name)
+		field, ok := s.Fields[name]
+		if !ok || !field.Selectable {
+			return fmt.Errorf("can't select %q", name)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		q.Fields = append(q.Fields, name)
+		q.columns = append(q.columns, field.column(name))
+	}
+	return nil
+}
+
+// names returns the names of the fields in order.
+func (s *Spec) names() []string {
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f Field) column(name string) string {
+	if f.Column != "" {
+		return f.Column
+	}
+	return name
+}
+
+func (f Field) allows(op Op) bool {
+	for _, allowed := range f.Ops {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Field) parse(value string) (interface{}, error) {
+	switch f.Type {
+	case IntField:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return n, nil
+	case FloatField:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return n, nil
+	case BoolField:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return b, nil
+	case TimeField:
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a time", value)
+		}
+		return t, nil
+	}
+	return value, nil
+}
+
+// escapeLike escapes the wildcards of LIKE patterns in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
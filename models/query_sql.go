@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operators are the SQL operators of the Op constants compared to a value.
+var operators = map[Op]string{
+	Eq:  "=",
+	Ne:  "<>",
+	Lt:  "<",
+	Lte: "<=",
+	Gt:  ">",
+	Gte: ">=",
+}
+
+// knownOp reports whether op is one of the Op constants, which Where builds
+// conditions of.
+func knownOp(op Op) bool {
+	_, ok := operators[op]
+	return ok || op == Like || op == In || op == Null
+}
+
+// Where builds the condition of a WHERE clause matching all the filters of
+// q, with PostgreSQL placeholders numbered after the n placeholders already
+// in the query, e.g.
+//
+//	where, args := q.Where(1)
+//	db.Query(`SELECT ... FROM users WHERE deleted_at IS NULL AND `+where,
+//		append([]interface{}{phone}, args...)...)
+//
+// The condition is `TRUE` if there is no filter. Values are always passed as
+// arguments, and columns come from the spec only.
+func (q *Query) Where(n int) (string, []interface{}) {
+	if len(q.Conditions) == 0 {
+		return "TRUE", nil
+	}
+
+	var conditions []string
+	var args []interface{}
+	placeholder := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", n+len(args))
+	}
+
+	for _, c := range q.Conditions {
+		switch c.Op {
+		case Null:
+			if c.Values[0].(bool) {
+				conditions = append(conditions, c.Column+" IS NULL")
+			} else {
+				conditions = append(conditions, c.Column+" IS NOT NULL")
+			}
+		case Like:
+			conditions = append(conditions, c.Column+" ILIKE "+placeholder(c.Values[0]))
+		case In:
+			placeholders := make([]string, len(c.Values))
+			for i, v := range c.Values {
+				placeholders[i] = placeholder(v)
+			}
+			conditions = append(conditions, c.Column+" IN ("+strings.Join(placeholders, ", ")+")")
+		default:
+			conditions = append(conditions, c.Column+" "+operators[c.Op]+" "+placeholder(c.Values[0]))
+		}
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// OrderBy builds the list of an ORDER BY clause, e.g. `created_at DESC, id
+// ASC`. It's empty if q isn't sorted.
+func (q *Query) OrderBy() string {
+	keys := make([]string, len(q.Sort))
+	for i, s := range q.Sort {
+		keys[i] = s.Column + " ASC"
+		if s.Desc {
+			keys[i] = s.Column + " DESC"
+		}
+	}
+	return strings.Join(keys, ", ")
+}
+
+// Columns builds the list of a SELECT clause from the selected fields.
+func (q *Query) Columns() string {
+	return strings.Join(q.columns, ", ")
+}
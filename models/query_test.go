@@ -0,0 +1,149 @@
+package models_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/models"
+)
+
+var spec = models.NewSpec(map[string]models.Field{
+	"name":       {Ops: []models.Op{models.Eq, models.Like}, Sortable: true, Selectable: true},
+	"age":        {Type: models.IntField, Ops: []models.Op{models.Gte, models.Lt, models.In}, Sortable: true, Selectable: true},
+	"score":      {Type: models.FloatField, Ops: []models.Op{models.Ne}},
+	"active":     {Type: models.BoolField, Ops: []models.Op{models.Eq}, Selectable: true},
+	"created_at": {Column: "users.created_at", Type: models.TimeField, Ops: []models.Op{models.Gt, models.Null}, Sortable: true},
+	"secret":     {},
+}, "-age")
+
+func TestSpecParse(t *testing.T) {
+	day := time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		params  map[string]string
+		where   string
+		args    []interface{}
+		orderBy string
+		columns string
+		err     string
+	}{
+		{
+			name:    "Defaults",
+			params:  map[string]string{},
+			where:   "TRUE",
+			orderBy: "age DESC",
+			columns: "active, age, name",
+		},
+		{
+			name: "Filters",
+			params: map[string]string{
+				"filter[name]":             "bob",
+				"filter[age][gte]":         "18",
+				"filter[age][lt]":          "65",
+				"filter[score][ne]":        "1.5",
+				"filter[created_at][gt]":   "2021-04-01",
+				"filter[active]":           "true",
+				"filter[created_at][null]": "false",
+			},
+			where: "active = $1 AND age >= $2 AND age < $3 AND users.created_at > $4 AND " +
+				"users.created_at IS NOT NULL AND name = $5 AND score <> $6",
+			args:    []interface{}{true, int64(18), int64(65), day, "bob", 1.5},
+			orderBy: "age DESC",
+			columns: "active, age, name",
+		},
+		{
+			name:    "Like",
+			params:  map[string]string{"filter[name][like]": "50%_off"},
+			where:   "name ILIKE $1",
+			args:    []interface{}{`%50\%\_off%`},
+			orderBy: "age DESC",
+			columns: "active, age, name",
+		},
+		{
+			name:    "In",
+			params:  map[string]string{"filter[age][in]": "1,2,3"},
+			where:   "age IN ($1, $2, $3)",
+			args:    []interface{}{int64(1), int64(2), int64(3)},
+			orderBy: "age DESC",
+			columns: "active, age, name",
+		},
+		{
+			name:    "SortAndFields",
+			params:  map[string]string{"sort": "name,-created_at", "fields": "name, age,name"},
+			where:   "TRUE",
+			orderBy: "name ASC, users.created_at DESC",
+			columns: "name, age",
+		},
+		{name: "UnknownField", params: map[string]string{"filter[secret]": "x"}, err: "filter[secret]"},
+		{name: "DisallowedOp", params: map[string]string{"filter[name][gt]": "x"}, err: "filter[name][gt]"},
+		{name: "Malformed", params: map[string]string{"filter[name": "x"}, err: "filter[name"},
+		{name: "NotInt", params: map[string]string{"filter[age][gte]": "old"}, err: "filter[age][gte]"},
+		{name: "NotTime", params: map[string]string{"filter[created_at][gt]": "today"}, err: "filter[created_at][gt]"},
+		{name: "NotBool", params: map[string]string{"filter[created_at][null]": "maybe"}, err: "filter[created_at][null]"},
+		{name: "Unsortable", params: map[string]string{"sort": "score"}, err: "sort"},
+		{name: "Unselectable", params: map[string]string{"fields": "secret"}, err: "fields"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := spec.Parse(tc.params)
+			if tc.err != "" {
+				if e, ok := err.(*models.QueryError); !ok || e.Param != tc.err {
+					t.Fatalf("Parse error = %v, want a *QueryError of %s", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			where, args := q.Where(0)
+			if where != tc.where || !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("Where = %q, %v, want %q, %v", where, args, tc.where, tc.args)
+			}
+			if orderBy := q.OrderBy(); orderBy != tc.orderBy {
+				t.Errorf("OrderBy = %q, want %q", orderBy, tc.orderBy)
+			}
+			if columns := q.Columns(); columns != tc.columns {
+				t.Errorf("Columns = %q, want %q", columns, tc.columns)
+			}
+		})
+	}
+}
+
+func TestQueryWhereNumbering(t *testing.T) {
+	q, err := spec.Parse(map[string]string{"filter[age][in]": "1,2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if where, _ := q.Where(2); where != "age IN ($3, $4)" {
+		t.Errorf("Where = %q, want placeholders after $2", where)
+	}
+}
+
+func TestNewSpecInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		field models.Field
+	}{
+		{name: "UnknownOp", field: models.Field{Ops: []models.Op{"between"}}},
+		{name: "LikeInt", field: models.Field{Type: models.IntField, Ops: []models.Op{models.Like}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("NewSpec didn't panic")
+				}
+			}()
+			models.NewSpec(map[string]models.Field{"field": tc.field}, "")
+		})
+	}
+}
+
+func TestSpecParseUnknownOp(t *testing.T) {
+	// Specs which aren't built by NewSpec still reject unknown operators.
+	literal := &models.Spec{Fields: map[string]models.Field{"name": {Ops: []models.Op{"between"}}}}
+	if _, err := literal.Parse(map[string]string{"filter[name][between]": "a"}); err == nil {
+		t.Error("Parse accepted an unknown operator")
+	}
+}
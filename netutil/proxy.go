@@ -0,0 +1,116 @@
+// Package netutil finds the clients of requests sent through reverse
+// proxies, trusting the forwarding headers of the configured proxies only.
+package netutil
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// DefaultTrustedProxies are the networks of the proxies trusted by
+// `server.trust_proxy` unless `server.trusted_proxies` is set: the loopback
+// and private networks, where reverse proxies usually are.
+var DefaultTrustedProxies = []string{
+	"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7",
+}
+
+// TrustedProxies returns the networks of the reverse proxies whose
+// forwarding headers are trusted: none unless `server.trust_proxy` is set,
+// then the comma-separated IPs and CIDRs of `server.trusted_proxies`, which
+// default to DefaultTrustedProxies. Invalid entries are skipped.
+func TrustedProxies(config contracts.IConfigService) []*net.IPNet {
+	if !config.Bool("server.trust_proxy") {
+		return nil
+	}
+	proxies := DefaultTrustedProxies
+	if value := config.String("server.trusted_proxies"); value != "" {
+		proxies = strings.Split(value, ",")
+	}
+
+	var networks []*net.IPNet
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+			continue
+		}
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// ClientIP returns the IP address of the client sending the request. If it's
+// sent by one of the trusted proxies, `X-Forwarded-For` is walked from the
+// right, skipping the trusted proxies, since any entries left of them are
+// sent by the client and may be forged. `X-Real-IP` is used if there's no
+// `X-Forwarded-For`.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := remoteIP(r)
+	if !trustedIP(trusted, ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	if len(hops) == 0 {
+		if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(real) != nil {
+			return real
+		}
+		return ip
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// A malformed hop can't be trusted to have forwarded the ones on
+			// its left.
+			break
+		}
+		ip = hop
+		if !trustedIP(trusted, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// FromTrustedProxy reports whether a request is sent by one of the trusted
+// proxies, so its forwarding headers, e.g. `X-Forwarded-Proto`, can be
+// trusted.
+func FromTrustedProxy(r *http.Request, trusted []*net.IPNet) bool {
+	return trustedIP(trusted, remoteIP(r))
+}
+
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func trustedIP(trusted []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
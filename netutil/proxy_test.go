@@ -0,0 +1,102 @@
+package netutil
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+)
+
+func TestClientIP(t *testing.T) {
+	cfg := config.NewConfigService()
+	cfg.SetString("server.trust_proxy", "true")
+	trusted := TrustedProxies(cfg)
+
+	cases := []struct {
+		name      string
+		trusted   bool
+		remote    string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{name: "Direct", trusted: true, remote: "203.0.113.9:1234", want: "203.0.113.9"},
+		{name: "NotTrusted", remote: "10.0.0.1:1234", forwarded: []string{"1.2.3.4"}, want: "10.0.0.1"},
+		{name: "UntrustedProxy", trusted: true, remote: "203.0.113.9:1234", forwarded: []string{"1.2.3.4"}, want: "203.0.113.9"},
+		{name: "Forwarded", trusted: true, remote: "10.0.0.1:1234", forwarded: []string{"1.2.3.4"}, want: "1.2.3.4"},
+		{name: "Forged", trusted: true, remote: "10.0.0.1:1234", forwarded: []string{"6.6.6.6, 1.2.3.4"}, want: "1.2.3.4"},
+		{name: "ProxyChain", trusted: true, remote: "10.0.0.1:1234", forwarded: []string{"6.6.6.6, 1.2.3.4, 10.0.0.2"}, want: "1.2.3.4"},
+		{name: "AllTrusted", trusted: true, remote: "10.0.0.1:1234", forwarded: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "HeaderLines", trusted: true, remote: "10.0.0.1:1234", forwarded: []string{"6.6.6.6", "1.2.3.4"}, want: "1.2.3.4"},
+		{name: "Malformed", trusted: true, remote: "10.0.0.1:1234", forwarded: []string{"1.2.3.4, unknown"}, want: "10.0.0.1"},
+		{name: "IPv6", trusted: true, remote: "[::1]:1234", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "RealIP", trusted: true, remote: "10.0.0.1:1234", realIP: "1.2.3.4", want: "1.2.3.4"},
+		{name: "RealIPUntrustedProxy", trusted: true, remote: "203.0.113.9:1234", realIP: "1.2.3.4", want: "203.0.113.9"},
+		{name: "RealIPMalformed", trusted: true, remote: "10.0.0.1:1234", realIP: "unknown", want: "10.0.0.1"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remote
+			for _, value := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-IP", tc.realIP)
+			}
+			proxies := trusted
+			if !tc.trusted {
+				proxies = nil
+			}
+			if got := ClientIP(r, proxies); got != tc.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  map[string]string
+		ip      string
+		trusted bool
+	}{
+		{name: "Disabled", config: map[string]string{}, ip: "127.0.0.1", trusted: false},
+		{name: "Default", config: map[string]string{"server.trust_proxy": "true"}, ip: "172.16.5.4", trusted: true},
+		{name: "DefaultPublic", config: map[string]string{"server.trust_proxy": "true"}, ip: "8.8.8.8", trusted: false},
+		{
+			name:    "IP",
+			config:  map[string]string{"server.trust_proxy": "true", "server.trusted_proxies": "192.0.2.1, junk"},
+			ip:      "192.0.2.1",
+			trusted: true,
+		},
+		{
+			name:    "CIDR",
+			config:  map[string]string{"server.trust_proxy": "true", "server.trusted_proxies": "198.51.100.0/24"},
+			ip:      "198.51.100.77",
+			trusted: true,
+		},
+		{
+			name:    "ReplacesDefault",
+			config:  map[string]string{"server.trust_proxy": "true", "server.trusted_proxies": "198.51.100.0/24"},
+			ip:      "127.0.0.1",
+			trusted: false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			for key, value := range tc.config {
+				cfg.SetString(key, value)
+			}
+			if got := trustedIP(TrustedProxies(cfg), tc.ip); got != tc.trusted {
+				t.Errorf("trusted(%s) = %v, want %v", tc.ip, got, tc.trusted)
+			}
+		})
+	}
+}
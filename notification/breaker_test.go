@@ -0,0 +1,107 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	failure := errors.New("failure")
+
+	// A step waits, asks the breaker to allow a request and, if it's
+	// allowed, ends it by result: "ok", "fail" or "release".
+	type step struct {
+		wait   time.Duration
+		allow  bool
+		result string
+	}
+	cases := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "Closed",
+			steps: []step{{allow: true, result: "ok"}, {allow: true, result: "fail"}, {allow: true, result: "ok"}},
+		},
+		{
+			name: "FailuresResetBySuccess",
+			steps: []step{
+				{allow: true, result: "fail"}, {allow: true, result: "ok"},
+				{allow: true, result: "fail"}, {allow: true, result: "ok"},
+			},
+		},
+		{
+			name:  "Opens",
+			steps: []step{{allow: true, result: "fail"}, {allow: true, result: "fail"}, {allow: false}},
+		},
+		{
+			name:  "ReleaseIsNotCounted",
+			steps: []step{{allow: true, result: "fail"}, {allow: true, result: "release"}, {allow: true, result: "release"}},
+		},
+		{
+			name: "ProbeCloses",
+			steps: []step{
+				{allow: true, result: "fail"}, {allow: true, result: "fail"},
+				{wait: cooldown, allow: true, result: "ok"}, {allow: true, result: "ok"},
+			},
+		},
+		{
+			name: "ProbeFails",
+			steps: []step{
+				{allow: true, result: "fail"}, {allow: true, result: "fail"},
+				{wait: cooldown, allow: true, result: "fail"}, {allow: false},
+			},
+		},
+		{
+			name: "SingleProbe",
+			steps: []step{
+				{allow: true, result: "fail"}, {allow: true, result: "fail"},
+				{wait: cooldown, allow: true}, {allow: false},
+			},
+		},
+		{
+			name: "ReleasedProbeStaysOpen",
+			steps: []step{
+				{allow: true, result: "fail"}, {allow: true, result: "fail"},
+				{wait: cooldown, allow: true, result: "release"}, {allow: true, result: "ok"}, {allow: true},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			b := &breaker{failures: 2, cooldown: cooldown}
+			for i, s := range tc.steps {
+				time.Sleep(s.wait)
+				if got := b.allow(); got != s.allow {
+					t.Fatalf("step %d: allow() = %v, want %v", i, got, s.allow)
+				}
+				switch s.result {
+				case "ok":
+					b.done(nil)
+				case "fail":
+					b.done(failure)
+				case "release":
+					b.release()
+				}
+			}
+		})
+	}
+}
+
+func TestMaskPhone(t *testing.T) {
+	cases := map[string]string{
+		"09121234567":  "*******4567",
+		"+15005550006": "********0006",
+		"1234":         "****",
+		"":             "",
+	}
+	for phone, want := range cases {
+		if got := maskPhone(phone); got != want {
+			t.Errorf("maskPhone(%q) = %q, want %q", phone, got, want)
+		}
+	}
+}
@@ -1,30 +1,118 @@
 package notification
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
 
 	kn "github.com/kavenegar/kavenegar-go"
 	"github.com/mostafasolati/leviathan/contracts"
 )
 
 type kavenegar struct {
+	name   string
 	api    *kn.Kavenegar
-	config contracts.IConfigService
+	apiKey string
+	lines  []string
 }
 
 type ApikeyType string
 
-func NewKavenegar(config contracts.IConfigService) contracts.INotificationService {
+// kavenegarRejections are the statuses of the Kavenegar API refusing the
+// message itself, e.g. 411 for an invalid receptor, rather than failing.
+var kavenegarRejections = map[int]bool{
+	400: true, // missing parameters
+	406: true, // missing parameters
+	411: true, // invalid receptor
+	412: true, // invalid sender
+	413: true, // empty or too long message
+	414: true, // too many receptors
+	422: true, // invalid characters
+}
+
+// NewKavenegar creates a provider sending messages through Kavenegar, by the
+// API key of `notification.sms.<name>.api_key`, or `notification.sms.api_key`
+// for the provider named kavenegar. Its API is requested at
+// `notification.sms.<name>.url` if configured, e.g. in tests.
+func NewKavenegar(config contracts.IConfigService, name string) (contracts.ISMSProvider, error) {
+	apiKey := config.String("notification.sms." + name + ".api_key")
+	if apiKey == "" && name == "kavenegar" {
+		apiKey = config.String("notification.sms.api_key")
+	}
+
+	client := kn.NewClient(apiKey)
+	client.BaseClient = httpClient(config)
+	if u := config.String("notification.sms." + name + ".url"); u != "" {
+		baseURL, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		client.BaseURL = baseURL
+	}
 
 	return &kavenegar{
-		api:    kn.New(config.String("notification.sms.api_key")),
-		config: config,
+		name:   name,
+		api:    kn.NewWithClient(client),
+		apiKey: apiKey,
+		lines:  lines(config, name),
+	}, nil
+}
+
+// Name implements ISMSProvider.Name
+func (k *kavenegar) Name() string {
+	return k.name
+}
+
+// Send implements ISMSProvider.Send
+func (k *kavenegar) Send(ctx context.Context, sender, phone, message string) error {
+	// MessageService.Send leaves the sender out, so the request is built
+	// here.
+	v := url.Values{}
+	v.Set("receptor", phone)
+	v.Set("message", message)
+	if sender != "" {
+		v.Set("sender", sender)
+	}
+	_, err := k.api.Message.CreateSend(v)
+	return kavenegarError(err)
+}
+
+// Lookup implements ISMSLookupProvider.Lookup by VerifyLookup, filling in
+// the `%token`, `%token2` and `%token3` of the template.
+func (k *kavenegar) Lookup(ctx context.Context, phone, template string, tokens ...string) error {
+	if len(tokens) == 0 || len(tokens) > 3 {
+		return fmt.Errorf("kavenegar templates take 1 to 3 tokens, not %d", len(tokens))
+	}
+	v := url.Values{}
+	v.Set("receptor", phone)
+	v.Set("template", template)
+	v.Set("token", tokens[0])
+	for i, token := range tokens[1:] {
+		v.Set("token"+strconv.Itoa(i+2), token)
+	}
+	_, err := k.api.Verify.CreateLookup(v)
+	return kavenegarError(err)
+}
+
+// kavenegarError wraps ErrSMSRejected into the errors of rejected messages.
+func kavenegarError(err error) error {
+	var e *kn.APIError
+	if errors.As(err, &e) && kavenegarRejections[e.Status] {
+		return fmt.Errorf("%w: %v", contracts.ErrSMSRejected, err)
 	}
+	return err
 }
 
+// Receive returns the messages received on the first line, by their
+// senders.
 func (k *kavenegar) Receive() (map[string]string, error) {
+	if len(k.lines) == 0 {
+		return nil, errors.New("kavenegar line is not configured")
+	}
 	ret := make(map[string]string, 0)
-	messages, err := k.api.Message.Receive(k.config.String("notification.sms.phone"), true)
+	messages, err := k.api.Message.Receive(k.lines[0], true)
 	if err != nil {
 		return nil, err
 	}
@@ -35,10 +123,10 @@ func (k *kavenegar) Receive() (map[string]string, error) {
 	return ret, nil
 }
 
-func (k *kavenegar) SendSMS(phone string, message string) error {
-	_, err := k.api.Message.Send("1000596446", []string{phone}, message, nil)
-	if err != nil {
-		fmt.Println("KAVENEGAR ERR:", phone, err)
+// HealthCheck implements IHealthChecker.HealthCheck
+func (k *kavenegar) HealthCheck(ctx context.Context) error {
+	if k.apiKey == "" {
+		return errors.New("kavenegar api key is not configured")
 	}
-	return err
+	return nil
 }
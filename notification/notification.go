@@ -0,0 +1,289 @@
+// Package notification sends text messages through a list of SMS providers,
+// failing over to the next one when a provider fails.
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// Defaults of `notification.sms.timeout` and
+// `notification.sms.breaker.cooldown`, in seconds, and of
+// `notification.sms.breaker.failures`.
+const (
+	DefaultTimeout         = 10
+	DefaultBreakerCooldown = 60
+	DefaultBreakerFailures = 3
+)
+
+// ProviderFactory creates the provider configured under
+// `notification.sms.<name>`.
+type ProviderFactory func(config contracts.IConfigService, name string) (contracts.ISMSProvider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]ProviderFactory{
+		"kavenegar": NewKavenegar,
+		"twilio":    NewTwilio,
+		"webhook":   NewWebhook,
+	}
+)
+
+// RegisterProvider adds a type of provider, which providers are configured
+// as by `notification.sms.<name>.type`, replacing any of the same type.
+func RegisterProvider(typ string, factory ProviderFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[typ] = factory
+}
+
+type notificationService struct {
+	config    contracts.IConfigService
+	logger    contracts.ILogger
+	providers []*provider
+}
+
+// provider is a configured provider and its circuit breaker.
+type provider struct {
+	contracts.ISMSProvider
+	lines   []string
+	next    uint32
+	timeout time.Duration
+	breaker *breaker
+}
+
+// NewNotificationService creates the providers listed in order of failover
+// by `notification.sms.providers`, which defaults to kavenegar. A provider
+// is of the type of its name, e.g. "twilio", unless configured by
+// `notification.sms.<name>.type`, and sends messages from the lines of
+// `notification.sms.<name>.lines` by turns.
+//
+// Each request to a provider times out after `notification.sms.timeout`
+// seconds. A provider failing `notification.sms.breaker.failures` times in
+// a row is skipped for `notification.sms.breaker.cooldown` seconds, then
+// tried with a single message before it's used again. Messages which a
+// provider rejects, e.g. for an invalid phone, aren't counted as failures
+// nor sent through the other providers.
+func NewNotificationService(config contracts.IConfigService, logger contracts.ILogger) (contracts.INotificationService, error) {
+	names := splitList(config.String("notification.sms.providers"))
+	if len(names) == 0 {
+		names = []string{"kavenegar"}
+	}
+
+	timeout := seconds(config, "notification.sms.timeout", DefaultTimeout)
+	cooldown := seconds(config, "notification.sms.breaker.cooldown", DefaultBreakerCooldown)
+	failures := config.Int("notification.sms.breaker.failures")
+	if failures <= 0 {
+		failures = DefaultBreakerFailures
+	}
+
+	s := &notificationService{config: config, logger: logger}
+	for _, name := range names {
+		typ := config.String("notification.sms." + name + ".type")
+		if typ == "" {
+			typ = name
+		}
+		factoriesMu.RLock()
+		factory, ok := factories[typ]
+		factoriesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown sms provider type %q of %s", typ, name)
+		}
+
+		p, err := factory(config, name)
+		if err != nil {
+			return nil, err
+		}
+		s.providers = append(s.providers, &provider{
+			ISMSProvider: p,
+			lines:        lines(config, name),
+			timeout:      timeout,
+			breaker:      &breaker{failures: failures, cooldown: cooldown},
+		})
+	}
+	return s, nil
+}
+
+// SendSMS sends message to phone through the first provider which succeeds.
+func (s *notificationService) SendSMS(phone, message string) error {
+	return s.send(phone, func(ctx context.Context, p *provider) error {
+		return p.Send(ctx, p.line(), phone, message)
+	})
+}
+
+// send tries sending a message to phone through each provider in order
+// until one succeeds or rejects it, skipping the providers whose breaker is
+// open.
+func (s *notificationService) send(phone string, send func(ctx context.Context, p *provider) error) error {
+	var failures []string
+	for _, p := range s.providers {
+		if !p.breaker.allow() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		err := send(ctx, p)
+		cancel()
+		if errors.Is(err, contracts.ErrSMSRejected) {
+			p.breaker.release()
+			s.logger.WithFields(contracts.LogFields{
+				"provider": p.Name(),
+				"phone":    maskPhone(phone),
+				"error":    err.Error(),
+			}).Info("sms is rejected")
+			return err
+		}
+		p.breaker.done(err)
+		if err == nil {
+			return nil
+		}
+
+		s.logger.WithFields(contracts.LogFields{
+			"provider": p.Name(),
+			"phone":    maskPhone(phone),
+			"error":    err.Error(),
+		}).Warn("cannot send sms")
+		failures = append(failures, p.Name()+": "+err.Error())
+	}
+
+	if len(failures) == 0 {
+		return contracts.ErrSMSUnavailable
+	}
+	return fmt.Errorf("%w: %s", contracts.ErrSMSUnavailable, strings.Join(failures, "; "))
+}
+
+// HealthCheck implements IHealthChecker.HealthCheck, failing unless any of
+// the providers is configured and not skipped by its circuit breaker.
+func (s *notificationService) HealthCheck(ctx context.Context) error {
+	var failures []string
+	for _, p := range s.providers {
+		if p.breaker.open() {
+			failures = append(failures, p.Name()+": too many failures")
+			continue
+		}
+		if checker, ok := p.ISMSProvider.(contracts.IHealthChecker); ok {
+			if err := checker.HealthCheck(ctx); err != nil {
+				failures = append(failures, p.Name()+": "+err.Error())
+				continue
+			}
+		}
+		return nil
+	}
+	return errors.New("no sms provider is available: " + strings.Join(failures, "; "))
+}
+
+// line returns the next sender line of the provider.
+func (p *provider) line() string {
+	if len(p.lines) == 0 {
+		return ""
+	}
+	i := atomic.AddUint32(&p.next, 1) - 1
+	return p.lines[i%uint32(len(p.lines))]
+}
+
+// breaker is the circuit breaker of a provider, which opens after a number
+// of failures in a row, and lets a single request through once it has
+// cooled down, closing if it succeeds.
+type breaker struct {
+	failures int
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	failed   int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a request may be sent.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failed < b.failures {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// done records the result of an allowed request.
+func (b *breaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil {
+		b.failed = 0
+		return
+	}
+	b.failed++
+	if b.failed >= b.failures {
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends an allowed request without recording its result, e.g. when
+// the message was rejected.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// open reports whether requests are being skipped.
+func (b *breaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failed >= b.failures && time.Since(b.openedAt) < b.cooldown
+}
+
+// maskPhone hides all but the last 4 digits of a phone number, for logs.
+func maskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
+
+// lines returns the sender lines of the provider name.
+func lines(config contracts.IConfigService, name string) []string {
+	lines := splitList(config.String("notification.sms." + name + ".lines"))
+	if len(lines) == 0 && name == "kavenegar" {
+		// The line which messages used to be received on.
+		lines = splitList(config.String("notification.sms.phone"))
+	}
+	return lines
+}
+
+// httpClient returns the client of the requests to the providers.
+func httpClient(config contracts.IConfigService) *http.Client {
+	return &http.Client{Timeout: seconds(config, "notification.sms.timeout", DefaultTimeout)}
+}
+
+func seconds(config contracts.IConfigService, key string, fallback int) time.Duration {
+	n := config.Int(key)
+	if n <= 0 {
+		n = fallback
+	}
+	return time.Duration(n) * time.Second
+}
+
+// splitList splits a comma-separated configuration parameter.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
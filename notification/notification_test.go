@@ -0,0 +1,412 @@
+package notification_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/logger"
+	"github.com/mostafasolati/leviathan/notification"
+	"github.com/mostafasolati/leviathan/notification/smstest"
+)
+
+// providers are the stand-ins of a test case, configured as kavenegar,
+// twilio and webhook.
+type providers struct {
+	kavenegar *smstest.Server
+	twilio    *smstest.Server
+	webhook   *smstest.Server
+}
+
+// TestService checks sending through each provider, failover, circuit
+// breaking, rejected messages and the templates of OTP messages.
+func TestService(t *testing.T) {
+	cases := []struct {
+		name   string
+		config map[string]string
+		test   func(t *testing.T, s contracts.INotificationService, p *providers)
+	}{
+		{
+			name: "Kavenegar",
+			config: map[string]string{
+				"notification.sms.providers":       "kavenegar",
+				"notification.sms.kavenegar.lines": "10001, 10002",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				for _, text := range []string{"one", "two", "three"} {
+					send(t, s, text)
+				}
+				// The lines send by turns.
+				expectMessages(t, p.kavenegar,
+					smstest.Message{Sender: "10001", Phone: "09120000000", Text: "one"},
+					smstest.Message{Sender: "10002", Phone: "09120000000", Text: "two"},
+					smstest.Message{Sender: "10001", Phone: "09120000000", Text: "three"},
+				)
+			},
+		},
+		{
+			name: "KavenegarLegacyConfig",
+			config: map[string]string{
+				"notification.sms.kavenegar.api_key": "",
+				"notification.sms.api_key":           "kavenegar-key",
+				"notification.sms.phone":             "10003",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				send(t, s, "hello")
+				expectMessages(t, p.kavenegar, smstest.Message{Sender: "10003", Phone: "09120000000", Text: "hello"})
+			},
+		},
+		{
+			name: "Twilio",
+			config: map[string]string{
+				"notification.sms.providers":    "twilio",
+				"notification.sms.twilio.lines": "+15005550006",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				send(t, s, "hello")
+				expectMessages(t, p.twilio, smstest.Message{Sender: "+15005550006", Phone: "09120000000", Text: "hello"})
+			},
+		},
+		{
+			name: "Webhook",
+			config: map[string]string{
+				"notification.sms.providers": "webhook",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				send(t, s, "hello")
+				expectMessages(t, p.webhook, smstest.Message{Phone: "09120000000", Text: "hello"})
+			},
+		},
+		{
+			name: "WebhookUnsigned",
+			config: map[string]string{
+				"notification.sms.providers":      "webhook",
+				"notification.sms.webhook.secret": "",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				if err := s.SendSMS("09120000000", "hello"); err == nil {
+					t.Error("sent unsigned webhook, want it rejected")
+				}
+			},
+		},
+		{
+			name: "ProviderType",
+			config: map[string]string{
+				"notification.sms.providers":    "backup",
+				"notification.sms.backup.type":  "webhook",
+				"notification.sms.backup.lines": "backup-line",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				send(t, s, "hello")
+				expectMessages(t, p.webhook, smstest.Message{Sender: "backup-line", Phone: "09120000000", Text: "hello"})
+			},
+		},
+		{
+			name: "Failover",
+			config: map[string]string{
+				"notification.sms.providers": "kavenegar,twilio,webhook",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				p.kavenegar.Fail(true)
+				send(t, s, "hello")
+				expectMessages(t, p.kavenegar)
+				expectMessages(t, p.twilio, smstest.Message{Phone: "09120000000", Text: "hello"})
+				expectMessages(t, p.webhook)
+			},
+		},
+		{
+			name: "CircuitBreaker",
+			config: map[string]string{
+				"notification.sms.providers":        "kavenegar,twilio",
+				"notification.sms.breaker.failures": "2",
+				"notification.sms.breaker.cooldown": "1",
+				"notification.sms.kavenegar.lines":  "10001",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				p.kavenegar.Fail(true)
+				for i := 0; i < 4; i++ {
+					send(t, s, "hello")
+				}
+				if n := p.kavenegar.Requests(); n != 2 {
+					t.Errorf("kavenegar got %d requests, want 2 before its breaker opens", n)
+				}
+				if n := len(p.twilio.Messages()); n != 4 {
+					t.Errorf("twilio sent %d messages, want 4", n)
+				}
+				if err := healthCheck(s); err != nil {
+					t.Errorf("HealthCheck() = %v, want twilio available", err)
+				}
+
+				// Once cooled down, a message is tried, closing the breaker.
+				p.kavenegar.Fail(false)
+				time.Sleep(1100 * time.Millisecond)
+				send(t, s, "recovered")
+				expectMessages(t, p.kavenegar, smstest.Message{Sender: "10001", Phone: "09120000000", Text: "recovered"})
+			},
+		},
+		{
+			name: "Unavailable",
+			config: map[string]string{
+				"notification.sms.providers":        "kavenegar,webhook",
+				"notification.sms.breaker.failures": "1",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				p.kavenegar.Fail(true)
+				p.webhook.Fail(true)
+				if err := s.SendSMS("09120000000", "hello"); !errors.Is(err, contracts.ErrSMSUnavailable) {
+					t.Errorf("SendSMS() = %v, want ErrSMSUnavailable", err)
+				}
+				if err := s.SendSMS("09120000000", "hello"); err != contracts.ErrSMSUnavailable {
+					t.Errorf("SendSMS() = %v, want ErrSMSUnavailable with the breakers open", err)
+				}
+				if n := p.kavenegar.Requests() + p.webhook.Requests(); n != 2 {
+					t.Errorf("providers got %d requests, want 2", n)
+				}
+				if err := healthCheck(s); err == nil {
+					t.Error("HealthCheck() = nil, want an error")
+				}
+			},
+		},
+		{
+			name: "Rejected",
+			config: map[string]string{
+				"notification.sms.providers":        "kavenegar,twilio,webhook",
+				"notification.sms.breaker.failures": "1",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				// A rejected message isn't sent through the other providers,
+				// nor opens the breaker.
+				for i := 0; i < 2; i++ {
+					if err := s.SendSMS("not-a-phone", "hello"); !errors.Is(err, contracts.ErrSMSRejected) {
+						t.Errorf("SendSMS() = %v, want ErrSMSRejected", err)
+					}
+				}
+				if n := p.twilio.Requests() + p.webhook.Requests(); n != 0 {
+					t.Errorf("other providers got %d requests, want 0", n)
+				}
+				send(t, s, "hello")
+				expectMessages(t, p.kavenegar, smstest.Message{Phone: "09120000000", Text: "hello"})
+			},
+		},
+		{
+			name: "RejectedLookup",
+			config: map[string]string{
+				"notification.sms.providers": "kavenegar,webhook",
+				"notification.otp.lookup":    "verify",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				if err := s.SendOTP("not-a-phone", "1234", "web", "en"); !errors.Is(err, contracts.ErrSMSRejected) {
+					t.Errorf("SendOTP() = %v, want ErrSMSRejected", err)
+				}
+				if n := p.webhook.Requests(); n != 0 {
+					t.Errorf("webhook got %d requests, want 0", n)
+				}
+			},
+		},
+		{
+			name: "RejectedByTwilio",
+			config: map[string]string{
+				"notification.sms.providers": "twilio,webhook",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				if err := s.SendSMS("not-a-phone", "hello"); !errors.Is(err, contracts.ErrSMSRejected) {
+					t.Errorf("SendSMS() = %v, want ErrSMSRejected", err)
+				}
+				if n := p.webhook.Requests(); n != 0 {
+					t.Errorf("webhook got %d requests, want 0", n)
+				}
+			},
+		},
+		{
+			name: "RejectedByWebhook",
+			config: map[string]string{
+				"notification.sms.providers": "webhook,twilio",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				if err := s.SendSMS("not-a-phone", "hello"); !errors.Is(err, contracts.ErrSMSRejected) {
+					t.Errorf("SendSMS() = %v, want ErrSMSRejected", err)
+				}
+				if n := p.twilio.Requests(); n != 0 {
+					t.Errorf("twilio got %d requests, want 0", n)
+				}
+			},
+		},
+		{
+			name: "OTP",
+			config: map[string]string{
+				"notification.sms.providers": "kavenegar",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				sendOTP(t, s, "web", "")
+				sendOTP(t, s, "web", "en")
+				expectMessages(t, p.kavenegar,
+					smstest.Message{Phone: "09120000000", Text: "کد تایید شما: 1234"},
+					smstest.Message{Phone: "09120000000", Text: "Your verification code is 1234"},
+				)
+			},
+		},
+		{
+			name: "OTPTemplates",
+			config: map[string]string{
+				"notification.sms.providers":           "kavenegar",
+				"notification.otp.locale":              "en",
+				"notification.otp.en.template":         "Code: {{.Code}}",
+				"notification.otp.en.android.template": "{{.App}} code: {{.Code}}",
+				"notification.otp.mobile-web.template": "Mobile code: {{.Code}}",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				sendOTP(t, s, "web", "")
+				sendOTP(t, s, "android", "en")
+				sendOTP(t, s, "mobile-web", "de")
+				expectMessages(t, p.kavenegar,
+					smstest.Message{Phone: "09120000000", Text: "Code: 1234"},
+					smstest.Message{Phone: "09120000000", Text: "android code: 1234"},
+					smstest.Message{Phone: "09120000000", Text: "Mobile code: 1234"},
+				)
+			},
+		},
+		{
+			name: "OTPAppHash",
+			config: map[string]string{
+				"notification.sms.providers":        "kavenegar",
+				"notification.otp.android.app_hash": "FA+9qCX9VSu",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				sendOTP(t, s, "android", "en")
+				sendOTP(t, s, "web", "en")
+				expectMessages(t, p.kavenegar,
+					smstest.Message{Phone: "09120000000", Text: "Your verification code is 1234\nFA+9qCX9VSu"},
+					smstest.Message{Phone: "09120000000", Text: "Your verification code is 1234"},
+				)
+			},
+		},
+		{
+			name: "OTPLookup",
+			config: map[string]string{
+				"notification.sms.providers":        "kavenegar",
+				"notification.otp.lookup":           "verify",
+				"notification.otp.android.app_hash": "FA+9qCX9VSu",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				sendOTP(t, s, "android", "")
+				sendOTP(t, s, "web", "")
+				expectMessages(t, p.kavenegar,
+					smstest.Message{Phone: "09120000000", Template: "verify", Tokens: []string{"1234", "FA+9qCX9VSu"}},
+					smstest.Message{Phone: "09120000000", Template: "verify", Tokens: []string{"1234"}},
+				)
+			},
+		},
+		{
+			name: "OTPLookupFailover",
+			config: map[string]string{
+				"notification.sms.providers": "kavenegar,webhook",
+				"notification.otp.lookup":    "verify",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				p.kavenegar.Fail(true)
+				sendOTP(t, s, "web", "en")
+				expectMessages(t, p.webhook, smstest.Message{Phone: "09120000000", Text: "Your verification code is 1234"})
+			},
+		},
+		{
+			name: "OTPInvalidTemplate",
+			config: map[string]string{
+				"notification.sms.providers": "kavenegar",
+				"notification.otp.template":  "{{.Code",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				if err := s.SendOTP("09120000000", "1234", "web", "en"); err == nil {
+					t.Error("sent by an invalid template, want an error")
+				}
+				expectMessages(t, p.kavenegar)
+			},
+		},
+		{
+			name: "InvalidAPIKey",
+			config: map[string]string{
+				"notification.sms.providers":         "kavenegar",
+				"notification.sms.kavenegar.api_key": "wrong-key",
+			},
+			test: func(t *testing.T, s contracts.INotificationService, p *providers) {
+				if err := s.SendSMS("09120000000", "hello"); err == nil {
+					t.Error("sent by a wrong api key, want an error")
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			p := &providers{
+				kavenegar: smstest.NewKavenegarServer("kavenegar-key"),
+				twilio:    smstest.NewTwilioServer("AC123", "twilio-token"),
+				webhook:   smstest.NewWebhookServer("webhook-secret"),
+			}
+			defer p.kavenegar.Close()
+			defer p.twilio.Close()
+			defer p.webhook.Close()
+
+			cfg := config.NewConfigService()
+			for key, value := range map[string]string{
+				"notification.sms.kavenegar.url":      p.kavenegar.URL,
+				"notification.sms.kavenegar.api_key":  "kavenegar-key",
+				"notification.sms.twilio.url":         p.twilio.URL,
+				"notification.sms.twilio.account_sid": "AC123",
+				"notification.sms.twilio.auth_token":  "twilio-token",
+				"notification.sms.webhook.url":        p.webhook.URL,
+				"notification.sms.webhook.secret":     "webhook-secret",
+				"notification.sms.backup.url":         p.webhook.URL,
+				"notification.sms.backup.secret":      "webhook-secret",
+			} {
+				cfg.SetString(key, value)
+			}
+			for key, value := range tc.config {
+				cfg.SetString(key, value)
+			}
+
+			s, err := notification.NewNotificationService(cfg, logger.NewLogger(cfg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.test(t, s, p)
+		})
+	}
+
+	t.Run("UnknownProvider", func(t *testing.T) {
+		cfg := config.NewConfigService()
+		cfg.SetString("notification.sms.providers", "kavenegar,pigeon")
+		if _, err := notification.NewNotificationService(cfg, logger.NewLogger(cfg)); err == nil {
+			t.Error("created an unknown provider, want an error")
+		}
+	})
+}
+
+func send(t *testing.T, s contracts.INotificationService, text string) {
+	t.Helper()
+	if err := s.SendSMS("09120000000", text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sendOTP(t *testing.T, s contracts.INotificationService, app, locale string) {
+	t.Helper()
+	if err := s.SendOTP("09120000000", "1234", app, locale); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func healthCheck(s contracts.INotificationService) error {
+	return s.(contracts.IHealthChecker).HealthCheck(context.Background())
+}
+
+func expectMessages(t *testing.T, s *smstest.Server, want ...smstest.Message) {
+	t.Helper()
+	if got := s.Messages(); !reflect.DeepEqual(got, want) && (len(got) != 0 || len(want) != 0) {
+		t.Errorf("messages = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"text/template"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// DefaultLocale is the locale of the OTP messages unless configured by
+// `notification.otp.locale`.
+const DefaultLocale = "fa"
+
+// defaultOTPTemplates are the templates of the OTP messages of the locales
+// having no `template` configured.
+var defaultOTPTemplates = map[string]string{
+	"en": "Your verification code is {{.Code}}",
+	"fa": "کد تایید شما: {{.Code}}",
+}
+
+// OTPData is the data which the templates of the OTP messages are executed
+// with.
+type OTPData struct {
+	Code    string
+	App     string
+	Locale  string
+	AppHash string
+}
+
+// SendOTP sends a one-time code to phone, by the settings of the app and the
+// locale, each being the most specific of `notification.otp.<locale>.<app>`,
+// `notification.otp.<locale>`, `notification.otp.<app>` and
+// `notification.otp`, e.g. `notification.otp.fa.android.template`:
+//
+//   - template is the text/template of the message, executed with OTPData.
+//   - lookup is the name of a template defined on the providers supporting
+//     them, e.g. Kavenegar's VerifyLookup, which is filled in by the code as
+//     the first token and the app hash, if any, as the second one. The
+//     other providers send the message of template instead.
+//   - app_hash is the hash of an Android app, which the message ends with
+//     so the SMS Retriever API reads the code to the app.
+func (s *notificationService) SendOTP(phone, code, app, locale string) error {
+	if locale == "" {
+		locale = s.config.String("notification.otp.locale")
+	}
+	if locale == "" {
+		locale = DefaultLocale
+	}
+
+	data := &OTPData{
+		Code:    code,
+		App:     app,
+		Locale:  locale,
+		AppHash: s.otpSetting(app, locale, "app_hash"),
+	}
+	message, err := s.otpMessage(data)
+	if err != nil {
+		return err
+	}
+
+	lookup := s.otpSetting(app, locale, "lookup")
+	tokens := []string{code}
+	if data.AppHash != "" {
+		tokens = append(tokens, data.AppHash)
+	}
+	return s.send(phone, func(ctx context.Context, p *provider) error {
+		if l, ok := p.ISMSProvider.(contracts.ISMSLookupProvider); ok && lookup != "" {
+			return l.Lookup(ctx, phone, lookup, tokens...)
+		}
+		return p.Send(ctx, p.line(), phone, message)
+	})
+}
+
+// otpMessage executes the template of an OTP message, ending it with the app
+// hash unless the template does.
+func (s *notificationService) otpMessage(data *OTPData) (string, error) {
+	text := s.otpSetting(data.App, data.Locale, "template")
+	if text == "" {
+		text = defaultOTPTemplates[data.Locale]
+	}
+	if text == "" {
+		text = defaultOTPTemplates["en"]
+	}
+
+	t, err := template.New("otp").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	message := strings.TrimSpace(buf.String())
+	if data.AppHash != "" && !strings.HasSuffix(message, data.AppHash) {
+		message += "\n" + data.AppHash
+	}
+	return message, nil
+}
+
+// otpSetting returns the most specific OTP setting of the app and the
+// locale.
+func (s *notificationService) otpSetting(app, locale, key string) string {
+	var prefixes []string
+	if app != "" {
+		prefixes = append(prefixes, locale+"."+app+".")
+	}
+	prefixes = append(prefixes, locale+".")
+	if app != "" {
+		prefixes = append(prefixes, app+".")
+	}
+	prefixes = append(prefixes, "")
+
+	for _, prefix := range prefixes {
+		if value := s.config.String("notification.otp." + prefix + key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
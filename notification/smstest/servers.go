@@ -0,0 +1,256 @@
+// Package smstest provides local stand-ins of the SMS providers, which keep
+// the messages they receive.
+package smstest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/mostafasolati/leviathan/notification"
+)
+
+// Message is a message received by a stand-in, either as text or as a
+// template of the provider filled in by tokens.
+type Message struct {
+	Sender   string
+	Phone    string
+	Text     string
+	Template string
+	Tokens   []string
+}
+
+// Server is a local stand-in of an SMS provider, keeping the messages it
+// receives.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	messages []Message
+	requests int
+	failing  bool
+}
+
+// Messages returns the messages sent through the stand-in.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Requests returns the number of requests to the stand-in, including the
+// failed ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Fail makes the stand-in fail every request, like a provider having an
+// outage, until it's called with false.
+func (s *Server) Fail(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+// receive counts a request, reporting whether the stand-in is failing.
+func (s *Server) receive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	return !s.failing
+}
+
+// validPhone reports whether the stand-ins accept phone, i.e. digits and an
+// optional leading plus.
+func validPhone(phone string) bool {
+	digits := strings.TrimPrefix(phone, "+")
+	if len(digits) < 8 {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) keep(message Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+// NewKavenegarServer starts a stand-in of the Kavenegar API, sending the
+// messages of `POST /v1/<apiKey>/sms/send.json` and
+// `POST /v1/<apiKey>/verify/lookup.json`.
+func NewKavenegarServer(apiKey string) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok := s.receive()
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if len(parts) != 4 || parts[0] != "v1" || r.Method != http.MethodPost {
+			kavenegarReturn(w, http.StatusNotFound, "not found", nil)
+			return
+		}
+		if parts[1] != apiKey {
+			kavenegarReturn(w, http.StatusForbidden, "invalid api key", nil)
+			return
+		}
+		if !ok {
+			kavenegarReturn(w, http.StatusInternalServerError, "internal error", nil)
+			return
+		}
+
+		if !validPhone(r.PostFormValue("receptor")) {
+			kavenegarReturn(w, 411, "receptor is invalid", nil)
+			return
+		}
+
+		switch parts[2] + "/" + parts[3] {
+		case "sms/send.json":
+			message := Message{
+				Sender: r.PostFormValue("sender"),
+				Phone:  r.PostFormValue("receptor"),
+				Text:   r.PostFormValue("message"),
+			}
+			s.keep(message)
+			kavenegarReturn(w, http.StatusOK, "OK", []map[string]interface{}{{
+				"messageid": len(s.Messages()),
+				"message":   message.Text,
+				"sender":    message.Sender,
+				"receptor":  message.Phone,
+				"status":    1,
+			}})
+		case "verify/lookup.json":
+			message := Message{
+				Phone:    r.PostFormValue("receptor"),
+				Template: r.PostFormValue("template"),
+			}
+			for _, key := range []string{"token", "token2", "token3"} {
+				if token := r.PostFormValue(key); token != "" {
+					message.Tokens = append(message.Tokens, token)
+				}
+			}
+			if message.Template == "" || len(message.Tokens) == 0 {
+				kavenegarReturn(w, http.StatusBadRequest, "template and token are required", nil)
+				return
+			}
+			s.keep(message)
+			kavenegarReturn(w, http.StatusOK, "OK", []map[string]interface{}{{
+				"messageid": len(s.Messages()),
+				"receptor":  message.Phone,
+				"status":    1,
+			}})
+		default:
+			kavenegarReturn(w, http.StatusNotFound, "not found", nil)
+		}
+	}))
+	return s
+}
+
+func kavenegarReturn(w http.ResponseWriter, status int, message string, entries interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"return":  map[string]interface{}{"status": status, "message": message},
+		"entries": entries,
+	})
+}
+
+// NewTwilioServer starts a stand-in of the Twilio API, sending the messages
+// of `POST /2010-04-01/Accounts/<accountSID>/Messages.json` authenticated by
+// accountSID and authToken.
+func NewTwilioServer(accountSID, authToken string) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok := s.receive()
+		if r.Method != http.MethodPost || r.URL.Path != "/2010-04-01/Accounts/"+accountSID+"/Messages.json" {
+			twilioError(w, http.StatusNotFound, 20404, "The requested resource was not found")
+			return
+		}
+		if user, password, _ := r.BasicAuth(); user != accountSID || password != authToken {
+			twilioError(w, http.StatusUnauthorized, 20003, "Authenticate")
+			return
+		}
+		if !ok {
+			twilioError(w, http.StatusInternalServerError, 20500, "Internal Server Error")
+			return
+		}
+
+		message := Message{
+			Sender: r.PostFormValue("From"),
+			Phone:  r.PostFormValue("To"),
+			Text:   r.PostFormValue("Body"),
+		}
+		if message.Phone == "" || message.Text == "" {
+			twilioError(w, http.StatusBadRequest, 21604, "A 'To' phone number and a 'Body' are required.")
+			return
+		}
+		if !validPhone(message.Phone) {
+			twilioError(w, http.StatusBadRequest, 21211, "The 'To' number is not a valid phone number.")
+			return
+		}
+		s.keep(message)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"account_sid": accountSID,
+			"from":        message.Sender,
+			"to":          message.Phone,
+			"body":        message.Text,
+			"status":      "queued",
+		})
+	}))
+	return s
+}
+
+func twilioError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":    code,
+		"message": message,
+		"status":  status,
+	})
+}
+
+// NewWebhookServer starts a receiver of the webhook provider, rejecting the
+// requests which aren't signed by secret unless it's empty.
+func NewWebhookServer(secret string) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok := s.receive()
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if secret != "" && r.Header.Get("X-Signature") != notification.WebhookSignature([]byte(secret), body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if !ok {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var m notification.WebhookMessage
+		if err := json.Unmarshal(body, &m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !validPhone(m.Phone) {
+			http.Error(w, "invalid phone", http.StatusUnprocessableEntity)
+			return
+		}
+		s.keep(Message{Sender: m.Sender, Phone: m.Phone, Text: m.Message})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return s
+}
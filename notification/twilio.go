@@ -0,0 +1,94 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+type twilio struct {
+	name       string
+	url        string
+	accountSID string
+	authToken  string
+	client     *http.Client
+}
+
+// NewTwilio creates a provider sending messages through Twilio, by the
+// account of `notification.sms.<name>.account_sid` and `auth_token`. Its API
+// is requested at `notification.sms.<name>.url` if configured, e.g. in
+// tests.
+func NewTwilio(config contracts.IConfigService, name string) (contracts.ISMSProvider, error) {
+	prefix := "notification.sms." + name + "."
+	apiURL := config.String(prefix + "url")
+	if apiURL == "" {
+		apiURL = "https://api.twilio.com"
+	}
+	return &twilio{
+		name:       name,
+		url:        strings.TrimSuffix(apiURL, "/"),
+		accountSID: config.String(prefix + "account_sid"),
+		authToken:  config.String(prefix + "auth_token"),
+		client:     httpClient(config),
+	}, nil
+}
+
+// Name implements ISMSProvider.Name
+func (t *twilio) Name() string {
+	return t.name
+}
+
+// Send implements ISMSProvider.Send
+func (t *twilio) Send(ctx context.Context, sender, phone, message string) error {
+	form := url.Values{}
+	form.Set("To", phone)
+	form.Set("From", sender)
+	form.Set("Body", message)
+
+	endpoint := t.url + "/2010-04-01/Accounts/" + url.PathEscape(t.accountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 == 2 {
+		return nil
+	}
+
+	var e struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err = fmt.Errorf("twilio: %s", res.Status)
+	if json.NewDecoder(res.Body).Decode(&e) == nil && e.Message != "" {
+		err = fmt.Errorf("twilio: %d: %s", e.Code, e.Message)
+	}
+	// Twilio answers 400 to the messages it refuses, e.g. to invalid or
+	// unsubscribed numbers.
+	if res.StatusCode == http.StatusBadRequest {
+		return fmt.Errorf("%w: %v", contracts.ErrSMSRejected, err)
+	}
+	return err
+}
+
+// HealthCheck implements IHealthChecker.HealthCheck
+func (t *twilio) HealthCheck(ctx context.Context) error {
+	if t.accountSID == "" || t.authToken == "" {
+		return errors.New("twilio account is not configured")
+	}
+	return nil
+}
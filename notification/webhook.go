@@ -0,0 +1,91 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// WebhookMessage is the body of the requests of the webhook provider.
+type WebhookMessage struct {
+	Sender  string `json:"sender,omitempty"`
+	Phone   string `json:"phone"`
+	Message string `json:"message"`
+}
+
+type webhook struct {
+	name   string
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhook creates a provider posting messages as JSON WebhookMessages to
+// `notification.sms.<name>.url`, e.g. to a gateway of its own. Unless
+// `notification.sms.<name>.secret` is empty, the requests are signed by it
+// in the `X-Signature` header, i.e. `sha256=` and the hex-encoded
+// HMAC-SHA256 of the body. Any 2xx response counts as sent, and 400 or 422
+// as the message being rejected.
+func NewWebhook(config contracts.IConfigService, name string) (contracts.ISMSProvider, error) {
+	prefix := "notification.sms." + name + "."
+	u := config.String(prefix + "url")
+	if u == "" {
+		return nil, fmt.Errorf("sms webhook %s has no url", name)
+	}
+	return &webhook{
+		name:   name,
+		url:    u,
+		secret: []byte(config.String(prefix + "secret")),
+		client: httpClient(config),
+	}, nil
+}
+
+// Name implements ISMSProvider.Name
+func (w *webhook) Name() string {
+	return w.name
+}
+
+// Send implements ISMSProvider.Send
+func (w *webhook) Send(ctx context.Context, sender, phone, message string) error {
+	body, err := json.Marshal(&WebhookMessage{Sender: sender, Phone: phone, Message: message})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		req.Header.Set("X-Signature", WebhookSignature(w.secret, body))
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode/100 == 2:
+		return nil
+	case res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: webhook: %s", contracts.ErrSMSRejected, res.Status)
+	default:
+		return fmt.Errorf("webhook: %s", res.Status)
+	}
+}
+
+// WebhookSignature returns the `X-Signature` header of the requests of the
+// webhook provider having body, signed by secret.
+func WebhookSignature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
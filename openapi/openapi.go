@@ -0,0 +1,231 @@
+package openapi
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+)
+
+// Version is the version of the OpenAPI specification of the generated
+// documents.
+const Version = "3.0.3"
+
+const bearerAuth = "bearerAuth"
+
+// Generate builds the OpenAPI document of routes. Hidden routes are left
+// out. Request and response schemas are derived from the types declared by
+// WithRequest and WithResponse, following their `json` tags; the request of
+// GET, HEAD and DELETE routes is described by the `query` tags instead.
+func Generate(info Info, baseURL string, routes []contracts.RouteInfo) *Document {
+	// The structs are collected first, to name their components.
+	collector := newSchemaGenerator(nil)
+	for _, route := range routes {
+		if !route.Hidden {
+			collector.operation(route)
+		}
+	}
+
+	g := newSchemaGenerator(componentNames(collector.structs))
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+	}
+	if baseURL != "" {
+		doc.Servers = []Server{{URL: baseURL}}
+	}
+
+	for _, route := range routes {
+		if route.Hidden {
+			continue
+		}
+
+		path, params := convertPath(route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+
+		op := g.operation(route)
+		op.Parameters = append(params, op.Parameters...)
+		if route.Authenticated {
+			op.Security = []map[string][]string{{bearerAuth: {}}}
+			if doc.Components.SecuritySchemes == nil {
+				doc.Components.SecuritySchemes = map[string]*SecurityScheme{
+					bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				}
+			}
+		}
+		(*item)[strings.ToLower(route.Method)] = op
+	}
+
+	if len(g.schemas) > 0 {
+		doc.Components.Schemas = g.schemas
+	}
+	return doc
+}
+
+func (g *schemaGenerator) operation(route contracts.RouteInfo) *Operation {
+	op := &Operation{
+		OperationID: route.Name,
+		Summary:     route.Summary,
+		Tags:        route.Tags,
+		Responses:   make(map[string]*Response),
+	}
+
+	if route.Request != nil {
+		requestType := reflect.TypeOf(route.Request)
+		switch route.Method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete:
+			op.Parameters = g.queryParameters(requestType)
+		default:
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  jsonContent(g.schema(requestType)),
+			}
+		}
+		op.Responses[strconv.Itoa(http.StatusBadRequest)] = g.response(http.StatusBadRequest, &models.Error{})
+	}
+
+	for status, v := range route.Responses {
+		op.Responses[strconv.Itoa(status)] = g.response(status, v)
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &Response{Description: "Response"}
+	}
+
+	if route.Authenticated {
+		op.Responses[strconv.Itoa(http.StatusUnauthorized)] = g.response(http.StatusUnauthorized, &models.Error{})
+		if len(route.Roles) > 0 {
+			op.Roles = route.Roles
+			op.Description = "Requires one of the roles: " + strings.Join(route.Roles, ", ")
+			op.Responses[strconv.Itoa(http.StatusForbidden)] = g.response(http.StatusForbidden, &models.Error{})
+		}
+	}
+
+	return op
+}
+
+func (g *schemaGenerator) response(status int, v interface{}) *Response {
+	response := &Response{Description: http.StatusText(status)}
+	if response.Description == "" {
+		response.Description = "Response"
+	}
+	if v != nil {
+		response.Content = jsonContent(g.schema(reflect.TypeOf(v)))
+	}
+	return response
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{
+		"application/json": {Schema: schema},
+	}
+}
+
+// convertPath converts the `:param` and `*` segments of a route path to
+// OpenAPI path templates, returning their parameters.
+func convertPath(path string) (string, []*Parameter) {
+	var params []*Parameter
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		name := ""
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			name = segment[1:]
+		case segment == "*":
+			name = "path"
+		default:
+			continue
+		}
+		segments[i] = "{" + name + "}"
+		params = append(params, &Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// Register serves the OpenAPI document of the routes of container at
+// `/openapi.json` if `server.openapi.enabled` is set. The document is titled
+// and versioned by `server.openapi.title` and `server.openapi.version`.
+//
+// If `server.openapi.swagger_ui` is set, Swagger UI is served at `/docs`
+// from the `swagger-ui` directory under StaticDir, which should contain the
+// swagger-ui-dist files. A default page loading them is served if the
+// directory has no index.html.
+func Register(config contracts.IConfigService, container contracts.IServerContainer) {
+	if !config.Bool("server.openapi.enabled") {
+		return
+	}
+
+	container.Route(http.MethodGet, "/openapi.json", func(server contracts.IServer) error {
+		// Routes may be registered after this one, so the document is
+		// generated on demand.
+		doc := Generate(info(config), config.BaseURL(), container.Routes())
+		return server.JSON(http.StatusOK, doc)
+	}, contracts.Named("openapi"), contracts.Hidden())
+
+	if !config.Bool("server.openapi.swagger_ui") {
+		return
+	}
+
+	root := filepath.Join(config.StaticDir(), "swagger-ui")
+	container.Route(http.MethodGet, "/docs", func(server contracts.IServer) error {
+		index := filepath.Join(root, "index.html")
+		if _, err := os.Stat(index); err == nil {
+			return server.File(index)
+		}
+		server.SetHeader("Content-Type", "text/html; charset=utf-8")
+		server.ResponseWriter().WriteHeader(http.StatusOK)
+		_, err := server.ResponseWriter().Write([]byte(swaggerUI))
+		return err
+	}, contracts.Named("docs"), contracts.Hidden())
+
+	container.Route(http.MethodGet, "/docs/*", func(server contracts.IServer) error {
+		// Cleaning a rooted path drops any `..` segments.
+		name := filepath.Clean("/" + server.Param("*"))
+		return server.File(filepath.Join(root, name))
+	}, contracts.Hidden())
+}
+
+func info(config contracts.IConfigService) Info {
+	info := Info{
+		Title:   config.String("server.openapi.title"),
+		Version: config.String("server.openapi.version"),
+	}
+	if info.Title == "" {
+		info.Title = "API"
+	}
+	if info.Version == "" {
+		info.Version = "1.0.0"
+	}
+	return info
+}
+
+const swaggerUI = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API Docs</title>
+<link rel="stylesheet" href="/docs/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="/docs/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,291 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+)
+
+type listRequest struct {
+	Page   int    `query:"page"`
+	Search string `query:"q"`
+	Hidden string
+}
+
+type createRequest struct {
+	Name      string     `json:"name"`
+	Email     *string    `json:"email"`
+	Tags      []string   `json:"tags,omitempty"`
+	Birthday  time.Time  `json:"birthday"`
+	Internal  string     `json:"-"`
+	Avatar    []byte     `json:"avatar,omitempty"`
+	Addresses []*Address `json:"addresses"`
+	private   int
+}
+
+// Address is embedded to test flattening.
+type Address struct {
+	City string `json:"city"`
+}
+
+type account struct {
+	Address
+	ID int64 `json:"id"`
+}
+
+type node struct {
+	Value    int     `json:"value"`
+	Parent   *node   `json:"parent"`
+	Children []*node `json:"children"`
+}
+
+// Error shares its name with models.Error.
+type Error struct {
+	Reason string `json:"reason"`
+}
+
+func TestGenerate(t *testing.T) {
+	ref := func(name string) *Schema { return &Schema{Ref: "#/components/schemas/" + name} }
+	cases := []struct {
+		name   string
+		routes []contracts.RouteInfo
+		check  func(t *testing.T, doc *Document)
+	}{
+		{
+			name: "PathParameters",
+			routes: []contracts.RouteInfo{
+				{Method: http.MethodGet, Path: "/v1/users/:id/files/:file", Name: "file"},
+				{Method: http.MethodGet, Path: "/static/*"},
+			},
+			check: func(t *testing.T, doc *Document) {
+				op := operation(t, doc, "/v1/users/{id}/files/{file}", "get")
+				if op.OperationID != "file" {
+					t.Errorf("operationId = %q, want file", op.OperationID)
+				}
+				want := []*Parameter{
+					{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+					{Name: "file", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+				}
+				equal(t, "parameters", op.Parameters, want)
+				op = operation(t, doc, "/static/{path}", "get")
+				equal(t, "wildcard parameters", op.Parameters,
+					[]*Parameter{{Name: "path", In: "path", Required: true, Schema: &Schema{Type: "string"}}})
+				if _, ok := op.Responses["default"]; !ok {
+					t.Errorf("responses = %v, want a default response", op.Responses)
+				}
+			},
+		},
+		{
+			name: "QueryParameters",
+			routes: []contracts.RouteInfo{
+				{Method: http.MethodGet, Path: "/v1/users/:id", Request: &listRequest{}},
+			},
+			check: func(t *testing.T, doc *Document) {
+				op := operation(t, doc, "/v1/users/{id}", "get")
+				want := []*Parameter{
+					{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+					{Name: "page", In: "query", Schema: &Schema{Type: "integer", Format: "int64"}},
+					{Name: "q", In: "query", Schema: &Schema{Type: "string"}},
+				}
+				equal(t, "parameters", op.Parameters, want)
+				if op.RequestBody != nil {
+					t.Errorf("requestBody = %+v, want none", op.RequestBody)
+				}
+				equal(t, "400", op.Responses["400"].Content["application/json"].Schema, ref("Error"))
+			},
+		},
+		{
+			name: "Bodies",
+			routes: []contracts.RouteInfo{
+				{
+					Method:    http.MethodPost,
+					Path:      "/v1/accounts",
+					Request:   createRequest{},
+					Responses: map[int]interface{}{http.StatusCreated: &account{}, http.StatusNoContent: nil},
+				},
+			},
+			check: func(t *testing.T, doc *Document) {
+				op := operation(t, doc, "/v1/accounts", "post")
+				if op.RequestBody == nil || !op.RequestBody.Required {
+					t.Fatalf("requestBody = %+v, want a required body", op.RequestBody)
+				}
+				equal(t, "request", op.RequestBody.Content["application/json"].Schema, ref("createRequest"))
+				equal(t, "201", op.Responses["201"].Content["application/json"].Schema, ref("account"))
+				if response := op.Responses["204"]; response.Description != "No Content" || response.Content != nil {
+					t.Errorf("204 = %+v, want no content", response)
+				}
+
+				equal(t, "createRequest", doc.Components.Schemas["createRequest"], &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"name":      {Type: "string"},
+						"email":     {Type: "string", Nullable: true},
+						"tags":      {Type: "array", Items: &Schema{Type: "string"}},
+						"birthday":  {Type: "string", Format: "date-time"},
+						"avatar":    {Type: "string", Format: "byte"},
+						"addresses": {Type: "array", Items: ref("Address")},
+					},
+					Required: []string{"name", "birthday", "addresses"},
+				})
+				equal(t, "account", doc.Components.Schemas["account"], &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"city": {Type: "string"},
+						"id":   {Type: "integer", Format: "int64"},
+					},
+					Required: []string{"city", "id"},
+				})
+			},
+		},
+		{
+			name: "Security",
+			routes: []contracts.RouteInfo{
+				{Method: http.MethodGet, Path: "/v1/me", Authenticated: true},
+				{Method: http.MethodDelete, Path: "/v1/users/:id", Authenticated: true, Roles: []string{"admin", "staff"}},
+				{Method: http.MethodGet, Path: "/v1/public"},
+			},
+			check: func(t *testing.T, doc *Document) {
+				bearer := []map[string][]string{{bearerAuth: {}}}
+				equal(t, "security schemes", doc.Components.SecuritySchemes, map[string]*SecurityScheme{
+					bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				})
+
+				op := operation(t, doc, "/v1/me", "get")
+				equal(t, "security", op.Security, bearer)
+				if _, ok := op.Responses["401"]; !ok {
+					t.Errorf("responses = %v, want 401", op.Responses)
+				}
+				if _, ok := op.Responses["403"]; ok {
+					t.Errorf("responses = %v, want no 403 without roles", op.Responses)
+				}
+
+				op = operation(t, doc, "/v1/users/{id}", "delete")
+				equal(t, "roles", op.Roles, []string{"admin", "staff"})
+				if op.Description != "Requires one of the roles: admin, staff" {
+					t.Errorf("description = %q", op.Description)
+				}
+				if _, ok := op.Responses["403"]; !ok {
+					t.Errorf("responses = %v, want 403", op.Responses)
+				}
+
+				if op := operation(t, doc, "/v1/public", "get"); op.Security != nil {
+					t.Errorf("security = %v, want none", op.Security)
+				}
+			},
+		},
+		{
+			name: "Recursive",
+			routes: []contracts.RouteInfo{
+				{Method: http.MethodGet, Path: "/v1/tree", Responses: map[int]interface{}{http.StatusOK: &node{}}},
+			},
+			check: func(t *testing.T, doc *Document) {
+				equal(t, "node", doc.Components.Schemas["node"], &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"value":    {Type: "integer", Format: "int64"},
+						"parent":   ref("node"),
+						"children": {Type: "array", Items: ref("node")},
+					},
+					Required: []string{"value", "children"},
+				})
+			},
+		},
+		{
+			name: "SameNames",
+			routes: []contracts.RouteInfo{
+				{Method: http.MethodGet, Path: "/v1/a", Responses: map[int]interface{}{http.StatusOK: &Error{}}},
+				{Method: http.MethodGet, Path: "/v1/b", Authenticated: true},
+			},
+			check: func(t *testing.T, doc *Document) {
+				equal(t, "200", operation(t, doc, "/v1/a", "get").Responses["200"].Content["application/json"].Schema,
+					ref("OpenapiError"))
+				equal(t, "401", operation(t, doc, "/v1/b", "get").Responses["401"].Content["application/json"].Schema,
+					ref("ModelsError"))
+				if _, ok := doc.Components.Schemas["Error"]; ok || len(doc.Components.Schemas) != 2 {
+					t.Errorf("schemas = %v, want OpenapiError and ModelsError", keys(doc.Components.Schemas))
+				}
+			},
+		},
+		{
+			name: "Hidden",
+			routes: []contracts.RouteInfo{
+				{Method: http.MethodGet, Path: "/openapi.json", Hidden: true, Responses: map[int]interface{}{200: &node{}}},
+			},
+			check: func(t *testing.T, doc *Document) {
+				if len(doc.Paths) != 0 || doc.Components.Schemas != nil {
+					t.Errorf("document = %+v, want no paths nor schemas", doc)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := Generate(Info{Title: "API", Version: "1.0.0"}, "https://api.example.com", tc.routes)
+			if doc.OpenAPI != Version || doc.Servers[0].URL != "https://api.example.com" {
+				t.Errorf("document = %s %+v", doc.OpenAPI, doc.Servers)
+			}
+			tc.check(t, doc)
+		})
+	}
+}
+
+func TestComponentNames(t *testing.T) {
+	// Names don't depend on the order of the routes.
+	routes := []contracts.RouteInfo{
+		{Method: http.MethodGet, Path: "/v1/a", Responses: map[int]interface{}{http.StatusOK: &Error{}}},
+		{Method: http.MethodGet, Path: "/v1/b", Responses: map[int]interface{}{http.StatusOK: &models.Error{}}},
+	}
+	first := Generate(Info{}, "", routes)
+	second := Generate(Info{}, "", []contracts.RouteInfo{routes[1], routes[0]})
+	equal(t, "schemas", keys(first.Components.Schemas), keys(second.Components.Schemas))
+
+	// Qualified names which are taken are numbered.
+	type ModelsError struct{}
+	names := componentNames(map[reflect.Type]bool{
+		reflect.TypeOf(Error{}):        true,
+		reflect.TypeOf(models.Error{}): true,
+		reflect.TypeOf(ModelsError{}):  true,
+	})
+	equal(t, "names", names, map[reflect.Type]string{
+		reflect.TypeOf(Error{}):        "OpenapiError",
+		reflect.TypeOf(models.Error{}): "ModelsError2",
+		reflect.TypeOf(ModelsError{}):  "ModelsError",
+	})
+}
+
+func operation(t *testing.T, doc *Document, path, method string) *Operation {
+	t.Helper()
+	item, ok := doc.Paths[path]
+	if !ok {
+		t.Fatalf("paths = %v, want %s", keys(doc.Paths), path)
+	}
+	op, ok := (*item)[method]
+	if !ok {
+		t.Fatalf("%s has no %s operation", path, method)
+	}
+	return op
+}
+
+// equal compares got to want by their JSON encodings, which are readable in
+// failures.
+func equal(t *testing.T, name string, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("%s = %s, want %s", name, gotJSON, wantJSON)
+	}
+}
+
+func keys[V any](m map[string]V) map[string]bool {
+	set := make(map[string]bool, len(m))
+	for key := range m {
+		set[key] = true
+	}
+	return set
+}
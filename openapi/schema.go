@@ -0,0 +1,257 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaGenerator derives schemas from Go types, collecting the schemas of
+// named structs as reusable components.
+type schemaGenerator struct {
+	schemas map[string]*Schema
+
+	// names are the component names of the structs, by componentNames. The
+	// structs missing are named by their type names.
+	names map[reflect.Type]string
+
+	// structs are the structs whose components are generated.
+	structs map[reflect.Type]bool
+}
+
+func newSchemaGenerator(names map[reflect.Type]string) *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]*Schema),
+		names:   names,
+		structs: make(map[reflect.Type]bool),
+	}
+}
+
+// schema returns the schema of t as encoding/json would encode it.
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		nullable = true
+		t = t.Elem()
+	}
+
+	s := g.schemaOf(t)
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType),
+		t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		// Custom encodings are mostly strings, e.g. dates.
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+
+	// Interfaces and the like may hold anything.
+	return &Schema{}
+}
+
+// structRef registers the schema of a struct as a component and refers to
+// it. Anonymous structs are inlined.
+func (g *schemaGenerator) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+	}
+	if !g.structs[t] {
+		g.structs[t] = true
+		// Register before generating, so recursive types terminate.
+		s := &Schema{}
+		g.schemas[name] = s
+		*s = *g.structSchema(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// componentNames names the components of structs by their type names. The
+// structs sharing a type name are qualified by their package names, e.g.
+// ModelsError, and numbered in the order of their package paths if that's
+// still taken, so the names don't depend on the order of the routes.
+func componentNames(structs map[reflect.Type]bool) map[reflect.Type]string {
+	types := make([]reflect.Type, 0, len(structs))
+	count := make(map[string]int)
+	for t := range structs {
+		types = append(types, t)
+		count[t.Name()]++
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Name() != types[j].Name() {
+			return types[i].Name() < types[j].Name()
+		}
+		return types[i].PkgPath() < types[j].PkgPath()
+	})
+
+	names := make(map[reflect.Type]string, len(types))
+	taken := make(map[string]bool, len(types))
+	for _, t := range types {
+		if count[t.Name()] == 1 {
+			names[t] = t.Name()
+			taken[t.Name()] = true
+		}
+	}
+	for _, t := range types {
+		if count[t.Name()] == 1 {
+			continue
+		}
+		pkg := t.PkgPath()
+		if i := strings.LastIndex(pkg, "/"); i >= 0 {
+			pkg = pkg[i+1:]
+		}
+		qualified := upperFirst(pkg) + t.Name()
+		name := qualified
+		for n := 2; taken[name]; n++ {
+			name = qualified + strconv.Itoa(n)
+		}
+		names[t] = name
+		taken[name] = true
+	}
+	return names
+}
+
+// upperFirst upper-cases the first letter of s.
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+// addFields adds the fields of a struct to s, flattening embedded structs
+// like encoding/json does.
+func (g *schemaGenerator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitempty, ok := jsonField(field)
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			for fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				g.addFields(s, fieldType)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = g.schema(field.Type)
+		if !omitempty && field.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// jsonField returns the JSON name of a struct field and whether it's
+// omitted when empty. ok is false for fields which aren't encoded.
+func jsonField(field reflect.StructField) (name string, omitempty, ok bool) {
+	if field.PkgPath != "" && !field.Anonymous {
+		return "", false, false
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	parts := strings.Split(tag, ",")
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitempty = true
+		}
+	}
+	return parts[0], omitempty, true
+}
+
+// queryParameters returns the query parameters of a struct bound from the
+// query string, i.e. its fields with a `query` tag.
+func (g *schemaGenerator) queryParameters(t reflect.Type) []*Parameter {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("query"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		params = append(params, &Parameter{
+			Name:   name,
+			In:     "query",
+			Schema: g.schema(field.Type),
+		})
+	}
+	return params
+}
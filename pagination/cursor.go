@@ -0,0 +1,104 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+var errNoSecret = errors.New("cursor secret is not configured")
+
+// Secret returns the key which cursors are signed by, i.e.
+// `server.pagination.secret`, or else a key derived from `auth.jwt.secret`.
+func Secret(config contracts.IConfigService) []byte {
+	return utils.DeriveSecret(config, "server.pagination.secret", cursorKeyInfo)
+}
+
+// cursorKeyInfo binds the keys derived by Secret to signing cursors.
+const cursorKeyInfo = "leviathan pagination cursor"
+
+// EncodeCursor encodes v, e.g. the sort keys of the last item of a page, as
+// an opaque cursor signed by secret, so clients can't forge it.
+func EncodeCursor(secret []byte, v interface{}) (string, error) {
+	if len(secret) == 0 {
+		return "", errNoSecret
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(sign(secret, payload)), nil
+}
+
+// DecodeCursor verifies a cursor made by EncodeCursor and decodes it into v.
+// It returns ErrInvalidCursor if the cursor is malformed or forged.
+func DecodeCursor(secret []byte, cursor string, v interface{}) error {
+	if len(secret) == 0 {
+		return errNoSecret
+	}
+
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 2 {
+		return contracts.ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return contracts.ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, sign(secret, payload)) {
+		return contracts.ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return contracts.ErrInvalidCursor
+	}
+	return nil
+}
+
+// NewCursor builds the cursor pagination of data, signing next into the
+// cursor of the next page. A nil next marks the last page.
+func NewCursor(secret []byte, perPage int, data interface{}, next interface{}) (*models.CursorPagination, error) {
+	p := &models.CursorPagination{
+		PerPage: perPage,
+		Data:    data,
+	}
+	if next != nil {
+		cursor, err := EncodeCursor(secret, next)
+		if err != nil {
+			return nil, err
+		}
+		p.NextCursor = cursor
+	}
+	return p, nil
+}
+
+// CursorLinks returns the RFC 5988 `Link` header of the first and next pages
+// of p, linking to u with its `cursor` parameter replaced.
+func CursorLinks(u *url.URL, p *models.CursorPagination) string {
+	first := *u
+	query := first.Query()
+	query.Del("cursor")
+	first.RawQuery = query.Encode()
+
+	links := []string{"<" + first.String() + `>; rel="first"`}
+	if p.NextCursor != "" {
+		links = append(links, link(u, "cursor", p.NextCursor, "next"))
+	}
+	return strings.Join(links, ", ")
+}
+
+func sign(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
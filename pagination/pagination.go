@@ -0,0 +1,125 @@
+// Package pagination fills in models.Pagination and models.CursorPagination
+// and links their pages.
+package pagination
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+)
+
+// Default limits of the page size, which are configured by
+// `server.pagination.per_page` and `server.pagination.max_per_page`.
+const (
+	DefaultPerPage    = 15
+	DefaultMaxPerPage = 100
+)
+
+// MaxOffset is the largest offset of the pages of ParsePage, which fits an
+// OFFSET of any database.
+const MaxOffset = math.MaxInt32
+
+// ParsePage reads the requested page from the `page` and `per_page`
+// parameters. Pages start at 1, and the page size is limited to
+// `server.pagination.max_per_page`. Pages are limited to MaxOffset, so their
+// offsets don't overflow.
+func ParsePage(config contracts.IConfigService, values url.Values) models.Page {
+	page := models.Page{
+		Number:  1,
+		PerPage: PerPage(config, values),
+	}
+	if n, err := strconv.Atoi(values.Get("page")); err == nil && n > 1 {
+		page.Number = n
+	}
+	if max := MaxOffset/page.PerPage + 1; page.Number > max {
+		page.Number = max
+	}
+	return page
+}
+
+// PerPage reads the page size from the `per_page` parameter, falling back to
+// `server.pagination.per_page`.
+func PerPage(config contracts.IConfigService, values url.Values) int {
+	perPage := config.Int("server.pagination.per_page")
+	if perPage <= 0 {
+		perPage = DefaultPerPage
+	}
+	maxPerPage := config.Int("server.pagination.max_per_page")
+	if maxPerPage <= 0 {
+		maxPerPage = DefaultMaxPerPage
+	}
+
+	if n, err := strconv.Atoi(values.Get("per_page")); err == nil && n > 0 {
+		perPage = n
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return perPage
+}
+
+// New builds the pagination of data, the items of page in a list of total
+// items.
+func New(total int, page models.Page, data interface{}) *models.Pagination {
+	p := &models.Pagination{
+		Total:       total,
+		PerPage:     page.PerPage,
+		CurrentPage: page.Number,
+		LastPage:    1,
+		Data:        data,
+	}
+	if page.PerPage > 0 && total > 0 {
+		p.LastPage = int(math.Ceil(float64(total) / float64(page.PerPage)))
+	}
+
+	count := total - page.Offset()
+	if value := reflect.ValueOf(data); value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		count = value.Len()
+	} else if count > page.PerPage {
+		count = page.PerPage
+	}
+	if count > 0 {
+		p.From = page.Offset() + 1
+		p.To = page.Offset() + count
+	}
+
+	return p
+}
+
+// Links returns the RFC 5988 `Link` header of the first, previous, next and
+// last pages of p, linking to u with its `page` parameter replaced.
+func Links(u *url.URL, p *models.Pagination) string {
+	pages := []struct {
+		rel    string
+		number int
+		ok     bool
+	}{
+		{"first", 1, true},
+		{"prev", p.CurrentPage - 1, p.CurrentPage > 1 && p.CurrentPage <= p.LastPage+1},
+		{"next", p.CurrentPage + 1, p.CurrentPage < p.LastPage},
+		{"last", p.LastPage, true},
+	}
+
+	var links []string
+	for _, page := range pages {
+		if !page.ok {
+			continue
+		}
+		links = append(links, link(u, "page", strconv.Itoa(page.number), page.rel))
+	}
+	return strings.Join(links, ", ")
+}
+
+// link returns a link to u with a query parameter replaced.
+func link(u *url.URL, key, value, rel string) string {
+	target := *u
+	query := target.Query()
+	query.Set(key, value)
+	target.RawQuery = query.Encode()
+	return "<" + target.String() + `>; rel="` + rel + `"`
+}
@@ -0,0 +1,214 @@
+package pagination_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/logger"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/pagination"
+	"github.com/mostafasolati/leviathan/ratelimit"
+	services "github.com/mostafasolati/leviathan/server"
+	"github.com/mostafasolati/leviathan/storage"
+)
+
+func TestParsePage(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		want  models.Page
+	}{
+		{name: "Default", query: "", want: models.Page{Number: 1, PerPage: pagination.DefaultPerPage}},
+		{name: "Page", query: "page=3&per_page=20", want: models.Page{Number: 3, PerPage: 20}},
+		{name: "Invalid", query: "page=-2&per_page=x", want: models.Page{Number: 1, PerPage: pagination.DefaultPerPage}},
+		{name: "MaxPerPage", query: "per_page=1000", want: models.Page{Number: 1, PerPage: pagination.DefaultMaxPerPage}},
+		{name: "Overflow", query: "page=9223372036854775807&per_page=100",
+			want: models.Page{Number: pagination.MaxOffset/100 + 1, PerPage: 100}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page := pagination.ParsePage(config.NewConfigService(), values)
+			if page != tc.want {
+				t.Errorf("ParsePage = %+v, want %+v", page, tc.want)
+			}
+			if offset := page.Offset(); offset < 0 || offset > pagination.MaxOffset {
+				t.Errorf("Offset = %d, want within 0 to %d", offset, pagination.MaxOffset)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name  string
+		total int
+		page  models.Page
+		data  interface{}
+		want  models.Pagination
+	}{
+		{name: "First", total: 25, page: models.Page{Number: 1, PerPage: 10}, data: make([]int, 10),
+			want: models.Pagination{Total: 25, PerPage: 10, CurrentPage: 1, LastPage: 3, From: 1, To: 10}},
+		{name: "Last", total: 25, page: models.Page{Number: 3, PerPage: 10}, data: make([]int, 5),
+			want: models.Pagination{Total: 25, PerPage: 10, CurrentPage: 3, LastPage: 3, From: 21, To: 25}},
+		{name: "Beyond", total: 25, page: models.Page{Number: 4, PerPage: 10}, data: []int{},
+			want: models.Pagination{Total: 25, PerPage: 10, CurrentPage: 4, LastPage: 3}},
+		{name: "Empty", total: 0, page: models.Page{Number: 1, PerPage: 10}, data: []int{},
+			want: models.Pagination{PerPage: 10, CurrentPage: 1, LastPage: 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := pagination.New(tc.total, tc.page, tc.data)
+			p.Data = nil
+			if *p != tc.want {
+				t.Errorf("New = %+v, want %+v", *p, tc.want)
+			}
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	secret := []byte("secret")
+	type position struct {
+		After int `json:"after"`
+	}
+	cursor, err := pagination.EncodeCursor(secret, position{After: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		secret []byte
+		cursor string
+		err    error
+	}{
+		{name: "Valid", secret: secret, cursor: cursor},
+		{name: "OtherSecret", secret: []byte("other"), cursor: cursor, err: contracts.ErrInvalidCursor},
+		{name: "Forged", secret: secret, cursor: "eyJhZnRlciI6OTl9." + cursor[len(cursor)-43:],
+			err: contracts.ErrInvalidCursor},
+		{name: "Malformed", secret: secret, cursor: "abc", err: contracts.ErrInvalidCursor},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got position
+			err := pagination.DecodeCursor(tc.secret, tc.cursor, &got)
+			if err != tc.err {
+				t.Fatalf("DecodeCursor = %v, want %v", err, tc.err)
+			}
+			if err == nil && got.After != 5 {
+				t.Errorf("DecodeCursor = %+v, want after 5", got)
+			}
+		})
+	}
+}
+
+func TestSecret(t *testing.T) {
+	cfg := config.NewConfigService()
+	if secret := pagination.Secret(cfg); len(secret) != 0 {
+		t.Errorf("Secret = %x, want none without secrets", secret)
+	}
+
+	cfg.SetString("auth.jwt.secret", "jwt")
+	derived := pagination.Secret(cfg)
+	if len(derived) == 0 || bytes.Equal(derived, []byte("jwt")) {
+		t.Errorf("Secret = %x, want a key derived from the JWT secret", derived)
+	}
+	if again := pagination.Secret(cfg); !bytes.Equal(again, derived) {
+		t.Errorf("Secret = %x, then %x, want the same key", derived, again)
+	}
+
+	cfg.SetString("server.pagination.secret", "cursors")
+	if secret := pagination.Secret(cfg); string(secret) != "cursors" {
+		t.Errorf("Secret = %q, want the configured one", secret)
+	}
+}
+
+func TestPaginateCursor(t *testing.T) {
+	cfg := config.NewConfigService()
+	cfg.SetString("auth.jwt.secret", "secret")
+	l := logger.NewLogger(cfg)
+	c := services.NewHTTPServerContainer(cfg, l, ratelimit.NewRateLimiter(cfg, l, ratelimit.NewMemoryStore()),
+		storage.NewMemoryStorage(cfg))
+	// The route lists 1 to 5 by cursors.
+	c.Route(http.MethodGet, "/items", func(server contracts.IServer) error {
+		var position struct {
+			After int `json:"after"`
+		}
+		perPage, err := server.Cursor(&position)
+		if err != nil {
+			return err
+		}
+		var items []int
+		for i := position.After + 1; i <= 5 && len(items) < perPage; i++ {
+			items = append(items, i)
+		}
+		var next interface{}
+		if len(items) > 0 && items[len(items)-1] < 5 {
+			next = map[string]int{"after": items[len(items)-1]}
+		}
+		return server.PaginateCursor(http.StatusOK, perPage, items, next)
+	})
+	ts := c.TestServer()
+	defer ts.Close()
+
+	cursor := func(secret []byte, after int) string {
+		cursor, err := pagination.EncodeCursor(secret, map[string]int{"after": after})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cursor
+	}
+	cases := []struct {
+		name   string
+		cursor string
+		status int
+		data   []int
+		next   bool
+	}{
+		{name: "First", status: http.StatusOK, data: []int{1, 2}, next: true},
+		{name: "Next", cursor: cursor(pagination.Secret(cfg), 2), status: http.StatusOK, data: []int{3, 4}, next: true},
+		{name: "Last", cursor: cursor(pagination.Secret(cfg), 4), status: http.StatusOK, data: []int{5}},
+		{name: "Forged", cursor: cursor([]byte("forged"), 4), status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := http.Get(ts.URL + "/items?per_page=2&cursor=" + url.QueryEscape(tc.cursor))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.status {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var page struct {
+				models.CursorPagination
+				Data []int `json:"data"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(page.Data, tc.data) || page.PerPage != 2 {
+				t.Errorf("page = %+v, want %v", page, tc.data)
+			}
+			linked := strings.Contains(res.Header.Get("Link"), `rel="next"`)
+			if next := page.NextCursor != ""; next != tc.next || linked != tc.next {
+				t.Errorf("next cursor %q, linked %v, want a next page: %v", page.NextCursor, linked, tc.next)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package pubsub
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// hub fans messages out to the subscribers of this process and tracks their
+// presence. Shared backends deliver the messages they receive through it.
+type hub struct {
+	config contracts.IConfigService
+
+	mu       sync.RWMutex
+	topics   map[string]map[*subscription]struct{}
+	presence map[string]map[string]int
+	closed   bool
+
+	// onJoin and onLeave are called when a member becomes present in or
+	// absent from a topic in this process.
+	onJoin  func(topic, member string)
+	onLeave func(topic, member string)
+}
+
+func newHub(config contracts.IConfigService) *hub {
+	return &hub{
+		config:   config,
+		topics:   make(map[string]map[*subscription]struct{}),
+		presence: make(map[string]map[string]int),
+	}
+}
+
+// isClosed reports whether the hub is closed, so nothing can be published.
+func (h *hub) isClosed() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.closed
+}
+
+// deliver sends a message to the subscribers of its topic.
+func (h *hub) deliver(m contracts.Message) {
+	h.mu.RLock()
+	subs := make([]*subscription, 0, len(h.topics[m.Topic]))
+	for s := range h.topics[m.Topic] {
+		subs = append(subs, s)
+	}
+	h.mu.RUnlock()
+
+	for _, s := range subs {
+		s.send(m)
+	}
+}
+
+func (h *hub) subscribe(ctx context.Context, topic string, options []contracts.SubscribeOption) (*subscription, error) {
+	o := subscribeOptions(h.config, options)
+	s := &subscription{
+		hub:    h,
+		topic:  topic,
+		member: o.Member,
+		policy: *o.Backpressure,
+		ch:     make(chan contracts.Message, o.Buffer),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, contracts.ErrPubSubClosed
+	}
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*subscription]struct{})
+	}
+	h.topics[topic][s] = struct{}{}
+	joined := false
+	if s.member != "" {
+		if h.presence[topic] == nil {
+			h.presence[topic] = make(map[string]int)
+		}
+		h.presence[topic][s.member]++
+		joined = h.presence[topic][s.member] == 1
+	}
+	h.mu.Unlock()
+
+	if joined && h.onJoin != nil {
+		h.onJoin(topic, s.member)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.close(nil)
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// remove unsubscribes a closed subscription.
+func (h *hub) remove(s *subscription) {
+	h.mu.Lock()
+	delete(h.topics[s.topic], s)
+	if len(h.topics[s.topic]) == 0 {
+		delete(h.topics, s.topic)
+	}
+	left := false
+	if s.member != "" && h.presence[s.topic] != nil {
+		h.presence[s.topic][s.member]--
+		if h.presence[s.topic][s.member] <= 0 {
+			delete(h.presence[s.topic], s.member)
+			left = true
+		}
+		if len(h.presence[s.topic]) == 0 {
+			delete(h.presence, s.topic)
+		}
+	}
+	h.mu.Unlock()
+
+	if left && h.onLeave != nil {
+		h.onLeave(s.topic, s.member)
+	}
+}
+
+// members returns the members present in this process, by topic.
+func (h *hub) members() map[string][]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	members := make(map[string][]string, len(h.presence))
+	for topic, counts := range h.presence {
+		for member := range counts {
+			members[topic] = append(members[topic], member)
+		}
+		sort.Strings(members[topic])
+	}
+	return members
+}
+
+// close closes all the subscriptions.
+func (h *hub) close() {
+	h.mu.Lock()
+	h.closed = true
+	var subs []*subscription
+	for _, topic := range h.topics {
+		for s := range topic {
+			subs = append(subs, s)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, s := range subs {
+		s.close(contracts.ErrPubSubClosed)
+	}
+}
+
+type subscription struct {
+	hub    *hub
+	topic  string
+	member string
+	policy contracts.Backpressure
+
+	mu     sync.Mutex
+	ch     chan contracts.Message
+	done   chan struct{}
+	closed bool
+	err    error
+}
+
+// send buffers a message, applying the backpressure policy if the buffer is
+// full.
+func (s *subscription) send(m contracts.Message) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	select {
+	case s.ch <- m:
+		s.mu.Unlock()
+		return
+	default:
+	}
+
+	if s.policy == contracts.Disconnect {
+		s.mu.Unlock()
+		s.close(contracts.ErrSlowSubscriber)
+		return
+	}
+
+	// Only senders holding the lock fill the buffer, so there is room once
+	// the oldest message is dropped.
+	select {
+	case <-s.ch:
+	default:
+	}
+	s.ch <- m
+	s.mu.Unlock()
+}
+
+// Messages implements ISubscription.Messages
+func (s *subscription) Messages() <-chan contracts.Message {
+	return s.ch
+}
+
+// Err implements ISubscription.Err
+func (s *subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close implements ISubscription.Close
+func (s *subscription) Close() error {
+	s.close(nil)
+	return nil
+}
+
+func (s *subscription) close(err error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.ch)
+	close(s.done)
+	s.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+type memoryPubSub struct {
+	*hub
+}
+
+// NewMemoryPubSub creates an IPubSub which only reaches the subscribers of
+// the same process.
+func NewMemoryPubSub(config contracts.IConfigService) contracts.IPubSub {
+	return &memoryPubSub{hub: newHub(config)}
+}
+
+// Publish implements IPubSub.Publish
+func (p *memoryPubSub) Publish(ctx context.Context, topic string, v interface{}) error {
+	if p.isClosed() {
+		return contracts.ErrPubSubClosed
+	}
+	m, err := encode(topic, v)
+	if err != nil {
+		return err
+	}
+	p.deliver(m)
+	return nil
+}
+
+// Subscribe implements IPubSub.Subscribe
+func (p *memoryPubSub) Subscribe(ctx context.Context, topic string, options ...contracts.SubscribeOption) (contracts.ISubscription, error) {
+	s, err := p.subscribe(ctx, topic, options)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// PublishToUser implements IPubSub.PublishToUser
+func (p *memoryPubSub) PublishToUser(ctx context.Context, userID int, v interface{}) error {
+	return p.Publish(ctx, UserTopic(userID), v)
+}
+
+// SubscribeUser implements IPubSub.SubscribeUser
+func (p *memoryPubSub) SubscribeUser(ctx context.Context, userID int, options ...contracts.SubscribeOption) (contracts.ISubscription, error) {
+	return p.Subscribe(ctx, UserTopic(userID), options...)
+}
+
+// Presence implements IPubSub.Presence
+func (p *memoryPubSub) Presence(ctx context.Context, topic string) ([]string, error) {
+	return p.members()[topic], nil
+}
+
+// Close implements IPubSub.Close
+func (p *memoryPubSub) Close() error {
+	p.close()
+	return nil
+}
@@ -0,0 +1,222 @@
+package pubsub
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// receive returns the data of the messages buffered for sub.
+func receive(sub contracts.ISubscription) []string {
+	var data []string
+	for {
+		select {
+		case m, ok := <-sub.Messages():
+			if !ok {
+				return data
+			}
+			data = append(data, string(m.Data))
+		default:
+			return data
+		}
+	}
+}
+
+func TestMemoryPublish(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []contracts.SubscribeOption
+		publish []int
+		want    []string
+		err     error
+	}{
+		{name: "Delivered", publish: []int{1, 2, 3}, want: []string{"1", "2", "3"}},
+		{
+			name:    "DropOldest",
+			options: []contracts.SubscribeOption{contracts.WithBuffer(2)},
+			publish: []int{1, 2, 3},
+			want:    []string{"2", "3"},
+		},
+		{
+			name: "Disconnect",
+			options: []contracts.SubscribeOption{
+				contracts.WithBuffer(2), contracts.WithBackpressure(contracts.Disconnect),
+			},
+			publish: []int{1, 2, 3},
+			want:    []string{"1", "2"},
+			err:     contracts.ErrSlowSubscriber,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			p := NewMemoryPubSub(config.NewConfigService())
+			defer p.Close()
+
+			sub, err := p.Subscribe(ctx, "orders", tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := p.Subscribe(ctx, "other")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range tc.publish {
+				if err := p.Publish(ctx, "orders", v); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := receive(sub); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("received %v, want %v", got, tc.want)
+			}
+			if err := sub.Err(); err != tc.err {
+				t.Errorf("Err = %v, want %v", err, tc.err)
+			}
+			if got := receive(other); len(got) != 0 {
+				t.Errorf("other topic received %v", got)
+			}
+		})
+	}
+}
+
+func TestMemoryPublishToUser(t *testing.T) {
+	ctx := context.Background()
+	p := NewMemoryPubSub(config.NewConfigService())
+	defer p.Close()
+
+	sub, err := p.SubscribeUser(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PublishToUser(ctx, 7, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PublishToUser(ctx, 8, "bye"); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(sub); !reflect.DeepEqual(got, []string{`"hi"`}) {
+		t.Errorf("received %v, want the message of the user", got)
+	}
+}
+
+func TestMemoryPresence(t *testing.T) {
+	ctx := context.Background()
+	p := NewMemoryPubSub(config.NewConfigService())
+	defer p.Close()
+
+	presence := func() []string {
+		members, err := p.Presence(ctx, "room")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return members
+	}
+
+	bob, err := p.Subscribe(ctx, "room", contracts.AsMember("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A member subscribed twice is present until both leave.
+	subCtx, cancel := context.WithCancel(ctx)
+	alice, err := p.Subscribe(subCtx, "room", contracts.AsMember("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Subscribe(ctx, "room", contracts.AsMember("alice")); err != nil {
+		t.Fatal(err)
+	}
+	if got := presence(); !reflect.DeepEqual(got, []string{"alice", "bob"}) {
+		t.Errorf("Presence = %v, want alice and bob", got)
+	}
+
+	bob.Close()
+	cancel()
+	// The subscription is closed by its context asynchronously.
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, ok := <-alice.Messages(); !ok {
+			break
+		}
+	}
+	if got := presence(); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("Presence = %v, want alice", got)
+	}
+}
+
+func TestMemoryClose(t *testing.T) {
+	ctx := context.Background()
+	p := NewMemoryPubSub(config.NewConfigService())
+	sub, err := p.Subscribe(ctx, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-sub.Messages(); ok {
+		t.Error("subscription is open after Close")
+	}
+	if err := sub.Err(); err != contracts.ErrPubSubClosed {
+		t.Errorf("Err = %v, want %v", err, contracts.ErrPubSubClosed)
+	}
+	if err := p.Publish(ctx, "orders", 1); err != contracts.ErrPubSubClosed {
+		t.Errorf("Publish = %v, want %v", err, contracts.ErrPubSubClosed)
+	}
+	if _, err := p.Subscribe(ctx, "orders"); err != contracts.ErrPubSubClosed {
+		t.Errorf("Subscribe = %v, want %v", err, contracts.ErrPubSubClosed)
+	}
+}
+
+func TestSubscribeOptions(t *testing.T) {
+	disconnect := contracts.Disconnect
+	dropOldest := contracts.DropOldest
+	cases := []struct {
+		name    string
+		config  map[string]string
+		options []contracts.SubscribeOption
+		want    contracts.SubscribeOptions
+	}{
+		{name: "Defaults", want: contracts.SubscribeOptions{Buffer: defaultBuffer, Backpressure: &dropOldest}},
+		{
+			name:   "Configured",
+			config: map[string]string{"pubsub.buffer": "8", "pubsub.backpressure": "disconnect"},
+			want:   contracts.SubscribeOptions{Buffer: 8, Backpressure: &disconnect},
+		},
+		{
+			name:   "Options",
+			config: map[string]string{"pubsub.buffer": "8", "pubsub.backpressure": "disconnect"},
+			options: []contracts.SubscribeOption{
+				contracts.WithBuffer(2), contracts.WithBackpressure(contracts.DropOldest), contracts.AsMember("bob"),
+			},
+			want: contracts.SubscribeOptions{Buffer: 2, Backpressure: &dropOldest, Member: "bob"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			for key, value := range tc.config {
+				cfg.SetString(key, value)
+			}
+			if got := subscribeOptions(cfg, tc.options); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("subscribeOptions = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSQLMessageInline(t *testing.T) {
+	// Inline messages are decoded without the database.
+	p := &sqlPubSub{}
+	m, err := p.message(`{"t":"orders","d":{"id":1}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Topic != "orders" || string(m.Data) != `{"id":1}` {
+		t.Errorf("message = %s %s, want orders {\"id\":1}", m.Topic, m.Data)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pubsub_presence;
+DROP TABLE IF EXISTS pubsub_messages;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS pubsub_messages(
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS pubsub_presence(
+    topic VARCHAR(255) NOT NULL,
+    member VARCHAR(255) NOT NULL,
+    node VARCHAR(32) NOT NULL,
+    expire_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (topic, member, node)
+);
+
+CREATE INDEX IF NOT EXISTS pubsub_messages_created_at_idx ON pubsub_messages(created_at);
+CREATE INDEX IF NOT EXISTS pubsub_presence_expire_at_idx ON pubsub_presence(expire_at);
@@ -0,0 +1,109 @@
+// Package pubsub fans messages out to subscribers, e.g. to push updates to
+// the event streams and WebSockets of clients.
+package pubsub
+
+import (
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"strconv"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations returns the database migrations of the SQL pubsub.
+func Migrations() fs.FS {
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
+}
+
+const defaultBuffer = 64
+
+// NewPubSub creates the IPubSub selected by the `pubsub.backend`
+// configuration parameter; either "memory" (default), which only reaches
+// the subscribers of the same process, or "sql", which reaches the
+// subscribers of all replicas through PostgreSQL LISTEN/NOTIFY.
+func NewPubSub(config contracts.IConfigService, logger contracts.ILogger, db *sql.DB) contracts.IPubSub {
+	if config.String("pubsub.backend") == "sql" {
+		return NewSQLPubSub(config, logger, db, config.String("database.dsn"))
+	}
+	return NewMemoryPubSub(config)
+}
+
+// UserTopic returns the topic of the channel of a user.
+func UserTopic(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+// Stream sends the messages of sub as server-sent events of their topic
+// until the stream or the subscription is done, and closes sub.
+func Stream(stream contracts.IEventStream, sub contracts.ISubscription) error {
+	defer sub.Close()
+	for {
+		select {
+		case m, ok := <-sub.Messages():
+			if !ok {
+				return sub.Err()
+			}
+			if err := stream.Send(contracts.Event{Event: m.Topic, Data: []byte(m.Data)}); err != nil {
+				return err
+			}
+		case <-stream.Done():
+			return nil
+		}
+	}
+}
+
+// Push sends the messages of sub to a WebSocket as JSON until the socket or
+// the subscription is closed, and closes sub.
+func Push(socket contracts.IWebSocket, sub contracts.ISubscription) error {
+	defer sub.Close()
+	for {
+		select {
+		case m, ok := <-sub.Messages():
+			if !ok {
+				return sub.Err()
+			}
+			if err := socket.WriteJSON(m); err != nil {
+				return err
+			}
+		case <-socket.Done():
+			return nil
+		}
+	}
+}
+
+func encode(topic string, v interface{}) (contracts.Message, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return contracts.Message{}, err
+	}
+	return contracts.Message{Topic: topic, Data: data}, nil
+}
+
+// subscribeOptions applies options to the defaults of the configuration.
+func subscribeOptions(config contracts.IConfigService, options []contracts.SubscribeOption) contracts.SubscribeOptions {
+	var o contracts.SubscribeOptions
+	for _, option := range options {
+		option(&o)
+	}
+
+	if o.Buffer <= 0 {
+		o.Buffer = config.Int("pubsub.buffer")
+	}
+	if o.Buffer <= 0 {
+		o.Buffer = defaultBuffer
+	}
+	if o.Backpressure == nil {
+		policy := contracts.DropOldest
+		if config.String("pubsub.backpressure") == "disconnect" {
+			policy = contracts.Disconnect
+		}
+		o.Backpressure = &policy
+	}
+	return o
+}
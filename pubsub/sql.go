@@ -0,0 +1,293 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+
+	"github.com/lib/pq"
+)
+
+// channel is the PostgreSQL notification channel of all the topics.
+const channel = "leviathan_pubsub"
+
+// maxPayload is the largest notification sent inline. PostgreSQL limits
+// payloads to 8000 bytes, so larger messages are stored in the
+// `pubsub_messages` table and notified by their ID.
+const maxPayload = 7900
+
+// Presence rows expire unless their replica refreshes them, e.g. if it
+// crashes, and stored messages are deleted after messageTTL, by when the
+// replicas notified of them have loaded them. Both are timed by the clock of
+// the database, which the replicas share.
+const (
+	presenceTTL     = 45 * time.Second
+	refreshInterval = 15 * time.Second
+	messageTTL      = time.Minute
+)
+
+// envelope is the payload of a notification.
+type envelope struct {
+	Topic string          `json:"t,omitempty"`
+	Data  json.RawMessage `json:"d,omitempty"`
+	ID    int64           `json:"id,omitempty"`
+}
+
+type sqlPubSub struct {
+	*hub
+	db       *sql.DB
+	logger   contracts.ILogger
+	listener *pq.Listener
+	node     string
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewSQLPubSub creates an IPubSub which reaches the subscribers of all the
+// replicas sharing a PostgreSQL database. Messages are published by NOTIFY
+// and received by a connection to dsn which LISTENs to them. Messages
+// published while the connection is lost aren't received.
+//
+// Presence is tracked in the `pubsub_presence` table, where each replica
+// refreshes its members until they leave.
+func NewSQLPubSub(
+	config contracts.IConfigService,
+	logger contracts.ILogger,
+	db *sql.DB,
+	dsn string,
+) contracts.IPubSub {
+	p := &sqlPubSub{
+		hub:    newHub(config),
+		db:     db,
+		logger: logger,
+		node:   newNodeID(),
+		stop:   make(chan struct{}),
+	}
+	p.onJoin = p.join
+	p.onLeave = p.leave
+
+	p.listener = pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.WithFields(contracts.LogFields{
+				"error": err.Error(),
+			}).Error("pubsub listener failed")
+		}
+	})
+	if err := p.listener.Listen(channel); err != nil {
+		// The listener keeps reconnecting and listens once connected.
+		logger.WithFields(contracts.LogFields{
+			"error": err.Error(),
+		}).Error("cannot listen to pubsub notifications")
+	}
+
+	p.wg.Add(2)
+	go p.receive()
+	go p.refresh()
+	return p
+}
+
+// Publish implements IPubSub.Publish
+func (p *sqlPubSub) Publish(ctx context.Context, topic string, v interface{}) error {
+	if p.isClosed() {
+		return contracts.ErrPubSubClosed
+	}
+	m, err := encode(topic, v)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(envelope{Topic: m.Topic, Data: m.Data})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPayload {
+		var id int64
+		err := p.db.QueryRowContext(ctx,
+			`INSERT INTO pubsub_messages(topic, data) VALUES($1, $2) RETURNING id`,
+			m.Topic, string(m.Data),
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		payload, _ = json.Marshal(envelope{ID: id})
+	}
+
+	_, err = p.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, string(payload))
+	return err
+}
+
+// Subscribe implements IPubSub.Subscribe
+func (p *sqlPubSub) Subscribe(ctx context.Context, topic string, options ...contracts.SubscribeOption) (contracts.ISubscription, error) {
+	s, err := p.subscribe(ctx, topic, options)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// PublishToUser implements IPubSub.PublishToUser
+func (p *sqlPubSub) PublishToUser(ctx context.Context, userID int, v interface{}) error {
+	return p.Publish(ctx, UserTopic(userID), v)
+}
+
+// SubscribeUser implements IPubSub.SubscribeUser
+func (p *sqlPubSub) SubscribeUser(ctx context.Context, userID int, options ...contracts.SubscribeOption) (contracts.ISubscription, error) {
+	return p.Subscribe(ctx, UserTopic(userID), options...)
+}
+
+// Presence implements IPubSub.Presence
+func (p *sqlPubSub) Presence(ctx context.Context, topic string) ([]string, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT DISTINCT member FROM pubsub_presence WHERE topic = $1 AND expire_at > now() ORDER BY member`,
+		topic,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []string
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// Close implements IPubSub.Close
+func (p *sqlPubSub) Close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+		p.close()
+		err = p.listener.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = p.db.ExecContext(ctx, `DELETE FROM pubsub_presence WHERE node = $1`, p.node)
+	})
+	return err
+}
+
+// receive delivers the notifications of the listener to the subscribers of
+// this replica.
+func (p *sqlPubSub) receive() {
+	defer p.wg.Done()
+	for {
+		select {
+		case n := <-p.listener.Notify:
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				continue
+			}
+			m, err := p.message(n.Extra)
+			if err != nil {
+				p.logger.WithFields(contracts.LogFields{
+					"error": err.Error(),
+				}).Error("cannot receive pubsub message")
+				continue
+			}
+			p.deliver(m)
+		case <-time.After(90 * time.Second):
+			go func() {
+				_ = p.listener.Ping()
+			}()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// message decodes the payload of a notification, loading stored messages.
+func (p *sqlPubSub) message(payload string) (contracts.Message, error) {
+	var e envelope
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		return contracts.Message{}, err
+	}
+	if e.ID == 0 {
+		return contracts.Message{Topic: e.Topic, Data: e.Data}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var m contracts.Message
+	var data string
+	err := p.db.QueryRowContext(ctx,
+		`SELECT topic, data FROM pubsub_messages WHERE id = $1`, e.ID,
+	).Scan(&m.Topic, &data)
+	m.Data = json.RawMessage(data)
+	return m, err
+}
+
+// refresh keeps the presence of the members of this replica from expiring,
+// and deletes expired rows.
+func (p *sqlPubSub) refresh() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for topic, members := range p.members() {
+				for _, member := range members {
+					p.join(topic, member)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_, _ = p.db.ExecContext(ctx, `DELETE FROM pubsub_presence WHERE expire_at < now()`)
+			_, _ = p.db.ExecContext(ctx,
+				`DELETE FROM pubsub_messages WHERE created_at < now() - make_interval(secs => $1)`,
+				messageTTL.Seconds(),
+			)
+			cancel()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *sqlPubSub) join(topic, member string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO pubsub_presence(topic, member, node, expire_at)
+			VALUES($1, $2, $3, now() + make_interval(secs => $4))
+			ON CONFLICT (topic, member, node) DO UPDATE SET expire_at = EXCLUDED.expire_at`,
+		topic, member, p.node, presenceTTL.Seconds(),
+	)
+	if err != nil {
+		p.logger.WithFields(contracts.LogFields{
+			"topic": topic,
+			"error": err.Error(),
+		}).Error("cannot track presence")
+	}
+}
+
+func (p *sqlPubSub) leave(topic, member string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = p.db.ExecContext(ctx,
+		`DELETE FROM pubsub_presence WHERE topic = $1 AND member = $2 AND node = $3`,
+		topic, member, p.node,
+	)
+}
+
+// newNodeID returns a random ID of this replica.
+func newNodeID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
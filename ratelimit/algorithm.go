@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// bucket is the state of a token bucket. A zero bucket is full.
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// window is the state of a sliding window: the counters of the current
+// fixed window, which starts at start, and of the previous one.
+type window struct {
+	start    time.Time
+	count    int
+	previous int
+}
+
+// normalize fills in the defaults of a rate limit.
+func normalize(limit contracts.RateLimit) contracts.RateLimit {
+	if limit.Requests <= 0 {
+		limit.Requests = 60
+	}
+	if limit.Period <= 0 {
+		limit.Period = time.Minute
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Requests
+	}
+	return limit
+}
+
+// ttl is how long the state of a limit is worth keeping after a request.
+func ttl(limit contracts.RateLimit) time.Duration {
+	if limit.Algorithm == contracts.SlidingWindow {
+		return 2 * limit.Period
+	}
+	return time.Duration(float64(limit.Burst) / rate(limit))
+}
+
+// rate is the refill rate of a token bucket, in tokens per nanosecond.
+func rate(limit contracts.RateLimit) float64 {
+	return float64(limit.Requests) / float64(limit.Period)
+}
+
+// takeToken refills the bucket up to now and takes a token from it if any.
+func takeToken(b bucket, limit contracts.RateLimit, now time.Time) (bucket, *contracts.RateLimitResult) {
+	burst := float64(limit.Burst)
+	if b.updatedAt.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.updatedAt); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+float64(elapsed)*rate(limit))
+	}
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return b, bucketResult(b.tokens, allowed, limit)
+}
+
+func bucketResult(tokens float64, allowed bool, limit contracts.RateLimit) *contracts.RateLimitResult {
+	result := &contracts.RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(limit.Burst) - tokens) / rate(limit)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / rate(limit))
+	}
+	return result
+}
+
+// slide moves the window up to now and counts the request if the weighted
+// count allows it.
+func slide(w window, limit contracts.RateLimit, now time.Time) (window, *contracts.RateLimitResult) {
+	start := now.Truncate(limit.Period)
+	switch {
+	case w.start.Equal(start):
+	case w.start.Add(limit.Period).Equal(start):
+		w.previous, w.count = w.count, 0
+	default:
+		w.previous, w.count = 0, 0
+	}
+	w.start = start
+
+	allowed := weightedCount(w, limit, now) <= float64(limit.Requests-1)
+	if allowed {
+		w.count++
+	}
+
+	return w, windowResult(w, allowed, limit, now)
+}
+
+// weightedCount approximates the number of requests in the last period,
+// assuming the requests of the previous window were evenly distributed.
+func weightedCount(w window, limit contracts.RateLimit, now time.Time) float64 {
+	weight := 1 - float64(now.Sub(w.start))/float64(limit.Period)
+	return float64(w.previous)*weight + float64(w.count)
+}
+
+func windowResult(w window, allowed bool, limit contracts.RateLimit, now time.Time) *contracts.RateLimitResult {
+	remaining := limit.Requests - int(math.Ceil(weightedCount(w, limit, now)))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	end := w.start.Add(limit.Period)
+	result := &contracts.RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: remaining,
+		Reset:     end.Sub(now),
+	}
+
+	if !allowed {
+		// Find when the weighted count drops to limit-1, either later in
+		// this window as the previous one fades out, or in the next window
+		// as this one does.
+		target := float64(limit.Requests - 1)
+		var at time.Time
+		if w.count <= int(target) && w.previous > 0 {
+			fraction := 1 - (target-float64(w.count))/float64(w.previous)
+			at = w.start.Add(time.Duration(fraction * float64(limit.Period)))
+		} else {
+			fraction := 1 - target/float64(w.count)
+			at = end.Add(time.Duration(fraction * float64(limit.Period)))
+		}
+		result.RetryAfter = at.Sub(now)
+	}
+
+	return result
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// take is a request at an offset from the start of a test, and its expected
+// result.
+type take struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+func TestTakeToken(t *testing.T) {
+	cases := []struct {
+		name  string
+		limit contracts.RateLimit
+		takes []take
+	}{
+		{
+			name:  "Burst",
+			limit: contracts.RateLimit{Requests: 2, Period: time.Second},
+			takes: []take{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond},
+			},
+		},
+		{
+			name:  "Refill",
+			limit: contracts.RateLimit{Requests: 2, Period: time.Second},
+			takes: []take{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{at: 500 * time.Millisecond, allowed: true, remaining: 0},
+				{at: 2 * time.Second, allowed: true, remaining: 1},
+			},
+		},
+		{
+			name:  "BurstAboveRate",
+			limit: contracts.RateLimit{Requests: 1, Period: time.Second, Burst: 3},
+			takes: []take{
+				{allowed: true, remaining: 2},
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{allowed: false, remaining: 0, retryAfter: time.Second},
+			},
+		},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			limit := normalize(tc.limit)
+			var b bucket
+			for i, want := range tc.takes {
+				var result *contracts.RateLimitResult
+				b, result = takeToken(b, limit, start.Add(want.at))
+				checkTake(t, i, result, want)
+			}
+		})
+	}
+}
+
+func TestSlide(t *testing.T) {
+	cases := []struct {
+		name  string
+		limit contracts.RateLimit
+		takes []take
+	}{
+		{
+			name:  "Window",
+			limit: contracts.RateLimit{Requests: 2, Period: time.Minute, Algorithm: contracts.SlidingWindow},
+			takes: []take{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{at: 30 * time.Second, allowed: false, remaining: 0, retryAfter: 60 * time.Second},
+			},
+		},
+		{
+			name:  "PreviousWindowFades",
+			limit: contracts.RateLimit{Requests: 2, Period: time.Minute, Algorithm: contracts.SlidingWindow},
+			takes: []take{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				// Half into the next window, the previous one counts as 1.
+				{at: 90 * time.Second, allowed: true, remaining: 0},
+				{at: 90 * time.Second, allowed: false, remaining: 0, retryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name:  "WindowsExpire",
+			limit: contracts.RateLimit{Requests: 1, Period: time.Minute, Algorithm: contracts.SlidingWindow},
+			takes: []take{
+				{allowed: true, remaining: 0},
+				{at: 3 * time.Minute, allowed: true, remaining: 0},
+			},
+		},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			limit := normalize(tc.limit)
+			var w window
+			for i, want := range tc.takes {
+				var result *contracts.RateLimitResult
+				w, result = slide(w, limit, start.Add(want.at))
+				checkTake(t, i, result, want)
+			}
+		})
+	}
+}
+
+func checkTake(t *testing.T, i int, result *contracts.RateLimitResult, want take) {
+	t.Helper()
+	if result.Allowed != want.allowed || result.Remaining != want.remaining {
+		t.Errorf("take %d: allowed %v with %d remaining, want %v with %d",
+			i, result.Allowed, result.Remaining, want.allowed, want.remaining)
+	}
+	if diff := result.RetryAfter - want.retryAfter; diff < -time.Millisecond || diff > time.Millisecond {
+		t.Errorf("take %d: RetryAfter = %v, want %v", i, result.RetryAfter, want.retryAfter)
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// sweepInterval is how often expired counters are removed from memory.
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	bucket   bucket
+	window   window
+	expireAt time.Time
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	sweptAt time.Time
+}
+
+// NewMemoryStore creates an IRateLimitStore which keeps counters in memory.
+// Counters aren't shared between replicas.
+func NewMemoryStore() contracts.IRateLimitStore {
+	return &memoryStore{
+		entries: make(map[string]*memoryEntry),
+	}
+}
+
+// Take implements IRateLimitStore.Take
+func (s *memoryStore) Take(ctx context.Context, key string, limit contracts.RateLimit) (*contracts.RateLimitResult, error) {
+	limit = normalize(limit)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+	entry.expireAt = now.Add(ttl(limit))
+
+	var result *contracts.RateLimitResult
+	if limit.Algorithm == contracts.SlidingWindow {
+		entry.window, result = slide(entry.window, limit, now)
+	} else {
+		entry.bucket, result = takeToken(entry.bucket, limit, now)
+	}
+
+	return result, nil
+}
+
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.sweptAt) < sweepInterval {
+		return
+	}
+	s.sweptAt = now
+
+	for key, entry := range s.entries {
+		if entry.expireAt.Before(now) {
+			delete(s.entries, key)
+		}
+	}
+}
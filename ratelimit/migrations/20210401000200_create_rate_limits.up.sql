@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS rate_limits(
+    name VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL DEFAULT 0,
+    count INT NOT NULL DEFAULT 0,
+    previous INT NOT NULL DEFAULT 0,
+    stamp BIGINT NOT NULL DEFAULT 0,
+    expire_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limits_expire_at_idx ON rate_limits(expire_at);
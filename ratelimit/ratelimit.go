@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/netutil"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations returns the database migrations of the SQL rate limit store.
+func Migrations() fs.FS {
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
+}
+
+// NewStore creates the IRateLimitStore selected by the
+// `server.ratelimit.store` configuration parameter; either "memory"
+// (default), "sql" or "redis". The redis store is configured by
+// `server.ratelimit.redis.address`, `password`, `db` and `pool_size`.
+func NewStore(config contracts.IConfigService, db *sql.DB) contracts.IRateLimitStore {
+	switch config.String("server.ratelimit.store") {
+	case "sql":
+		return NewSQLStore(db)
+	case "redis":
+		return NewRedisStore(
+			config.String("server.ratelimit.redis.address"),
+			config.String("server.ratelimit.redis.password"),
+			config.Int("server.ratelimit.redis.db"),
+			config.Int("server.ratelimit.redis.pool_size"),
+		)
+	}
+	return NewMemoryStore()
+}
+
+type rateLimiter struct {
+	config contracts.IConfigService
+	logger contracts.ILogger
+	store  contracts.IRateLimitStore
+}
+
+// NewRateLimiter creates a new IRateLimiter.
+func NewRateLimiter(
+	config contracts.IConfigService,
+	logger contracts.ILogger,
+	store contracts.IRateLimitStore,
+) contracts.IRateLimiter {
+	return &rateLimiter{
+		config: config,
+		logger: logger,
+		store:  store,
+	}
+}
+
+// ByIP counts requests by the client IP, as netutil.ClientIP finds it
+// behind the netutil.TrustedProxies.
+func ByIP(config contracts.IConfigService) contracts.RateLimitKey {
+	return func(server contracts.IServer) string {
+		return "ip:" + netutil.ClientIP(server.Request(), netutil.TrustedProxies(config))
+	}
+}
+
+// ByUser counts requests by the logged in user's ID, and anonymous requests
+// by fallback.
+func ByUser(fallback contracts.RateLimitKey) contracts.RateLimitKey {
+	return func(server contracts.IServer) string {
+		if user := server.User(); user != nil {
+			return "user:" + strconv.Itoa(user.ID)
+		}
+		return fallback(server)
+	}
+}
+
+// Limit implements IRateLimiter.Limit
+func (s *rateLimiter) Limit(limit contracts.RateLimit, handler contracts.Handler) contracts.Handler {
+	key := limit.Key
+	if key == nil {
+		key = ByIP(s.config)
+	}
+
+	return func(server contracts.IServer) error {
+		result, err := s.Allow(server.Request().Context(), limit.Name+"|"+key(server), limit)
+		if err != nil {
+			// Rather serve than fail when the store is unavailable.
+			s.logger.WithFields(contracts.LogFields{
+				"limit": limit.Name,
+				"error": err.Error(),
+			}).Error("cannot check rate limit")
+			return handler(server)
+		}
+
+		setHeaders(server, result)
+		if !result.Allowed {
+			server.SetHeader("Retry-After", seconds(result.RetryAfter))
+			return server.JSON(http.StatusTooManyRequests, &models.Error{
+				Message: contracts.ErrTooManyRequests.Error(),
+				Code:    http.StatusTooManyRequests,
+			})
+		}
+
+		return handler(server)
+	}
+}
+
+// Global implements IRateLimiter.Global
+func (s *rateLimiter) Global(handler contracts.Handler) contracts.Handler {
+	return func(server contracts.IServer) error {
+		limit, ok := s.globalLimit()
+		if !ok {
+			return handler(server)
+		}
+		return s.Limit(limit, handler)(server)
+	}
+}
+
+// globalLimit reads the default limit of all routes from the configuration.
+func (s *rateLimiter) globalLimit() (contracts.RateLimit, bool) {
+	limit := contracts.RateLimit{
+		Name:     "global",
+		Requests: s.config.Int("server.ratelimit.requests"),
+		Period:   time.Duration(s.config.Int("server.ratelimit.period")) * time.Second,
+		Burst:    s.config.Int("server.ratelimit.burst"),
+		Key:      ByIP(s.config),
+	}
+	if s.config.String("server.ratelimit.algorithm") == "sliding_window" {
+		limit.Algorithm = contracts.SlidingWindow
+	}
+	if s.config.String("server.ratelimit.key") == "user" {
+		limit.Key = ByUser(limit.Key)
+	}
+	return limit, limit.Requests > 0
+}
+
+// Allow implements IRateLimiter.Allow
+func (s *rateLimiter) Allow(ctx context.Context, key string, limit contracts.RateLimit) (*contracts.RateLimitResult, error) {
+	return s.store.Take(ctx, key, limit)
+}
+
+// setHeaders sets the `RateLimit-*` headers of the IETF draft "RateLimit
+// header fields for HTTP".
+func setHeaders(server contracts.IServer, result *contracts.RateLimitResult) {
+	server.SetHeader("RateLimit-Limit", strconv.Itoa(result.Limit))
+	server.SetHeader("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	server.SetHeader("RateLimit-Reset", seconds(result.Reset))
+}
+
+// seconds formats a duration as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	limit := contracts.RateLimit{Requests: 2, Period: time.Minute}
+	for i, want := range []bool{true, true, false} {
+		result, err := s.Take(context.Background(), "a", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != want {
+			t.Errorf("request %d: Allowed = %v, want %v", i, result.Allowed, want)
+		}
+	}
+
+	// Keys are counted separately.
+	result, err := s.Take(context.Background(), "b", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != 1 {
+		t.Errorf("other key: %+v, want allowed with 1 remaining", result)
+	}
+}
@@ -0,0 +1,347 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// scriptClock sets now to the time of the Redis server, in microseconds,
+// which fit in Lua numbers, so the replicas of the app share a clock.
+// Scripts reading the time must replicate their effects rather than
+// themselves, which is the default since Redis 5.
+const scriptClock = `
+if redis.replicate_commands then redis.replicate_commands() end
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+`
+
+// tokenBucketScript refills and takes a token from the bucket hash KEYS[1].
+var tokenBucketScript = newScript(scriptClock + `
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(state[1])
+local at = tonumber(state[2])
+if tokens == nil then
+  tokens = burst
+  at = now
+elseif now > at then
+  tokens = math.min(burst, tokens + (now - at) * rate)
+  at = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', string.format('%.6f', tokens), 'at', string.format('%.0f', at))
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {allowed, string.format('%.6f', tokens)}
+`)
+
+// slidingWindowScript moves the window hash KEYS[1] up to now, like slide,
+// and counts a request in it if the weighted count allows. It returns the
+// start of the window and now, in microseconds, along with the counters.
+var slidingWindowScript = newScript(scriptClock + `
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local start = now - now % period
+local state = redis.call('HMGET', KEYS[1], 'start', 'count', 'previous')
+local last = tonumber(state[1])
+local count = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+if last ~= start then
+  if last == start - period then
+    previous = count
+  else
+    previous = 0
+  end
+  count = 0
+end
+local allowed = 0
+if previous * (1 - (now - start) / period) + count <= limit - 1 then
+  count = count + 1
+  allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'start', string.format('%.0f', start), 'count', count, 'previous', previous)
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {allowed, count, previous, string.format('%.0f', start), string.format('%.0f', now)}
+`)
+
+// script is a Lua script, which is run by its SHA1 digest once the server
+// has it cached.
+type script struct {
+	source string
+	sha1   string
+}
+
+func newScript(source string) *script {
+	sum := sha1.Sum([]byte(source))
+	return &script{source: source, sha1: hex.EncodeToString(sum[:])}
+}
+
+type redisStore struct {
+	address  string
+	password string
+	db       int
+	conns    chan *respConn
+}
+
+// NewRedisStore creates an IRateLimitStore which keeps counters in a server
+// speaking the Redis protocol, e.g. Redis, KeyDB or Dragonfly. Counters are
+// updated by Lua scripts by the clock of the server, so replicas can share
+// them.
+func NewRedisStore(address, password string, db, poolSize int) contracts.IRateLimitStore {
+	if poolSize <= 0 {
+		poolSize = 10
+	}
+	return &redisStore{
+		address:  address,
+		password: password,
+		db:       db,
+		conns:    make(chan *respConn, poolSize),
+	}
+}
+
+// Take implements IRateLimitStore.Take
+func (s *redisStore) Take(ctx context.Context, key string, limit contracts.RateLimit) (*contracts.RateLimitResult, error) {
+	limit = normalize(limit)
+	expiry := strconv.FormatInt(ttl(limit).Milliseconds()+1000, 10)
+
+	if limit.Algorithm == contracts.SlidingWindow {
+		reply, err := s.eval(ctx, slidingWindowScript,
+			[]string{"ratelimit:{" + key + "}:window"},
+			strconv.Itoa(limit.Requests), strconv.FormatInt(limit.Period.Microseconds(), 10), expiry,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		values, err := replyInts(reply, 5)
+		if err != nil {
+			return nil, err
+		}
+		w := window{
+			start:    time.UnixMicro(values[3]),
+			count:    int(values[1]),
+			previous: int(values[2]),
+		}
+		return windowResult(w, values[0] == 1, limit, time.UnixMicro(values[4])), nil
+	}
+
+	reply, err := s.eval(ctx, tokenBucketScript,
+		[]string{"ratelimit:{" + key + "}"},
+		strconv.Itoa(limit.Burst),
+		strconv.FormatFloat(rate(limit)*float64(time.Microsecond), 'g', -1, 64),
+		expiry,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("unexpected reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	raw, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return bucketResult(tokens, allowed == 1, limit), nil
+}
+
+// eval runs script by EVALSHA, sending it by EVAL if the server doesn't
+// have it cached yet.
+func (s *redisStore) eval(ctx context.Context, script *script, keys []string, args ...string) (interface{}, error) {
+	params := append([]string{strconv.Itoa(len(keys))}, keys...)
+	params = append(params, args...)
+
+	conn, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(ctx, append([]string{"EVALSHA", script.sha1}, params...)...)
+	var redisErr redisError
+	if errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "NOSCRIPT") {
+		reply, err = conn.do(ctx, append([]string{"EVAL", script.source}, params...)...)
+	}
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection is in an unknown state.
+		_ = conn.Close()
+		return nil, err
+	}
+	s.put(conn)
+
+	return reply, err
+}
+
+func (s *redisStore) get(ctx context.Context) (*respConn, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	default:
+	}
+
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, err
+	}
+	conn := &respConn{Conn: nc, reader: bufio.NewReader(nc)}
+
+	if s.password != "" {
+		if _, err := conn.do(ctx, "AUTH", s.password); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(s.db)); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (s *redisStore) put(conn *respConn) {
+	select {
+	case s.conns <- conn:
+	default:
+		_ = conn.Close()
+	}
+}
+
+// replyInts reads an array of n integers, which may be sent as strings.
+func replyInts(reply interface{}, n int) ([]int64, error) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != n {
+		return nil, fmt.Errorf("unexpected reply %v", reply)
+	}
+
+	ints := make([]int64, n)
+	for i, value := range values {
+		switch value := value.(type) {
+		case int64:
+			ints[i] = value
+		case string:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected reply %v", reply)
+			}
+			ints[i] = n
+		default:
+			return nil, fmt.Errorf("unexpected reply %v", reply)
+		}
+	}
+	return ints, nil
+}
+
+/********** Redis protocol **********/
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (err redisError) Error() string {
+	return string(err)
+}
+
+// respConn is a connection speaking RESP, the Redis serialization protocol.
+type respConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// do sends a command and reads its reply, which is either a string, an
+// int64, nil or a []interface{} of those.
+func (c *respConn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	_ = c.SetDeadline(deadline)
+
+	buf := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return c.read()
+}
+
+func (c *respConn) read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, redisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		// Error replies nested in the array are returned once the whole
+		// array is read, so the next reply starts where it should.
+		var nested error
+		values := make([]interface{}, n)
+		for i := range values {
+			values[i], err = c.read()
+			var redisErr redisError
+			if errors.As(err, &redisErr) {
+				if nested == nil {
+					nested = err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if nested != nil {
+			return nil, nested
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("malformed reply %q", line)
+}
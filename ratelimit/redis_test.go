@@ -0,0 +1,192 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// fakeRedis is a server speaking RESP, which replies to each command by
+// reply, and counts its connections and records its commands.
+type fakeRedis struct {
+	net.Listener
+	reply func(args []string) string
+
+	mu       sync.Mutex
+	conns    int
+	commands [][]string
+}
+
+func newFakeRedis(t *testing.T, reply func(args []string) string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{Listener: l, reply: reply}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		s.mu.Unlock()
+		if _, err := conn.Write([]byte(s.reply(args))); err != nil {
+			return
+		}
+	}
+}
+
+// names returns the names of the commands received.
+func (s *fakeRedis) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, command := range s.commands {
+		names = append(names, command[0])
+	}
+	return names
+}
+
+func TestRedisStoreEvalSHA(t *testing.T) {
+	var mu sync.Mutex
+	cached := map[string]bool{}
+	s := newFakeRedis(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "EVALSHA":
+			if !cached[args[1]] {
+				return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+			}
+		case "EVAL":
+			cached[newScript(args[1]).sha1] = true
+		}
+		return "*2\r\n:1\r\n$8\r\n9.000000\r\n"
+	})
+
+	store := NewRedisStore(s.Addr().String(), "", 0, 1)
+	limit := contracts.RateLimit{Requests: 10, Period: time.Minute}
+	for i := 0; i < 2; i++ {
+		result, err := store.Take(context.Background(), "key", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != 9 {
+			t.Errorf("result = %+v, want allowed with 9 remaining", result)
+		}
+	}
+
+	// The script is sent once, then run by its digest.
+	want := []string{"EVALSHA", "EVAL", "EVALSHA"}
+	if got := s.names(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+}
+
+func TestRedisStoreNestedError(t *testing.T) {
+	var mu sync.Mutex
+	replies := []string{
+		"*2\r\n-ERR nested\r\n:1\r\n",
+		"*2\r\n:1\r\n$8\r\n4.000000\r\n",
+	}
+	s := newFakeRedis(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		reply := replies[0]
+		replies = replies[1:]
+		return reply
+	})
+
+	store := NewRedisStore(s.Addr().String(), "", 0, 1)
+	limit := contracts.RateLimit{Requests: 5, Period: time.Minute}
+	if _, err := store.Take(context.Background(), "key", limit); err == nil || !strings.Contains(err.Error(), "nested") {
+		t.Fatalf("Take() = %v, want the nested error", err)
+	}
+
+	// The rest of the array is read, so the connection is reused in sync.
+	result, err := store.Take(context.Background(), "key", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Remaining != 4 {
+		t.Errorf("Remaining = %d, want 4", result.Remaining)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns != 1 {
+		t.Errorf("connections = %d, want 1", s.conns)
+	}
+}
+
+func TestRedisStoreServerClock(t *testing.T) {
+	// A minute into the window of the server, which is far from the clock
+	// of the test.
+	start := time.Date(2001, 2, 3, 4, 5, 0, 0, time.UTC)
+	now := start.Add(15 * time.Second)
+	bulk := func(n int64) string {
+		s := strconv.FormatInt(n, 10)
+		return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+	}
+	s := newFakeRedis(t, func(args []string) string {
+		return "*5\r\n:1\r\n:3\r\n:2\r\n" + bulk(start.UnixMicro()) + bulk(now.UnixMicro())
+	})
+
+	store := NewRedisStore(s.Addr().String(), "", 0, 1)
+	limit := contracts.RateLimit{Requests: 60, Period: time.Minute, Algorithm: contracts.SlidingWindow}
+	result, err := store.Take(context.Background(), "key", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	if period := s.commands[0][5]; period != "60000000" {
+		t.Errorf("period = %s, want 60000000 microseconds", period)
+	}
+	s.mu.Unlock()
+	// 2 requests of the previous window weigh 1.5, and 3 of this one.
+	if !result.Allowed || result.Remaining != 55 || result.Reset != 45*time.Second {
+		t.Errorf("result = %+v, want allowed with 55 remaining, reset in 45s", result)
+	}
+}
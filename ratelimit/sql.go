@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+type sqlStore struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	sweptAt time.Time
+}
+
+// NewSQLStore creates an IRateLimitStore which keeps counters in the
+// `rate_limits` table. Rows are locked while they are updated, so replicas
+// can share the counters.
+func NewSQLStore(db *sql.DB) contracts.IRateLimitStore {
+	return &sqlStore{db: db}
+}
+
+// Take implements IRateLimitStore.Take
+func (s *sqlStore) Take(ctx context.Context, key string, limit contracts.RateLimit) (*contracts.RateLimitResult, error) {
+	limit = normalize(limit)
+	now := time.Now()
+	expireAt := now.Add(ttl(limit))
+
+	s.sweep(ctx, now)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO rate_limits(name, expire_at) VALUES($1, $2) ON CONFLICT (name) DO NOTHING`,
+		key, expireAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens float64
+	var count, previous int
+	var stamp int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT tokens, count, previous, stamp FROM rate_limits WHERE name = $1 FOR UPDATE`, key,
+	).Scan(&tokens, &count, &previous, &stamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *contracts.RateLimitResult
+	if limit.Algorithm == contracts.SlidingWindow {
+		w := window{count: count, previous: previous}
+		if stamp != 0 {
+			w.start = time.Unix(0, stamp)
+		}
+		w, result = slide(w, limit, now)
+		count, previous, stamp = w.count, w.previous, w.start.UnixNano()
+	} else {
+		b := bucket{tokens: tokens}
+		if stamp != 0 {
+			b.updatedAt = time.Unix(0, stamp)
+		}
+		b, result = takeToken(b, limit, now)
+		tokens, stamp = b.tokens, b.updatedAt.UnixNano()
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE rate_limits SET tokens = $1, count = $2, previous = $3, stamp = $4, expire_at = $5
+			WHERE name = $6`,
+		tokens, count, previous, stamp, expireAt, key,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
+
+// sweep deletes the expired counters once in a while.
+func (s *sqlStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.sweptAt) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.sweptAt = now
+	s.mu.Unlock()
+
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE expire_at < $1`, now)
+}
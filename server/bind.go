@@ -0,0 +1,108 @@
+package services
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/encoder"
+)
+
+// bind decodes a request into in like the default binder of echo does: the
+// query string of GET and DELETE requests with no body, and otherwise the
+// form. Other bodies are decoded by the encoders of the container.
+func bind(r *http.Request, in interface{}) error {
+	if r.ContentLength == 0 {
+		if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+			return bindValues(in, r.URL.Query(), "query")
+		}
+		return errors.New("request body can't be empty")
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") &&
+		!strings.HasPrefix(contentType, "multipart/form-data") {
+		return fmt.Errorf("unsupported media type %q", contentType)
+	}
+
+	form, err := formValues(r)
+	if err != nil {
+		return err
+	}
+	return bindValues(in, form, "form")
+}
+
+// formValues parses the form of a request, including its query string.
+func formValues(r *http.Request) (map[string][]string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+	} else if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	return r.Form, nil
+}
+
+// bindValues sets the fields of the struct which ptr points to from data by
+// their tag. Fields with no tag are matched by name, case-insensitively, and
+// untagged struct fields are bound recursively.
+func bindValues(ptr interface{}, data map[string][]string, tag string) error {
+	val := reflect.ValueOf(ptr)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return errors.New("binding element must be a pointer to a struct")
+	}
+	val = val.Elem()
+	typ := val.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		value := val.Field(i)
+		if !value.CanSet() {
+			continue
+		}
+
+		name := field.Tag.Get(tag)
+		if name == "" {
+			name = field.Name
+			if _, ok := value.Addr().Interface().(encoding.TextUnmarshaler); !ok && value.Kind() == reflect.Struct {
+				if err := bindValues(value.Addr().Interface(), data, tag); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		input, ok := data[name]
+		if !ok {
+			for key, values := range data {
+				if strings.EqualFold(key, name) {
+					input, ok = values, true
+					break
+				}
+			}
+		}
+		if !ok || len(input) == 0 {
+			continue
+		}
+
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 {
+			slice := reflect.MakeSlice(value.Type(), len(input), len(input))
+			for j, item := range input {
+				if err := encoder.ParseValue(slice.Index(j), item); err != nil {
+					return err
+				}
+			}
+			value.Set(slice)
+			continue
+		}
+
+		if err := encoder.ParseValue(value, input[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/utils"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// NewServerContainer creates the IServerContainer selected by the
+// `server.backend` configuration parameter; either "echo" (default) or
+// "http", which is built on net/http only.
+func NewServerContainer(
+	config contracts.IConfigService,
+	logger contracts.ILogger,
+	rateLimiter contracts.IRateLimiter,
+	storage contracts.IStorage,
+) contracts.IServerContainer {
+	if config.String("server.backend") == "http" {
+		return NewHTTPServerContainer(config, logger, rateLimiter, storage)
+	}
+	return NewEchoServerContainer(config, logger, rateLimiter, storage)
+}
+
+// serve serves handler on address until server is shut down.
+//
+// The server speaks TLS and HTTP/2 if `server.tls.cert_file` is set.
+// Otherwise it speaks plain HTTP/1.1, and also HTTP/2 without TLS (h2c) if
+// `server.h2c` is set, e.g. behind a reverse proxy.
+func serve(
+	server *http.Server,
+	handler http.Handler,
+	config contracts.IConfigService,
+	logger contracts.ILogger,
+	address string,
+) error {
+	tlsConfig, err := newTLSConfig(config, logger)
+	if err != nil {
+		return err
+	}
+
+	server.Addr = address
+	server.Handler = handler
+	server.TLSConfig = tlsConfig
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	if tlsConfig != nil {
+		logger.WithFields(contracts.LogFields{
+			"address": listener.Addr().String(),
+		}).Info("https server started")
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		if config.Bool("server.h2c") {
+			server.Handler = h2c.NewHandler(handler, &http2.Server{})
+		}
+		logger.WithFields(contracts.LogFields{
+			"address": listener.Addr().String(),
+		}).Info("http server started")
+		err = server.Serve(listener)
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// securedBy returns the SecureRoutes prefix which path falls under.
+func securedBy(roles map[string][]string, path string) (string, bool) {
+	for prefix := range roles {
+		if strings.Contains(path, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// detectApp determines the client app using the `User-Agent` header.
+// Android app is known to send `okhttp/X.Y.Z`.
+func detectApp(userAgent string) models.App {
+	if strings.Contains(userAgent, "okhttp") {
+		return models.AndroidApp
+	} else if utils.IsMobileWeb(userAgent) {
+		return models.MobileWebApp
+	}
+	return models.WebApp
+}
+
+// renderTemplate executes the template `templates/<name>.gohtml` under the
+// static directory.
+func renderTemplate(config contracts.IConfigService, name string, data interface{}) ([]byte, error) {
+	tmplPath := filepath.Join(
+		config.StaticDir(),
+		fmt.Sprintf("templates/%s.gohtml", name),
+	)
+	tmpl, err := template.ParseFiles(tmplPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
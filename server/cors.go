@@ -0,0 +1,213 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch,
+	http.MethodPost, http.MethodDelete, http.MethodOptions,
+}
+
+// corsPolicy is the parsed form of the `server.cors.*` configuration
+// parameters.
+type corsPolicy struct {
+	origins          []string
+	methods          string
+	headers          string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           int
+}
+
+// corsMiddleware handles CORS according to the `server.cors.*` configuration
+// parameters, which are read on every request so config changes take effect
+// without a restart:
+//
+//   - allow_origins: comma-separated origins, e.g.
+//     `https://example.com, https://*.example.com`. Defaults to `*`.
+//   - allow_methods: comma-separated methods. Defaults to all common methods.
+//   - allow_headers: comma-separated headers. Defaults to the requested ones.
+//   - expose_headers: comma-separated headers exposed to the client, on top
+//     of the headers of resumable uploads.
+//   - allow_credentials: whether cookies and authorization are allowed,
+//     which is ignored if any origin is, i.e. with `*` in allow_origins.
+//   - max_age: how many seconds the preflight response may be cached.
+func corsMiddleware(config contracts.IConfigService) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	var cacheKey string
+	var policy *corsPolicy
+
+	load := func() *corsPolicy {
+		key := strings.Join([]string{
+			config.String("server.cors.allow_origins"),
+			config.String("server.cors.allow_methods"),
+			config.String("server.cors.allow_headers"),
+			config.String("server.cors.expose_headers"),
+			strconv.FormatBool(config.Bool("server.cors.allow_credentials")),
+			strconv.Itoa(config.Int("server.cors.max_age")),
+		}, "\n")
+
+		mu.Lock()
+		defer mu.Unlock()
+		if policy == nil || key != cacheKey {
+			policy = newCORSPolicy(config)
+			cacheKey = key
+		}
+		return policy
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			header := w.Header()
+			origin := req.Header.Get("Origin")
+			preflight := req.Method == http.MethodOptions &&
+				req.Header.Get("Access-Control-Request-Method") != ""
+
+			header.Add("Vary", "Origin")
+			if origin == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			p := load()
+			allowed, ok := p.allowOrigin(origin)
+			if !ok {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Origin", allowed)
+			if p.allowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if p.exposeHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+				}
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", p.methods)
+
+			headers := p.headers
+			if headers == "" {
+				headers = req.Header.Get("Access-Control-Request-Headers")
+			}
+			if headers != "" {
+				header.Set("Access-Control-Allow-Headers", headers)
+			}
+			if p.maxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(p.maxAge))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func newCORSPolicy(config contracts.IConfigService) *corsPolicy {
+	p := &corsPolicy{
+		origins:          splitList(config.String("server.cors.allow_origins")),
+		methods:          strings.Join(splitList(config.String("server.cors.allow_methods")), ","),
+		headers:          strings.Join(splitList(config.String("server.cors.allow_headers")), ","),
+		exposeHeaders:    strings.Join(mergeHeaders(splitList(config.String("server.cors.expose_headers")), tusHeaders), ","),
+		allowCredentials: config.Bool("server.cors.allow_credentials"),
+		maxAge:           config.Int("server.cors.max_age"),
+	}
+
+	if len(p.origins) == 0 {
+		p.origins = []string{"*"}
+	}
+	for _, origin := range p.origins {
+		if origin == "*" {
+			// Credentials of any origin would let any site act on behalf of
+			// the logged in users, so the wildcard goes without them.
+			p.allowCredentials = false
+		}
+	}
+	if p.methods == "" {
+		p.methods = strings.Join(defaultCORSMethods, ",")
+	}
+	if p.headers == "*" {
+		// Reflect the requested headers, which also works with credentials.
+		p.headers = ""
+	}
+
+	return p
+}
+
+// allowOrigin returns the value of the `Access-Control-Allow-Origin` header
+// for an origin and whether the origin is allowed at all.
+func (p *corsPolicy) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range p.origins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(allowed, origin) || matchWildcardOrigin(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// matchWildcardOrigin matches origins against patterns like
+// `https://*.example.com`, which match any subdomain but not the domain
+// itself.
+func matchWildcardOrigin(pattern, origin string) bool {
+	i := strings.Index(pattern, "://*.")
+	if i < 0 {
+		return false
+	}
+	scheme, suffix := pattern[:i+3], pattern[i+4:]
+
+	origin = strings.ToLower(origin)
+	if !strings.HasPrefix(origin, strings.ToLower(scheme)) {
+		return false
+	}
+	host := origin[len(scheme):]
+	return len(host) > len(suffix) && strings.HasSuffix(host, strings.ToLower(suffix))
+}
+
+// splitList splits a comma-separated configuration parameter.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// mergeHeaders appends the headers of extra missing from headers, which are
+// compared case-insensitively.
+func mergeHeaders(headers, extra []string) []string {
+	merged := headers[:len(headers):len(headers)]
+	for _, header := range extra {
+		found := false
+		for _, h := range headers {
+			if strings.EqualFold(h, header) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, header)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+)
+
+func TestCORS(t *testing.T) {
+	tus := "Location,Tus-Resumable,Tus-Version,Tus-Extension,Tus-Max-Size,Upload-Offset,Upload-Length,Upload-Expires"
+	cases := []struct {
+		name      string
+		config    map[string]string
+		origin    string
+		preflight bool
+		status    int
+		want      map[string]string
+	}{
+		{
+			name:   "NoOrigin",
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "DefaultWildcard",
+			origin: "https://example.com",
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:   "WildcardWithoutCredentials",
+			config: map[string]string{"server.cors.allow_origins": "*", "server.cors.allow_credentials": "true"},
+			origin: "https://evil.example",
+			status: http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:   "AllowedOriginWithCredentials",
+			config: map[string]string{"server.cors.allow_origins": "https://example.com", "server.cors.allow_credentials": "true"},
+			origin: "https://example.com",
+			status: http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:   "DisallowedOrigin",
+			config: map[string]string{"server.cors.allow_origins": "https://example.com"},
+			origin: "https://evil.example",
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "WildcardSubdomain",
+			config: map[string]string{"server.cors.allow_origins": "https://*.example.com"},
+			origin: "https://app.example.com",
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"},
+		},
+		{
+			name:   "WildcardSubdomainNotDomain",
+			config: map[string]string{"server.cors.allow_origins": "https://*.example.com"},
+			origin: "https://example.com",
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "WildcardSubdomainOtherScheme",
+			config: map[string]string{"server.cors.allow_origins": "https://*.example.com"},
+			origin: "http://app.example.com",
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "ExposeHeaders",
+			config: map[string]string{"server.cors.expose_headers": "X-Total-Count, Link"},
+			origin: "https://example.com",
+			status: http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Expose-Headers": "X-Total-Count,Link," + tus,
+			},
+		},
+		{
+			name:   "ExposeTusHeaders",
+			origin: "https://example.com",
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Expose-Headers": tus},
+		},
+		{
+			name:   "ExposeTusHeadersOnce",
+			config: map[string]string{"server.cors.expose_headers": "upload-offset, Location"},
+			origin: "https://example.com",
+			status: http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Expose-Headers": "upload-offset,Location,Tus-Resumable,Tus-Version,Tus-Extension," +
+					"Tus-Max-Size,Upload-Length,Upload-Expires",
+			},
+		},
+		{
+			name:      "Preflight",
+			config:    map[string]string{"server.cors.allow_methods": "GET, POST", "server.cors.max_age": "600"},
+			origin:    "https://example.com",
+			preflight: true,
+			status:    http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET,POST",
+				"Access-Control-Allow-Headers": "Authorization",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:      "PreflightConfiguredHeaders",
+			config:    map[string]string{"server.cors.allow_headers": "Content-Type"},
+			origin:    "https://example.com",
+			preflight: true,
+			status:    http.StatusNoContent,
+			want:      map[string]string{"Access-Control-Allow-Headers": "Content-Type"},
+		},
+		{
+			name:      "PreflightDisallowedOrigin",
+			config:    map[string]string{"server.cors.allow_origins": "https://example.com"},
+			origin:    "https://evil.example",
+			preflight: true,
+			status:    http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			for key, value := range tc.config {
+				cfg.SetString(key, value)
+			}
+			handler := corsMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+			if tc.preflight {
+				req.Method = http.MethodOptions
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				req.Header.Set("Access-Control-Request-Headers", "Authorization")
+			}
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d", rec.Code, tc.status)
+			}
+			for key, want := range tc.want {
+				if got := rec.Header().Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
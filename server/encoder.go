@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+)
+
+// encoderRegistry keeps the encoders of a container in the order of
+// registration, which breaks ties between equally preferred formats.
+type encoderRegistry struct {
+	mu       sync.RWMutex
+	encoders []contracts.IEncoder
+}
+
+func newEncoderRegistry(encoders ...contracts.IEncoder) *encoderRegistry {
+	r := &encoderRegistry{}
+	r.register(encoders...)
+	return r
+}
+
+// register adds encoders, replacing the ones handling the same media types.
+func (r *encoderRegistry) register(encoders ...contracts.IEncoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, encoder := range encoders {
+		replaced := false
+		for i, registered := range r.encoders {
+			if overlaps(registered.MediaTypes(), encoder.MediaTypes()) {
+				r.encoders[i] = encoder
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			r.encoders = append(r.encoders, encoder)
+		}
+	}
+}
+
+func (r *encoderRegistry) list() []contracts.IEncoder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]contracts.IEncoder(nil), r.encoders...)
+}
+
+// forContentType returns the encoder of a `Content-Type` header.
+func (r *encoderRegistry) forContentType(contentType string) (contracts.IEncoder, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	for _, encoder := range r.list() {
+		for _, t := range encoder.MediaTypes() {
+			if strings.EqualFold(t, mediaType) {
+				return encoder, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// encode encodes v in the most preferred format of an `Accept` header which
+// can represent v. Other formats are sent only if preferred as much as any
+// media range, and JSON otherwise, so browsers preferring HTML over XML get
+// JSON, like the clients accepting no format known. It returns
+// ErrNotAcceptable if JSON is excluded and no format accepted can represent v.
+func (r *encoderRegistry) encode(accept string, v interface{}) (contracts.IEncoder, []byte, error) {
+	ranges, excluded := parseAccept(accept)
+	encoders := r.list()
+	tried := make([]bool, len(encoders))
+
+	top := 0
+	for top < len(ranges) && ranges[top].q == ranges[0].q {
+		top++
+	}
+	var fallback []mediaRange
+	if !overlaps(excluded, []string{"application/json"}) {
+		fallback = append(fallback, mediaRange{mediaType: "application/json", q: 1})
+	}
+	for _, ranges := range [][]mediaRange{ranges[:top], fallback, ranges[top:]} {
+		encoder, body, err := tryEncoders(encoders, tried, ranges, excluded, v)
+		if encoder != nil || err != nil {
+			return encoder, body, err
+		}
+	}
+
+	return nil, nil, contracts.ErrNotAcceptable
+}
+
+// tryEncoders encodes v by the first encoder of the media ranges which can
+// represent it and wasn't tried yet, if any.
+func tryEncoders(encoders []contracts.IEncoder, tried []bool, ranges []mediaRange, excluded []string, v interface{}) (contracts.IEncoder, []byte, error) {
+	for _, mediaRange := range ranges {
+		for i, encoder := range encoders {
+			if tried[i] || !matchesAny(mediaRange.mediaType, encoder.MediaTypes()) || overlaps(excluded, encoder.MediaTypes()) {
+				continue
+			}
+			tried[i] = true
+
+			var b bytes.Buffer
+			err := encoder.Encode(&b, v)
+			if err == contracts.ErrNotEncodable {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			return encoder, b.Bytes(), nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// decode decodes the body of a request into in if an encoder handles its
+// `Content-Type`. It returns false otherwise, e.g. for forms.
+func (r *encoderRegistry) decode(req *http.Request, in interface{}) (bool, error) {
+	if req.ContentLength == 0 {
+		return false, nil
+	}
+	encoder, ok := r.forContentType(req.Header.Get("Content-Type"))
+	if !ok {
+		return false, nil
+	}
+	return true, encoder.Decode(req.Body, in)
+}
+
+// respond writes v in the format negotiated by the `Accept` header of req.
+func respond(w http.ResponseWriter, req *http.Request, encoders *encoderRegistry, status int, v interface{}) error {
+	w.Header().Add("Vary", "Accept")
+
+	encoder, body, err := encoders.encode(req.Header.Get("Accept"), v)
+	if err == contracts.ErrNotAcceptable {
+		return writeJSON(w, req, http.StatusNotAcceptable, &models.Error{
+			Message: err.Error(),
+			Code:    http.StatusNotAcceptable,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", encoder.ContentType())
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// mediaRange is a media range of an `Accept` header and its quality.
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept returns the media ranges of an `Accept` header by preference,
+// and the media types excluded by `q=0`. No header accepts anything.
+func parseAccept(accept string) ([]mediaRange, []string) {
+	if strings.TrimSpace(accept) == "" {
+		return []mediaRange{{mediaType: "*/*", q: 1}}, nil
+	}
+
+	var ranges []mediaRange
+	var excluded []string
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			excluded = append(excluded, mediaType)
+			continue
+		}
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges, excluded
+}
+
+// matchesAny reports whether a media range like `application/*` matches any
+// of mediaTypes.
+func matchesAny(mediaRange string, mediaTypes []string) bool {
+	for _, mediaType := range mediaTypes {
+		if mediaRange == "*/*" || strings.EqualFold(mediaRange, mediaType) {
+			return true
+		}
+		if strings.HasSuffix(mediaRange, "/*") &&
+			strings.HasPrefix(strings.ToLower(mediaType), strings.ToLower(strings.TrimSuffix(mediaRange, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps reports whether a and b share a media type.
+func overlaps(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
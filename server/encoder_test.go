@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/encoder"
+)
+
+func TestEncodeNegotiation(t *testing.T) {
+	type item struct {
+		Name string `json:"name" xml:"name"`
+	}
+	type row struct {
+		Name string `csv:"name"`
+	}
+	cases := []struct {
+		name   string
+		accept string
+		v      interface{}
+		want   string
+		err    error
+	}{
+		{name: "None", accept: "", v: &item{}, want: "application/json"},
+		{name: "Any", accept: "*/*", v: &item{}, want: "application/json"},
+		{name: "Browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			v: &item{}, want: "application/json"},
+		{name: "HTML", accept: "text/html", v: &item{}, want: "application/json"},
+		{name: "XML", accept: "application/xml", v: &item{}, want: "application/xml"},
+		{name: "XMLOverAny", accept: "application/xml, */*;q=0.1", v: &item{}, want: "application/xml"},
+		{name: "JSONOverXML", accept: "application/json, application/xml;q=0.9", v: &item{}, want: "application/json"},
+		{name: "SamePreference", accept: "application/msgpack, application/json", v: &item{}, want: "application/msgpack"},
+		{name: "CSV", accept: "text/csv", v: []row{{Name: "a"}}, want: "text/csv"},
+		// Items aren't tabular, so they're sent as JSON instead.
+		{name: "CSVOfItem", accept: "text/csv", v: &item{}, want: "application/json"},
+		{name: "JSONExcluded", accept: "text/html, application/xml;q=0.5, application/json;q=0", v: &item{},
+			want: "application/xml"},
+		{name: "NotAcceptable", accept: "text/csv, application/json;q=0", v: &item{}, err: contracts.ErrNotAcceptable},
+	}
+	registry := newEncoderRegistry(encoder.Defaults()...)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, _, err := registry.encode(tc.accept, tc.v)
+			if err != tc.err {
+				t.Fatalf("encode = %v, want %v", err, tc.err)
+			}
+			if err == nil && e.MediaTypes()[0] != tc.want {
+				t.Errorf("encoded as %s, want %s", e.MediaTypes()[0], tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import "github.com/mostafasolati/leviathan/contracts"
+
+// routerGroup is a sub-router which prefixes the paths of its routes and
+// applies its options to them.
+type routerGroup struct {
+	router  contracts.IRouter
+	prefix  string
+	options []contracts.RouteOption
+}
+
+// Route registers a route on the parent router
+func (g *routerGroup) Route(method, path string, handler contracts.Handler, options ...contracts.RouteOption) {
+	all := make([]contracts.RouteOption, 0, len(g.options)+len(options))
+	all = append(all, g.options...)
+	all = append(all, options...)
+	g.router.Route(method, g.prefix+path, handler, all...)
+}
+
+// Use adds middleware to the routes registered on the group afterwards
+func (g *routerGroup) Use(middleware ...contracts.Middleware) {
+	g.options = append(g.options[:len(g.options):len(g.options)], contracts.WithMiddleware(middleware...))
+}
+
+// Group returns a nested sub-router
+func (g *routerGroup) Group(prefix string, options ...contracts.RouteOption) contracts.IRouter {
+	all := make([]contracts.RouteOption, 0, len(g.options)+len(options))
+	all = append(all, g.options...)
+	all = append(all, options...)
+	return &routerGroup{
+		router:  g.router,
+		prefix:  g.prefix + prefix,
+		options: all,
+	}
+}
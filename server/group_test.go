@@ -0,0 +1,131 @@
+package services
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func TestGroupOptions(t *testing.T) {
+	ok := func(server contracts.IServer) error { return nil }
+	for name, newContainer := range containers {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("storage-dir", t.TempDir())
+			c := factory(newContainer)(cfg)
+
+			v1 := c.Group("/v1", contracts.WithTags("v1"))
+			admin := v1.Group("/admin", contracts.WithRoles("admin"),
+				contracts.WithRateLimit(contracts.RateLimit{Requests: 1, Period: time.Minute}))
+			admin.Route(http.MethodGet, "/users", ok)
+			admin.Route(http.MethodGet, "/reports", ok, contracts.WithRoles("staff"), contracts.WithTags("reports"),
+				contracts.WithRateLimit(contracts.RateLimit{Requests: 5, Period: time.Minute}))
+			v1.Route(http.MethodGet, "/public", ok)
+
+			routes := c.Routes()
+			if len(routes) != 3 {
+				t.Fatalf("Routes = %+v, want 3 routes", routes)
+			}
+			cases := []struct {
+				path      string
+				roles     []string
+				tags      []string
+				rateLimit int
+			}{
+				{path: "/v1/admin/users", roles: []string{"admin"}, tags: []string{"v1"}, rateLimit: 1},
+				{path: "/v1/admin/reports", roles: []string{"admin", "staff"}, tags: []string{"v1", "reports"}, rateLimit: 5},
+				// Options of a nested group don't leak into its parent.
+				{path: "/v1/public", tags: []string{"v1"}},
+			}
+			for i, tc := range cases {
+				r := routes[i]
+				if r.Path != tc.path || !reflect.DeepEqual(r.Roles, tc.roles) || !reflect.DeepEqual(r.Tags, tc.tags) {
+					t.Errorf("Routes[%d] = %s %v %v, want %s %v %v", i, r.Path, r.Roles, r.Tags, tc.path, tc.roles, tc.tags)
+				}
+				if r.Authenticated != (tc.roles != nil) {
+					t.Errorf("%s authenticated: %v", r.Path, r.Authenticated)
+				}
+				limit := 0
+				if r.RateLimit != nil {
+					limit = r.RateLimit.Requests
+				}
+				if limit != tc.rateLimit {
+					t.Errorf("%s is limited to %d requests, want %d", r.Path, limit, tc.rateLimit)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupMiddleware(t *testing.T) {
+	for name, newContainer := range containers {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("storage-dir", t.TempDir())
+			c := factory(newContainer)(cfg)
+
+			var calls []string
+			record := func(name string) contracts.Middleware {
+				return func(next contracts.Handler) contracts.Handler {
+					return func(server contracts.IServer) error {
+						calls = append(calls, name)
+						return next(server)
+					}
+				}
+			}
+			ok := func(server contracts.IServer) error {
+				return server.String(http.StatusOK, "ok")
+			}
+
+			api := c.Group("/api")
+			api.Route(http.MethodGet, "/before", ok)
+			api.Use(record("api"))
+			nested := api.Group("/nested", contracts.WithMiddleware(record("group")))
+			nested.Use(record("nested"))
+			nested.Route(http.MethodGet, "/route", ok, contracts.WithMiddleware(record("route")))
+			api.Route(http.MethodGet, "/after", ok)
+			limited := api.Group("/limited", contracts.WithRateLimit(contracts.RateLimit{Requests: 1, Period: time.Minute}))
+			limited.Route(http.MethodGet, "/group", ok)
+			limited.Route(http.MethodGet, "/route", ok,
+				contracts.WithRateLimit(contracts.RateLimit{Requests: 2, Period: time.Minute}))
+
+			ts := c.TestServer()
+			defer ts.Close()
+
+			for _, step := range []struct {
+				path   string
+				status int
+				calls  string
+			}{
+				// Middleware applies to the routes registered afterwards only.
+				{path: "/api/before", status: http.StatusOK},
+				{path: "/api/nested/route", status: http.StatusOK, calls: "api,group,nested,route"},
+				{path: "/api/after", status: http.StatusOK, calls: "api"},
+				{path: "/api/limited/group", status: http.StatusOK, calls: "api"},
+				{path: "/api/limited/group", status: http.StatusTooManyRequests},
+				// The limit of the route overrides the one of its group.
+				{path: "/api/limited/route", status: http.StatusOK, calls: "api"},
+				{path: "/api/limited/route", status: http.StatusOK, calls: "api"},
+				{path: "/api/limited/route", status: http.StatusTooManyRequests},
+			} {
+				calls = nil
+				res, err := http.Get(ts.URL + step.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if res.StatusCode != step.status {
+					t.Errorf("GET %s = %d, want %d", step.path, res.StatusCode, step.status)
+				}
+				if got := strings.Join(calls, ","); got != step.calls {
+					t.Errorf("GET %s ran %q, want %q", step.path, got, step.calls)
+				}
+			}
+		})
+	}
+}
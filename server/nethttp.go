@@ -0,0 +1,582 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/encoder"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/pagination"
+	"github.com/mostafasolati/leviathan/utils"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// wildcardParam is the name of the path value which `*` segments match.
+const wildcardParam = "_"
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	appKey
+)
+
+type httpServerContainer struct {
+	configService contracts.IConfigService
+	logger        contracts.ILogger
+	rateLimiter   contracts.IRateLimiter
+	uploads       *uploader
+	roles         map[string][]string
+	routes        *routeRegistry
+	encoders      *encoderRegistry
+	mux           *http.ServeMux
+	handler       http.Handler
+	server        *http.Server
+	closing       context.Context
+
+	mu         sync.RWMutex
+	middleware []contracts.Middleware
+}
+
+// NewHTTPServerContainer is responsible for registering routes and run the
+// server, like NewEchoServerContainer, but is built on net/http only. Routes
+// are matched by the pattern routing of http.ServeMux.
+func NewHTTPServerContainer(
+	config contracts.IConfigService,
+	logger contracts.ILogger,
+	rateLimiter contracts.IRateLimiter,
+	storage contracts.IStorage,
+) contracts.IServerContainer {
+	container := &httpServerContainer{
+		configService: config,
+		logger:        logger,
+		rateLimiter:   rateLimiter,
+		uploads:       newUploader(config, storage),
+		roles:         make(map[string][]string),
+		routes:        newRouteRegistry(),
+		encoders:      newEncoderRegistry(encoder.Defaults()...),
+		mux:           http.NewServeMux(),
+		server:        &http.Server{},
+	}
+	container.closing = onShutdown(container.server)
+
+	var handler http.Handler = http.HandlerFunc(container.dispatch)
+	handler = appDetectionHandler(handler)
+	handler = websocketTokenMiddleware()(handler)
+	handler = securityHeadersMiddleware(config)(handler)
+	handler = corsMiddleware(config)(handler)
+	container.handler = handler
+
+	return container
+}
+
+// SecureRoutes restricts access to the routes under the given path prefixes
+func (s *httpServerContainer) SecureRoutes(routes map[string][]string) {
+	for route, roles := range routes {
+		s.roles[route] = roles
+	}
+}
+
+// Route registers a route to a corresponding handler
+func (s *httpServerContainer) Route(method, path string, handler contracts.Handler, options ...contracts.RouteOption) {
+
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	info := contracts.RouteInfo{Method: method, Path: path}
+	for _, option := range options {
+		option(&info)
+	}
+
+	for i := len(info.Middleware) - 1; i >= 0; i-- {
+		handler = info.Middleware[i](handler)
+	}
+	handler = rateLimited(s.rateLimiter, info, handler)
+
+	// Access is checked against the roles of SecureRoutes and of the route
+	// separately, like the echo container does.
+	var access [][]string
+	routeRoles := info.Roles
+	sr, secured := securedBy(s.roles, path)
+	if secured {
+		info.Roles = s.roles[sr]
+		access = append(access, info.Roles)
+		if len(routeRoles) > 0 {
+			info.Roles = intersectRoles(info.Roles, routeRoles)
+		}
+		info.Authenticated = true
+	}
+	if info.Authenticated && (!secured || len(routeRoles) > 0) {
+		access = append(access, routeRoles)
+	}
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		if info.Authenticated {
+			user, failure := s.authenticate(r)
+			if failure == nil {
+				failure = authorize(user, access)
+			}
+			if failure != nil {
+				writeJSON(w, r, failure.Code, failure)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), userKey, user))
+		}
+
+		server := &httpServer{
+			w:             w,
+			r:             r,
+			configService: s.configService,
+			uploads:       s.uploads,
+			encoders:      s.encoders,
+			takeover:      takeover{closing: s.closing},
+		}
+		err := s.wrap(info, handler)(server)
+		server.release()
+
+		if err != nil {
+			s.logger.WithFields(contracts.LogFields{
+				"path":  r.URL.Path,
+				"error": err.Error(),
+			}).Error("cannot handle request")
+			if server.detached {
+				return
+			}
+			_ = server.JSON(http.StatusBadRequest, &models.Error{
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+		}
+	}
+
+	s.mux.HandleFunc(httpPattern(method, path), h)
+	s.routes.add(info)
+}
+
+// Use adds middleware to all routes
+func (s *httpServerContainer) Use(middleware ...contracts.Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// Group returns a sub-router for routes sharing a prefix and options
+func (s *httpServerContainer) Group(prefix string, options ...contracts.RouteOption) contracts.IRouter {
+	return &routerGroup{
+		router:  s,
+		prefix:  prefix,
+		options: options,
+	}
+}
+
+// wrap wraps a handler with the global middleware, and with the global rate
+// limit outermost unless the route is exempt from it
+func (s *httpServerContainer) wrap(info contracts.RouteInfo, handler contracts.Handler) contracts.Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		handler = s.middleware[i](handler)
+	}
+	if !info.Unlimited {
+		handler = s.rateLimiter.Global(handler)
+	}
+	return handler
+}
+
+// authenticate parses the bearer token of a request. Requests with no token
+// are let through with no user, so access is decided by authorize.
+func (s *httpServerContainer) authenticate(r *http.Request) (*models.UserClaims, *models.Error) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return nil, nil
+	}
+
+	const scheme = "Bearer "
+	if !strings.HasPrefix(auth, scheme) {
+		return nil, &models.Error{
+			Message: "missing or malformed jwt",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	claims := &models.UserClaims{}
+	token, err := jwt.ParseWithClaims(auth[len(scheme):], claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("unexpected jwt signing method %v", token.Header["alg"])
+		}
+		return []byte(s.configService.String("auth.jwt.secret")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, &models.Error{
+			Message: "invalid or expired jwt",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+	return claims, nil
+}
+
+// authorize requires a logged in user having one of the roles of each
+// element of access. Empty roles allow any logged in user.
+func authorize(user *models.UserClaims, access [][]string) *models.Error {
+	if user == nil {
+		return &models.Error{
+			Message: "unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+	for _, roles := range access {
+		if len(roles) > 0 && len(intersectRoles(roles, user.Roles)) == 0 {
+			return &models.Error{
+				Message: "access forbidden",
+				Code:    http.StatusForbidden,
+			}
+		}
+	}
+	return nil
+}
+
+// dispatch routes a request, responding to unknown routes in the same format
+// as the errors of the handlers.
+func (s *httpServerContainer) dispatch(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := s.mux.Handler(r); pattern == "" {
+		w = &unmatchedWriter{ResponseWriter: w, r: r}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// unmatchedWriter writes the plain-text errors of http.ServeMux for
+// requests matching no route, i.e. 404 and 405, as JSON errors. Other
+// responses, like the redirects to cleaned paths, pass through.
+type unmatchedWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	intercepted bool
+}
+
+func (w *unmatchedWriter) WriteHeader(status int) {
+	if status != http.StatusNotFound && status != http.StatusMethodNotAllowed {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.intercepted = true
+	_ = writeJSON(w.ResponseWriter, w.r, status, &models.Error{
+		Message: http.StatusText(status),
+		Code:    status,
+	})
+}
+
+func (w *unmatchedWriter) Write(b []byte) (int, error) {
+	if w.intercepted {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// httpPattern converts a route to a http.ServeMux pattern, i.e. its `:param`
+// and `*` segments to wildcards. Paths ending in a slash match exactly, like
+// the other paths.
+func httpPattern(method, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		} else if segment == "*" {
+			segments[i] = "{" + wildcardParam + "...}"
+		}
+	}
+
+	pattern := strings.Join(segments, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "{$}"
+	}
+	return method + " " + pattern
+}
+
+// URL builds the path of a named route
+func (s *httpServerContainer) URL(name string, params ...string) (string, error) {
+	return s.routes.url(name, params...)
+}
+
+// Routes lists the registered routes
+func (s *httpServerContainer) Routes() []contracts.RouteInfo {
+	return s.routes.list()
+}
+
+// Storage returns the storage which uploaded files are kept in
+func (s *httpServerContainer) Storage() contracts.IStorage {
+	return s.uploads.storage
+}
+
+// File looks up the metadata of a file uploaded by uploaderID by its ID
+func (s *httpServerContainer) File(ctx context.Context, id string, uploaderID int) (*models.File, error) {
+	return utils.LookupUpload(ctx, s.uploads.storage, id, uploaderID)
+}
+
+// DeleteFile deletes a file uploaded by a user
+func (s *httpServerContainer) DeleteFile(ctx context.Context, id string, uploaderID int) error {
+	return s.uploads.delete(ctx, id, uploaderID)
+}
+
+// RegisterUploadProcessor adds processors of the files uploaded
+func (s *httpServerContainer) RegisterUploadProcessor(processors ...contracts.IUploadProcessor) {
+	s.uploads.register(processors...)
+}
+
+// ResumableUpload registers the routes of resumable uploads
+func (s *httpServerContainer) ResumableUpload(path, field string, handler func(server contracts.IServer, file *models.File) error, options ...contracts.RouteOption) {
+	registerResumableUpload(s, s.uploads, s.closing, path, field, handler, options...)
+}
+
+// RegisterEncoder adds formats of responses and requests
+func (s *httpServerContainer) RegisterEncoder(encoders ...contracts.IEncoder) {
+	s.encoders.register(encoders...)
+}
+
+// Run starts the server in given address
+func (s *httpServerContainer) Run(address string) error {
+	return serve(s.server, s.handler, s.configService, s.logger, address)
+}
+
+// Shutdown gracefully stops the server
+func (s *httpServerContainer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// TestServer starts a test server
+func (s *httpServerContainer) TestServer() *httptest.Server {
+	return httptest.NewServer(s.handler)
+}
+
+// appDetectionHandler determines the client app sending the request
+func appDetectionHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app := detectApp(r.Header.Get("User-Agent"))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), appKey, app)))
+	})
+}
+
+// writeJSON writes a JSON response, indented if the `pretty` query parameter
+// is present.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, output interface{}) error {
+	enc := json.NewEncoder(w)
+	if _, pretty := r.URL.Query()["pretty"]; pretty {
+		enc.SetIndent("", "  ")
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	return enc.Encode(output)
+}
+
+type httpServer struct {
+	w             http.ResponseWriter
+	r             *http.Request
+	configService contracts.IConfigService
+	uploads       *uploader
+	encoders      *encoderRegistry
+	takeover
+}
+
+// Page reads the requested page
+func (s *httpServer) Page() models.Page {
+	return pagination.ParsePage(s.configService, s.r.URL.Query())
+}
+
+// Cursor decodes the cursor of the requested page
+func (s *httpServer) Cursor(v interface{}) (int, error) {
+	return readCursor(s, s.configService, v)
+}
+
+// Paginate sends a page linking the other pages
+func (s *httpServer) Paginate(status int, p *models.Pagination) error {
+	return paginate(s, s.configService, status, p)
+}
+
+// PaginateCursor sends a page linking the next page by a signed cursor
+func (s *httpServer) PaginateCursor(status, perPage int, data, next interface{}) error {
+	return paginateCursor(s, s.configService, status, perPage, data, next)
+}
+
+// Stream sends server-sent events
+func (s *httpServer) Stream(handler func(stream contracts.IEventStream) error) error {
+	return s.stream(s.w, s.r, s.configService, handler)
+}
+
+// Upgrade upgrades the connection to a WebSocket
+func (s *httpServer) Upgrade() (contracts.IWebSocket, error) {
+	return s.upgrade(s.w, s.r, s.configService)
+}
+
+// SetHeader sets a http header
+func (s *httpServer) SetHeader(key, value string) {
+	s.w.Header().Set(key, value)
+}
+
+// Response return a proper http response to the client in the format
+// negotiated by the `Accept` header
+func (s *httpServer) Response(status int, v interface{}) error {
+	return respond(s.w, s.r, s.encoders, status, v)
+}
+
+// Query returns a query string parameter
+func (s *httpServer) Query(key string) string {
+	return s.r.URL.Query().Get(key)
+}
+
+// QueryParams returns all query string parameters
+func (s *httpServer) QueryParams() map[string]string {
+	params := make(map[string]string)
+	for key, val := range s.r.URL.Query() {
+		params[key] = val[0]
+	}
+	return params
+}
+
+// FormParams returns all post string parameters
+func (s *httpServer) FormParams() map[string]string {
+	params := make(map[string]string)
+	form, err := formValues(s.r)
+	if err != nil {
+		return params
+	}
+	for key, val := range form {
+		params[key] = val[0]
+	}
+	return params
+}
+
+// UploadedFile returns an uploaded file.
+func (s *httpServer) UploadedFile(name string) (*multipart.FileHeader, error) {
+	_, header, err := s.r.FormFile(name)
+	return header, err
+}
+
+// Upload handles http file upload into the server
+func (s *httpServer) Upload(field string) (string, error) {
+	file, err := s.UploadedFile(field)
+	if err != nil {
+		return "", err
+	}
+	return s.uploads.upload(s.r.Context(), field, file, s.User())
+}
+
+// User returns the logged in user
+func (s *httpServer) User() *models.UserClaims {
+	user, _ := s.r.Context().Value(userKey).(*models.UserClaims)
+	return user
+}
+
+// App returns the client app sending the HTTP request
+func (s *httpServer) App() models.App {
+	if app, ok := s.r.Context().Value(appKey).(models.App); ok {
+		return app
+	}
+	return models.WebApp
+}
+
+// Param returns a url parameter
+func (s *httpServer) Param(key string) string {
+	if key == "*" {
+		key = wildcardParam
+	}
+	return s.r.PathValue(key)
+}
+
+// Bind binds a http request (posted data or query params) to a struct
+func (s *httpServer) Bind(in interface{}) error {
+	if decoded, err := s.encoders.decode(s.r, in); decoded {
+		return err
+	}
+	return bind(s.r, in)
+}
+
+// RawBody reads the raw body of the request.
+func (s *httpServer) RawBody() ([]byte, error) {
+	return ioutil.ReadAll(s.r.Body)
+}
+
+// String returns a string response to the client
+func (s *httpServer) String(status int, output string) error {
+	s.w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	s.w.WriteHeader(status)
+	_, err := s.w.Write([]byte(output))
+	return err
+}
+
+// JSON returns a json response to the client
+func (s *httpServer) JSON(status int, output interface{}) error {
+	return writeJSON(s.w, s.r, status, output)
+}
+
+// File send a file to client for download
+func (s *httpServer) File(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return contracts.ErrFileNotFound
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return s.File(filepath.Join(path, "index.html"))
+	}
+
+	http.ServeContent(s.w, s.r, fi.Name(), fi.ModTime(), f)
+	return nil
+}
+
+// Attachment sends a file as attachment prompting for download
+func (s *httpServer) Attachment(path, name string) error {
+	s.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	return s.File(path)
+}
+
+// HTML serves a Go HTML template
+func (s *httpServer) HTML(name string, data interface{}) error {
+	b, err := renderTemplate(s.configService, name, data)
+	if err != nil {
+		return err
+	}
+
+	s.w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	s.w.WriteHeader(http.StatusOK)
+	_, err = s.w.Write(b)
+	return err
+}
+
+// Redirect redirects the client to a url
+func (s *httpServer) Redirect(status int, url string) error {
+	if status < http.StatusMultipleChoices || status > http.StatusPermanentRedirect {
+		return fmt.Errorf("invalid redirect status code %d", status)
+	}
+	s.w.Header().Set("Location", url)
+	s.w.WriteHeader(status)
+	return nil
+}
+
+func (s *httpServer) Request() *http.Request {
+	return s.r
+}
+
+func (s *httpServer) ResponseWriter() http.ResponseWriter {
+	return s.w
+}
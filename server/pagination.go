@@ -0,0 +1,47 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/netutil"
+	"github.com/mostafasolati/leviathan/pagination"
+)
+
+// requestURL returns the absolute URL of a request, which pages are linked to.
+func requestURL(r *http.Request, config contracts.IConfigService) *url.URL {
+	u := *r.URL
+	u.Scheme = "http"
+	if isHTTPS(r, netutil.TrustedProxies(config)) {
+		u.Scheme = "https"
+	}
+	u.Host = r.Host
+	return &u
+}
+
+func paginate(server contracts.IServer, config contracts.IConfigService, status int, p *models.Pagination) error {
+	server.SetHeader("Link", pagination.Links(requestURL(server.Request(), config), p))
+	return server.Response(status, p)
+}
+
+func readCursor(server contracts.IServer, config contracts.IConfigService, v interface{}) (int, error) {
+	values := server.Request().URL.Query()
+	perPage := pagination.PerPage(config, values)
+	if cursor := values.Get("cursor"); cursor != "" {
+		if err := pagination.DecodeCursor(pagination.Secret(config), cursor, v); err != nil {
+			return 0, err
+		}
+	}
+	return perPage, nil
+}
+
+func paginateCursor(server contracts.IServer, config contracts.IConfigService, status, perPage int, data, next interface{}) error {
+	p, err := pagination.NewCursor(pagination.Secret(config), perPage, data, next)
+	if err != nil {
+		return err
+	}
+	server.SetHeader("Link", pagination.CursorLinks(requestURL(server.Request(), config), p))
+	return server.Response(status, p)
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func TestRouteRateLimit(t *testing.T) {
+	containers := map[string]func(contracts.IConfigService, contracts.ILogger, contracts.IRateLimiter, contracts.IStorage) contracts.IServerContainer{
+		"Echo": NewEchoServerContainer,
+		"HTTP": NewHTTPServerContainer,
+	}
+	for name, newContainer := range containers {
+		newContainer := newContainer
+		t.Run(name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("storage-dir", t.TempDir())
+			c := factory(newContainer)(cfg)
+
+			ok := func(server contracts.IServer) error {
+				return server.String(http.StatusOK, "ok")
+			}
+			limit := contracts.WithRateLimit(contracts.RateLimit{Requests: 1, Period: time.Minute})
+			c.Route(http.MethodGet, "/limited", ok, limit)
+			c.Route(http.MethodGet, "/other-limited", ok, limit)
+			c.Route(http.MethodGet, "/unlimited", ok)
+			c.Group("/group", limit).Route(http.MethodGet, "/limited", ok)
+
+			ts := c.TestServer()
+			defer ts.Close()
+
+			// Each route counts its requests separately.
+			for _, step := range []struct {
+				path   string
+				status int
+			}{
+				{"/limited", http.StatusOK},
+				{"/limited", http.StatusTooManyRequests},
+				{"/other-limited", http.StatusOK},
+				{"/unlimited", http.StatusOK},
+				{"/unlimited", http.StatusOK},
+				{"/group/limited", http.StatusOK},
+				{"/group/limited", http.StatusTooManyRequests},
+			} {
+				res, err := http.Get(ts.URL + step.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if res.StatusCode != step.status {
+					t.Errorf("GET %s = %d, want %d", step.path, res.StatusCode, step.status)
+				}
+			}
+		})
+	}
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	containers := map[string]func(contracts.IConfigService, contracts.ILogger, contracts.IRateLimiter, contracts.IStorage) contracts.IServerContainer{
+		"Echo": NewEchoServerContainer,
+		"HTTP": NewHTTPServerContainer,
+	}
+	for name, newContainer := range containers {
+		newContainer := newContainer
+		t.Run(name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("storage-dir", t.TempDir())
+			cfg.SetString("server.ratelimit.requests", "1")
+			cfg.SetString("server.ratelimit.period", "60")
+			c := factory(newContainer)(cfg)
+
+			ok := func(server contracts.IServer) error {
+				return server.String(http.StatusOK, "ok")
+			}
+			c.Route(http.MethodGet, "/limited", ok)
+			c.Route(http.MethodGet, "/healthz", ok, contracts.WithoutGlobalRateLimit())
+
+			ts := c.TestServer()
+			defer ts.Close()
+
+			// The exempt route neither counts nor is counted.
+			for _, step := range []struct {
+				path   string
+				status int
+			}{
+				{"/healthz", http.StatusOK},
+				{"/limited", http.StatusOK},
+				{"/limited", http.StatusTooManyRequests},
+				{"/healthz", http.StatusOK},
+			} {
+				res, err := http.Get(ts.URL + step.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if res.StatusCode != step.status {
+					t.Errorf("GET %s = %d, want %d", step.path, res.StatusCode, step.status)
+				}
+			}
+		})
+	}
+}
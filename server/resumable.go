@@ -0,0 +1,466 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+// Resumable uploads follow the core protocol of tus, see https://tus.io, and
+// its creation, expiration and termination extensions.
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+	tusChunkType  = "application/offset+octet-stream"
+
+	// resumablePrefix is the prefix of the storage keys of the resumable
+	// uploads in progress.
+	resumablePrefix = "uploads/"
+
+	// DefaultResumableExpiry is how long resumable uploads are kept since
+	// their last chunk, in seconds, unless configured by
+	// `server.uploads.expiry`.
+	DefaultResumableExpiry = 24 * 60 * 60
+)
+
+// tusHeaders are the response headers of resumable uploads, which browsers
+// hide from the scripts of other origins unless CORS exposes them.
+var tusHeaders = []string{
+	"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+	"Upload-Offset", "Upload-Length", "Upload-Expires",
+}
+
+// resumableInfo describes a resumable upload in progress.
+type resumableInfo struct {
+	ID         string    `json:"id"`
+	Field      string    `json:"field"`
+	Name       string    `json:"name"`
+	Length     int64     `json:"length"`
+	UploaderID int       `json:"uploader_id,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// resumable serves the resumable uploads of a route.
+type resumable struct {
+	uploads *uploader
+	field   string
+	handler func(server contracts.IServer, file *models.File) error
+}
+
+// registerResumableUpload registers the routes of resumable uploads at path
+// on c, which are stored by uploads.
+func registerResumableUpload(
+	c contracts.IServerContainer,
+	uploads *uploader,
+	closing context.Context,
+	path, field string,
+	handler func(server contracts.IServer, file *models.File) error,
+	options ...contracts.RouteOption,
+) {
+	r := &resumable{uploads: uploads, field: field, handler: handler}
+	uploads.sweepOnce.Do(func() {
+		go uploads.sweep(closing)
+	})
+
+	// Only the creation route keeps the name of the route.
+	unnamed := append(options[:len(options):len(options)], func(route *contracts.RouteInfo) {
+		route.Name = ""
+	})
+	path = strings.TrimSuffix(path, "/")
+	c.Route(http.MethodPost, path, r.create, options...)
+	c.Route(http.MethodOptions, path, r.options, unnamed...)
+	c.Route(http.MethodHead, path+"/:id", r.head, unnamed...)
+	c.Route(http.MethodPatch, path+"/:id", r.patch, unnamed...)
+	c.Route(http.MethodDelete, path+"/:id", r.terminate, unnamed...)
+}
+
+// options describes the supported protocol.
+func (r *resumable) options(server contracts.IServer) error {
+	server.SetHeader("Tus-Resumable", tusVersion)
+	server.SetHeader("Tus-Version", tusVersion)
+	server.SetHeader("Tus-Extension", tusExtensions)
+	server.SetHeader("Tus-Max-Size", strconv.FormatInt(utils.MaxUploadSize(r.uploads.config, r.field), 10))
+	server.ResponseWriter().WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// create starts an upload of the size in the `Upload-Length` header.
+func (r *resumable) create(server contracts.IServer) error {
+	server.SetHeader("Tus-Resumable", tusVersion)
+	req := server.Request()
+	length, err := strconv.ParseInt(req.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return tusError(server, http.StatusBadRequest, "invalid Upload-Length")
+	}
+	if length > utils.MaxUploadSize(r.uploads.config, r.field) {
+		return tusError(server, http.StatusRequestEntityTooLarge, contracts.ErrFileTooLarge.Error())
+	}
+
+	info := &resumableInfo{
+		Field:  r.field,
+		Name:   uploadMetadata(req.Header.Get("Upload-Metadata"))["filename"],
+		Length: length,
+	}
+	if user := server.User(); user != nil {
+		info.UploaderID = user.ID
+	}
+	if err := r.uploads.createResumable(req.Context(), info); err != nil {
+		return err
+	}
+
+	server.SetHeader("Location", path.Join(req.URL.Path, info.ID))
+	server.SetHeader("Upload-Expires", info.ExpiresAt.Format(http.TimeFormat))
+	server.ResponseWriter().WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// head reports the offset of an upload.
+func (r *resumable) head(server contracts.IServer) error {
+	server.SetHeader("Tus-Resumable", tusVersion)
+	server.SetHeader("Cache-Control", "no-store")
+	info, offset, err := r.lookup(server)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		server.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
+	server.SetHeader("Upload-Offset", strconv.FormatInt(offset, 10))
+	server.SetHeader("Upload-Length", strconv.FormatInt(info.Length, 10))
+	server.SetHeader("Upload-Expires", info.ExpiresAt.Format(http.TimeFormat))
+	server.ResponseWriter().WriteHeader(http.StatusOK)
+	return nil
+}
+
+// patch appends a chunk to an upload at the offset in the `Upload-Offset`
+// header, storing the file once it's complete.
+func (r *resumable) patch(server contracts.IServer) error {
+	server.SetHeader("Tus-Resumable", tusVersion)
+	req := server.Request()
+	if req.Header.Get("Content-Type") != tusChunkType {
+		return tusError(server, http.StatusUnsupportedMediaType, "Content-Type must be "+tusChunkType)
+	}
+	claimed, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || claimed < 0 {
+		return tusError(server, http.StatusBadRequest, "invalid Upload-Offset")
+	}
+
+	// Chunks of the same upload are appended one at a time.
+	lock := r.uploads.lock(server.Param("id"))
+	lock.Lock()
+	defer lock.Unlock()
+
+	info, offset, err := r.lookup(server)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return tusError(server, http.StatusNotFound, contracts.ErrFileNotFound.Error())
+	}
+	if claimed != offset {
+		return tusError(server, http.StatusConflict, fmt.Sprintf("Upload-Offset must be %d", offset))
+	}
+
+	chunk, err := ioutil.ReadAll(io.LimitReader(req.Body, info.Length-offset+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(chunk)) > info.Length-offset {
+		return tusError(server, http.StatusRequestEntityTooLarge, "chunk exceeds Upload-Length")
+	}
+	ctx := req.Context()
+	if err := r.uploads.appendChunk(ctx, info, offset, chunk); err != nil {
+		return err
+	}
+	offset += int64(len(chunk))
+	server.SetHeader("Upload-Offset", strconv.FormatInt(offset, 10))
+	server.SetHeader("Upload-Expires", info.ExpiresAt.Format(http.TimeFormat))
+
+	if offset == info.Length {
+		file, err := r.uploads.finishResumable(ctx, info)
+		if err != nil {
+			return err
+		}
+		if r.handler != nil {
+			if err := r.handler(server, file); err != nil {
+				return err
+			}
+		}
+	}
+	server.ResponseWriter().WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// terminate discards an upload.
+func (r *resumable) terminate(server contracts.IServer) error {
+	server.SetHeader("Tus-Resumable", tusVersion)
+
+	// An upload isn't discarded while a chunk of it is being appended.
+	lock := r.uploads.lock(server.Param("id"))
+	lock.Lock()
+	defer lock.Unlock()
+
+	info, _, err := r.lookup(server)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return tusError(server, http.StatusNotFound, contracts.ErrFileNotFound.Error())
+	}
+	if err := r.uploads.deleteResumable(server.Request().Context(), info.ID); err != nil {
+		return err
+	}
+	server.ResponseWriter().WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// lookup returns the upload of the request and its offset, or nil if there's
+// no such upload of the user, or it's expired.
+func (r *resumable) lookup(server contracts.IServer) (*resumableInfo, int64, error) {
+	ctx := server.Request().Context()
+	info, err := r.uploads.resumableInfo(ctx, server.Param("id"))
+	if err == contracts.ErrFileNotFound || err == contracts.ErrInvalidStorageKey {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	uploaderID := 0
+	if user := server.User(); user != nil {
+		uploaderID = user.ID
+	}
+	if info.UploaderID != uploaderID {
+		return nil, 0, nil
+	}
+	if time.Now().After(info.ExpiresAt) {
+		return nil, 0, r.uploads.deleteResumable(ctx, info.ID)
+	}
+
+	offset, err := r.uploads.resumableOffset(ctx, info.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return info, offset, nil
+}
+
+// tusError responds with an error of status.
+func tusError(server contracts.IServer, status int, message string) error {
+	return server.JSON(status, &models.Error{
+		Message: message,
+		Code:    status,
+	})
+}
+
+// uploadMetadata decodes the `Upload-Metadata` header, a comma-separated list
+// of keys and base64-encoded values.
+func uploadMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+		value := ""
+		if len(fields) > 1 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata
+}
+
+// createResumable starts an upload, filling its ID and expiry.
+func (u *uploader) createResumable(ctx context.Context, info *resumableInfo) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	info.ID = hex.EncodeToString(id)
+	return u.saveResumable(ctx, info)
+}
+
+// saveResumable stores the description of an upload, extending its expiry.
+func (u *uploader) saveResumable(ctx context.Context, info *resumableInfo) error {
+	expiry := u.config.Int("server.uploads.expiry")
+	if expiry <= 0 {
+		expiry = DefaultResumableExpiry
+	}
+	info.ExpiresAt = time.Now().Add(time.Duration(expiry) * time.Second).UTC().Truncate(time.Second)
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return u.storage.Put(ctx, resumablePrefix+info.ID+"/info.json", bytes.NewReader(data), "application/json")
+}
+
+func (u *uploader) resumableInfo(ctx context.Context, id string) (*resumableInfo, error) {
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return nil, contracts.ErrFileNotFound
+	}
+	r, _, err := u.storage.Get(ctx, resumablePrefix+id+"/info.json")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	info := &resumableInfo{}
+	if err := json.NewDecoder(r).Decode(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// resumableChunks returns the chunks of an upload, which are named by their
+// offset, in order.
+func (u *uploader) resumableChunks(ctx context.Context, id string) ([]contracts.FileInfo, error) {
+	chunks, err := u.storage.List(ctx, resumablePrefix+id+"/chunks/")
+	if err != nil {
+		return nil, err
+	}
+
+	// A chunk is only counted if it follows the previous one, e.g. in case a
+	// request was interrupted while storing it.
+	var offset int64
+	for i, chunk := range chunks {
+		if chunk.Key != chunkKey(id, offset) {
+			return chunks[:i], nil
+		}
+		offset += chunk.Size
+	}
+	return chunks, nil
+}
+
+func (u *uploader) resumableOffset(ctx context.Context, id string) (int64, error) {
+	chunks, err := u.resumableChunks(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	var offset int64
+	for _, chunk := range chunks {
+		offset += chunk.Size
+	}
+	return offset, nil
+}
+
+// appendChunk stores a chunk of an upload at offset.
+func (u *uploader) appendChunk(ctx context.Context, info *resumableInfo, offset int64, chunk []byte) error {
+	if len(chunk) > 0 {
+		err := u.storage.Put(ctx, chunkKey(info.ID, offset), bytes.NewReader(chunk), "application/octet-stream")
+		if err != nil {
+			return err
+		}
+	}
+	return u.saveResumable(ctx, info)
+}
+
+// finishResumable validates and stores the file of a complete upload like
+// IServer.Upload, discarding the upload.
+func (u *uploader) finishResumable(ctx context.Context, info *resumableInfo) (*models.File, error) {
+	chunks, err := u.resumableChunks(ctx, info.ID)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, 0, info.Length)
+	for _, chunk := range chunks {
+		r, _, err := u.storage.Get(ctx, chunk.Key)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, data...)
+	}
+
+	// Failed files are discarded too, since they can't be uploaded again
+	// any differently.
+	defer u.deleteResumable(context.WithoutCancel(ctx), info.ID)
+	contentType, err := utils.ValidateContent(u.config, info.Field, content)
+	if err != nil {
+		return nil, err
+	}
+	return u.store(ctx, info.Field, info.Name, content, contentType, info.UploaderID)
+}
+
+func (u *uploader) deleteResumable(ctx context.Context, id string) error {
+	files, err := u.storage.List(ctx, resumablePrefix+id+"/")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := u.storage.Delete(ctx, file.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweep deletes the expired uploads every hour, until closing is done.
+func (u *uploader) sweep(closing context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closing.Done():
+			return
+		case <-ticker.C:
+			u.deleteExpired(closing)
+		}
+	}
+}
+
+// deleteExpired deletes the expired uploads.
+func (u *uploader) deleteExpired(ctx context.Context) {
+	files, err := u.storage.List(ctx, resumablePrefix)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Key, "/info.json") {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(file.Key, resumablePrefix), "/info.json")
+		info, err := u.resumableInfo(ctx, id)
+		if err == nil && time.Now().After(info.ExpiresAt) {
+			_ = u.deleteResumable(ctx, id)
+		}
+	}
+}
+
+// lock returns the lock of the upload of id.
+func (u *uploader) lock(id string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return &u.locks[h.Sum32()%uint32(len(u.locks))]
+}
+
+func chunkKey(id string, offset int64) string {
+	return fmt.Sprintf("%s%s/chunks/%020d", resumablePrefix, id, offset)
+}
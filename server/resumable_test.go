@@ -0,0 +1,59 @@
+package services
+
+import (
+	"net/http"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+)
+
+func TestTerminateWaitsForChunks(t *testing.T) {
+	cfg := config.NewConfigService()
+	cfg.SetString("storage-dir", t.TempDir())
+	c := factory(NewHTTPServerContainer)(cfg).(*httpServerContainer)
+	c.ResumableUpload("/files", "file", nil)
+	ts := c.TestServer()
+	defer ts.Close()
+
+	do := func(method, url string, header map[string]string) *http.Response {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Tus-Resumable", tusVersion)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+	res := do(http.MethodPost, ts.URL+"/files", map[string]string{"Upload-Length": "3"})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("POST = %d, want %d", res.StatusCode, http.StatusCreated)
+	}
+	location := res.Header.Get("Location")
+
+	// The lock is held as if a chunk were being appended.
+	lock := c.uploads.lock(path.Base(location))
+	lock.Lock()
+	done := make(chan int)
+	go func() {
+		done <- do(http.MethodDelete, ts.URL+location, nil).StatusCode
+	}()
+	select {
+	case status := <-done:
+		t.Fatalf("DELETE = %d while a chunk is appended", status)
+	case <-time.After(50 * time.Millisecond):
+	}
+	lock.Unlock()
+
+	if status := <-done; status != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want %d", status, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// routeRegistry keeps the metadata of the registered routes, independent of
+// the underneath server implementation.
+type routeRegistry struct {
+	mu     sync.RWMutex
+	routes []contracts.RouteInfo
+	names  map[string]int
+}
+
+func newRouteRegistry() *routeRegistry {
+	return &routeRegistry{
+		names: make(map[string]int),
+	}
+}
+
+// add records a route and panics on duplicate names, which is a programming
+// error like registering the same route twice.
+func (r *routeRegistry) add(info contracts.RouteInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info.Name != "" {
+		if _, ok := r.names[info.Name]; ok {
+			panic(fmt.Sprintf("route name %q is already registered", info.Name))
+		}
+		r.names[info.Name] = len(r.routes)
+	}
+	r.routes = append(r.routes, info)
+}
+
+func (r *routeRegistry) list() []contracts.RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]contracts.RouteInfo(nil), r.routes...)
+}
+
+// url builds the path of a named route, escaping params.
+func (r *routeRegistry) url(name string, params ...string) (string, error) {
+	r.mu.RLock()
+	i, ok := r.names[name]
+	var path string
+	if ok {
+		path = r.routes[i].Path
+	}
+	r.mu.RUnlock()
+
+	if !ok {
+		return "", contracts.ErrRouteNotFound
+	}
+
+	segments := strings.Split(path, "/")
+	n := 0
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") && segment != "*" {
+			continue
+		}
+		if n >= len(params) {
+			return "", fmt.Errorf("route %q needs more than %d params", name, len(params))
+		}
+		if segment == "*" {
+			// The wildcard spans segments, so slashes are kept.
+			segments[i] = strings.ReplaceAll(url.PathEscape(params[n]), "%2F", "/")
+		} else {
+			segments[i] = url.PathEscape(params[n])
+		}
+		n++
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// intersectRoles returns the roles present in both a and b.
+func intersectRoles(a, b []string) []string {
+	var roles []string
+	for _, role := range a {
+		for _, r := range b {
+			if strings.EqualFold(role, r) {
+				roles = append(roles, role)
+				break
+			}
+		}
+	}
+	return roles
+}
+
+// rateLimited wraps handler with the rate limit of a route, if any, named by
+// the route unless the limit is.
+func rateLimited(limiter contracts.IRateLimiter, info contracts.RouteInfo, handler contracts.Handler) contracts.Handler {
+	if info.RateLimit == nil {
+		return handler
+	}
+	limit := *info.RateLimit
+	if limit.Name == "" {
+		limit.Name = info.Method + " " + info.Path
+	}
+	return limiter.Limit(limit, handler)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+func TestRouteRegistryURL(t *testing.T) {
+	r := newRouteRegistry()
+	r.add(contracts.RouteInfo{Method: http.MethodGet, Path: "/v1/users", Name: "users"})
+	r.add(contracts.RouteInfo{Method: http.MethodGet, Path: "/v1/users/:id/files/:file", Name: "file"})
+	r.add(contracts.RouteInfo{Method: http.MethodGet, Path: "/static/*", Name: "static"})
+	r.add(contracts.RouteInfo{Method: http.MethodPost, Path: "/v1/unnamed"})
+
+	cases := []struct {
+		name   string
+		route  string
+		params []string
+		want   string
+		err    bool
+	}{
+		{name: "Static", route: "users", want: "/v1/users"},
+		{name: "Params", route: "file", params: []string{"7", "a.png"}, want: "/v1/users/7/files/a.png"},
+		{name: "Escaped", route: "file", params: []string{"a/b", "c d"}, want: "/v1/users/a%2Fb/files/c%20d"},
+		{name: "Wildcard", route: "static", params: []string{"css/site main.css"}, want: "/static/css/site%20main.css"},
+		{name: "MissingParams", route: "file", params: []string{"7"}, err: true},
+		{name: "Unknown", route: "unnamed", err: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := r.url(tc.route, tc.params...)
+			if (err != nil) != tc.err {
+				t.Fatalf("url = %v, want an error: %v", err, tc.err)
+			}
+			if got != tc.want {
+				t.Errorf("url = %q, want %q", got, tc.want)
+			}
+		})
+	}
+	if _, err := r.url("unnamed"); err != contracts.ErrRouteNotFound {
+		t.Errorf("url of an unknown name = %v, want %v", err, contracts.ErrRouteNotFound)
+	}
+}
+
+func TestRouteRegistryDuplicateName(t *testing.T) {
+	r := newRouteRegistry()
+	r.add(contracts.RouteInfo{Method: http.MethodGet, Path: "/a", Name: "a"})
+	// Unnamed routes never clash.
+	r.add(contracts.RouteInfo{Method: http.MethodGet, Path: "/b"})
+	r.add(contracts.RouteInfo{Method: http.MethodPost, Path: "/b"})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice didn't panic")
+		}
+		if got := len(r.list()); got != 3 {
+			t.Errorf("listed %d routes, want the 3 registered before the panic", got)
+		}
+	}()
+	r.add(contracts.RouteInfo{Method: http.MethodGet, Path: "/c", Name: "a"})
+}
+
+func TestIntersectRoles(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b []string
+		want []string
+	}{
+		{name: "Disjoint", a: []string{"admin"}, b: []string{"staff"}},
+		{name: "Common", a: []string{"admin", "staff", "user"}, b: []string{"user", "admin"}, want: []string{"admin", "user"}},
+		{name: "CaseInsensitive", a: []string{"Admin"}, b: []string{"admin"}, want: []string{"Admin"}},
+		{name: "Empty", a: nil, b: []string{"admin"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := intersectRoles(tc.a, tc.b); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("intersectRoles = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// containers are the constructors of the containers which the unit tests of
+// routing run against.
+var containers = map[string]func(contracts.IConfigService, contracts.ILogger, contracts.IRateLimiter, contracts.IStorage) contracts.IServerContainer{
+	"Echo": NewEchoServerContainer,
+	"HTTP": NewHTTPServerContainer,
+}
+
+func TestContainerRoutes(t *testing.T) {
+	handler := func(server contracts.IServer) error { return nil }
+	for name, newContainer := range containers {
+		t.Run(name, func(t *testing.T) {
+			c := factory(newContainer)(config.NewConfigService())
+			c.Route("", "/v1/users/:id", handler, contracts.Named("user"), contracts.WithTags("users"))
+			c.Route("patch", "/v1/users/:id", handler, contracts.WithRoles("admin"))
+
+			routes := c.Routes()
+			if len(routes) != 2 {
+				t.Fatalf("Routes = %+v, want 2 routes", routes)
+			}
+			if r := routes[0]; r.Method != http.MethodGet || r.Name != "user" || !reflect.DeepEqual(r.Tags, []string{"users"}) {
+				t.Errorf("Routes[0] = %+v, want the named GET route", r)
+			}
+			if r := routes[1]; r.Method != http.MethodPatch || !r.Authenticated || !reflect.DeepEqual(r.Roles, []string{"admin"}) {
+				t.Errorf("Routes[1] = %+v, want the PATCH route of admins", r)
+			}
+
+			if url, err := c.URL("user", "7"); err != nil || url != "/v1/users/7" {
+				t.Errorf("URL = %q, %v, want /v1/users/7", url, err)
+			}
+			defer func() {
+				if recover() == nil {
+					t.Error("registering a name twice didn't panic")
+				}
+			}()
+			c.Route(http.MethodDelete, "/v1/users/:id", handler, contracts.Named("user"))
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/netutil"
+)
+
+// securityHeadersMiddleware sets security headers if `server.security.enabled`
+// is set. Each setting is read from `server.security.<environment>.<key>` and
+// falls back to `server.security.<key>`, so e.g. HSTS can be enabled only in
+// production:
+//
+//   - hsts_max_age: seconds of `Strict-Transport-Security`, sent over HTTPS,
+//     which `X-Forwarded-Proto` tells only from `server.trusted_proxies`.
+//   - hsts_include_subdomains, hsts_preload: HSTS directives.
+//   - csp: `Content-Security-Policy`.
+//   - frame_options: `X-Frame-Options`, defaults to DENY.
+//   - referrer_policy: `Referrer-Policy`, defaults to
+//     strict-origin-when-cross-origin.
+func securityHeadersMiddleware(config contracts.IConfigService) func(http.Handler) http.Handler {
+	settingKey := func(key string) string {
+		envKey := fmt.Sprintf("server.security.%s.%s", config.Environment().Name(), key)
+		if config.String(envKey) != "" {
+			return envKey
+		}
+		return "server.security." + key
+	}
+	setting := func(key string) string {
+		return config.String(settingKey(key))
+	}
+	enabled := func(key string) bool {
+		return config.Bool(settingKey(key))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !enabled("enabled") {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			header := w.Header()
+			header.Set("X-Content-Type-Options", "nosniff")
+
+			frameOptions := setting("frame_options")
+			if frameOptions == "" {
+				frameOptions = "DENY"
+			}
+			header.Set("X-Frame-Options", frameOptions)
+
+			referrerPolicy := setting("referrer_policy")
+			if referrerPolicy == "" {
+				referrerPolicy = "strict-origin-when-cross-origin"
+			}
+			header.Set("Referrer-Policy", referrerPolicy)
+
+			if csp := setting("csp"); csp != "" {
+				header.Set("Content-Security-Policy", csp)
+			}
+
+			maxAge := setting("hsts_max_age")
+			if maxAge != "" && maxAge != "0" && isHTTPS(req, netutil.TrustedProxies(config)) {
+				hsts := "max-age=" + maxAge
+				if enabled("hsts_include_subdomains") {
+					hsts += "; includeSubDomains"
+				}
+				if enabled("hsts_preload") {
+					hsts += "; preload"
+				}
+				header.Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// isHTTPS reports whether the client connected over HTTPS, directly or
+// through one of the trusted reverse proxies.
+func isHTTPS(req *http.Request, trusted []*net.IPNet) bool {
+	return req.TLS != nil || netutil.FromTrustedProxy(req, trusted) &&
+		strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+)
+
+func TestSecurityHeadersHSTS(t *testing.T) {
+	cases := []struct {
+		name       string
+		config     map[string]string
+		remoteAddr string
+		proto      string
+		want       string
+	}{
+		{
+			name:       "Plain",
+			remoteAddr: "10.0.0.1:1234",
+			want:       "",
+		},
+		{
+			name:       "UntrustedForwardedProto",
+			remoteAddr: "10.0.0.1:1234",
+			proto:      "https",
+			want:       "",
+		},
+		{
+			name:       "TrustedForwardedProto",
+			config:     map[string]string{"server.trust_proxy": "true"},
+			remoteAddr: "10.0.0.1:1234",
+			proto:      "https",
+			want:       "max-age=600",
+		},
+		{
+			name:       "ForwardedProtoOfOtherProxy",
+			config:     map[string]string{"server.trust_proxy": "true", "server.trusted_proxies": "10.0.0.2"},
+			remoteAddr: "10.0.0.1:1234",
+			proto:      "https",
+			want:       "",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("server.security.enabled", "true")
+			cfg.SetString("server.security.hsts_max_age", "600")
+			for key, value := range tc.config {
+				cfg.SetString(key, value)
+			}
+			handler := securityHeadersMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tc.proto)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Strict-Transport-Security"); got != tc.want {
+				t.Errorf("Strict-Transport-Security = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,7 @@
 package services
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"mime/multipart"
@@ -9,10 +9,13 @@ import (
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
-	"text/template"
+	"sync"
 
 	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/encoder"
 	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/pagination"
+	"github.com/mostafasolati/leviathan/storage"
 	"github.com/mostafasolati/leviathan/utils"
 
 	"github.com/dgrijalva/jwt-go"
@@ -24,54 +27,80 @@ import (
 func NewEchoServerContainer(
 	config contracts.IConfigService,
 	logger contracts.ILogger,
+	rateLimiter contracts.IRateLimiter,
+	storage contracts.IStorage,
 ) contracts.IServerContainer {
 	e := echo.New()
 	e.HideBanner = true
 
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowHeaders: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch},
-	}))
+	e.Use(echo.WrapMiddleware(corsMiddleware(config)))
+	e.Use(echo.WrapMiddleware(securityHeadersMiddleware(config)))
 	e.Use(appDetectionMiddleware())
+	e.Use(echo.WrapMiddleware(websocketTokenMiddleware()))
 
-	return &serverContainer{
+	container := &serverContainer{
 		groups:        make(map[string]*echo.Group),
+		roles:         make(map[string][]string),
+		routes:        newRouteRegistry(),
+		encoders:      newEncoderRegistry(encoder.Defaults()...),
 		e:             e,
 		configService: config,
 		logger:        logger,
+		rateLimiter:   rateLimiter,
+		uploads:       newUploader(config, storage),
+		closing:       onShutdown(e.Server),
 	}
+	e.HTTPErrorHandler = container.errorHandler
+	return container
 }
 
 // SecureRoutes restrict access to certain routes by allowing access only to specified roles
 func (s *serverContainer) SecureRoutes(routes map[string][]string) {
-
-	config := middleware.JWTConfig{
-		Claims:     &models.UserClaims{},
-		SigningKey: []byte(s.configService.String("auth.jwt_secret")),
-		Skipper: func(c echo.Context) bool {
-			// Skip requests with no token.
-			return c.Request().Header.Get("Authorization") == ""
-		},
-	}
-
 	for route, roles := range routes {
-		g := s.e.Group(route, middleware.JWTWithConfig(config))
+		g := s.e.Group(route, s.jwtMiddleware())
 
 		g.Use(accessMiddleware(roles))
 		s.groups[route] = g
+		s.roles[route] = roles
 	}
 }
 
 // Route s a method to define a route for an API endpoint
-func (s *serverContainer) Route(method, path string, handler contracts.Handler) {
+func (s *serverContainer) Route(method, path string, handler contracts.Handler, options ...contracts.RouteOption) {
+
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	info := contracts.RouteInfo{Method: method, Path: path}
+	for _, option := range options {
+		option(&info)
+	}
+
+	// Wrap the handler with the route middleware now, and with the global
+	// middleware on each request, so later calls to Use take effect.
+	for i := len(info.Middleware) - 1; i >= 0; i-- {
+		handler = info.Middleware[i](handler)
+	}
+	handler = rateLimited(s.rateLimiter, info, handler)
 
 	h := func(c echo.Context) error {
-		server := NewServer(c, s.configService)
-		err := handler(server)
+		server := &echoServer{
+			c:             c,
+			configService: s.configService,
+			uploads:       s.uploads,
+			encoders:      s.encoders,
+			takeover:      takeover{closing: s.closing},
+		}
+		err := s.wrap(info, handler)(server)
+		server.release()
 
 		if err != nil {
 			c.Logger().Error(err)
+			if server.detached {
+				return nil
+			}
 			return server.JSON(http.StatusBadRequest, &models.Error{
 				Message: err.Error(),
 				Code:    http.StatusBadRequest,
@@ -81,33 +110,145 @@ func (s *serverContainer) Route(method, path string, handler contracts.Handler)
 	}
 
 	g := s.e.Group("")
-
-	for sr, group := range s.groups {
-		if strings.Contains(path, sr) {
-			g = group
-			path = strings.Replace(path, sr, "", 1)
-			break
+	routeRoles := info.Roles
+
+	sr, secured := securedBy(s.roles, path)
+	if secured {
+		g = s.groups[sr]
+		path = strings.Replace(path, sr, "", 1)
+		info.Roles = s.roles[sr]
+		if len(routeRoles) > 0 {
+			info.Roles = intersectRoles(info.Roles, routeRoles)
 		}
+		info.Authenticated = true
+	}
+
+	var m []echo.MiddlewareFunc
+	if info.Authenticated && !secured {
+		m = append(m, s.jwtMiddleware())
+	}
+	if info.Authenticated && (!secured || len(routeRoles) > 0) {
+		m = append(m, accessMiddleware(routeRoles))
 	}
 
-	switch method {
-	case http.MethodPost:
-		g.POST(path, h)
-	case http.MethodDelete:
-		g.DELETE(path, h)
-	case http.MethodPut:
-		g.PUT(path, h)
-	case http.MethodPatch:
-		g.PATCH(path, h)
-	default:
-		g.GET(path, h)
+	g.Add(method, path, h, m...)
+	s.routes.add(info)
+}
+
+// Use adds middleware to all routes
+func (s *serverContainer) Use(middleware ...contracts.Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// Group returns a sub-router for routes sharing a prefix and options
+func (s *serverContainer) Group(prefix string, options ...contracts.RouteOption) contracts.IRouter {
+	return &routerGroup{
+		router:  s,
+		prefix:  prefix,
+		options: options,
+	}
+}
+
+// wrap wraps a handler with the global middleware, and with the global rate
+// limit outermost unless the route is exempt from it
+func (s *serverContainer) wrap(info contracts.RouteInfo, handler contracts.Handler) contracts.Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		handler = s.middleware[i](handler)
+	}
+	if !info.Unlimited {
+		handler = s.rateLimiter.Global(handler)
 	}
+	return handler
+}
+
+func (s *serverContainer) jwtMiddleware() echo.MiddlewareFunc {
+	return middleware.JWTWithConfig(middleware.JWTConfig{
+		Claims:     &models.UserClaims{},
+		SigningKey: []byte(s.configService.String("auth.jwt.secret")),
+		Skipper: func(c echo.Context) bool {
+			// Skip requests with no token.
+			return c.Request().Header.Get("Authorization") == ""
+		},
+	})
+}
+
+// URL builds the path of a named route
+func (s *serverContainer) URL(name string, params ...string) (string, error) {
+	return s.routes.url(name, params...)
+}
+
+// Routes lists the registered routes
+func (s *serverContainer) Routes() []contracts.RouteInfo {
+	return s.routes.list()
+}
 
+// Storage returns the storage which uploaded files are kept in
+func (s *serverContainer) Storage() contracts.IStorage {
+	return s.uploads.storage
+}
+
+// File looks up the metadata of a file uploaded by uploaderID by its ID
+func (s *serverContainer) File(ctx context.Context, id string, uploaderID int) (*models.File, error) {
+	return utils.LookupUpload(ctx, s.uploads.storage, id, uploaderID)
+}
+
+// DeleteFile deletes a file uploaded by a user
+func (s *serverContainer) DeleteFile(ctx context.Context, id string, uploaderID int) error {
+	return s.uploads.delete(ctx, id, uploaderID)
+}
+
+// RegisterUploadProcessor adds processors of the files uploaded
+func (s *serverContainer) RegisterUploadProcessor(processors ...contracts.IUploadProcessor) {
+	s.uploads.register(processors...)
+}
+
+// ResumableUpload registers the routes of resumable uploads
+func (s *serverContainer) ResumableUpload(path, field string, handler func(server contracts.IServer, file *models.File) error, options ...contracts.RouteOption) {
+	registerResumableUpload(s, s.uploads, s.closing, path, field, handler, options...)
+}
+
+// RegisterEncoder adds formats of responses and requests
+func (s *serverContainer) RegisterEncoder(encoders ...contracts.IEncoder) {
+	s.encoders.register(encoders...)
 }
 
 // Run starts the server in given address
-func (s *serverContainer) Run(address string) {
-	s.e.Start(address)
+func (s *serverContainer) Run(address string) error {
+	return serve(s.e.Server, s.e, s.configService, s.logger, address)
+}
+
+// Shutdown gracefully stops the server
+func (s *serverContainer) Shutdown(ctx context.Context) error {
+	return s.e.Shutdown(ctx)
+}
+
+// errorHandler responds to errors of echo, e.g. unknown routes and invalid
+// tokens, in the same format as the errors of the handlers.
+func (s *serverContainer) errorHandler(err error, c echo.Context) {
+	code := http.StatusInternalServerError
+	message := http.StatusText(code)
+	if he, ok := err.(*echo.HTTPError); ok {
+		code = he.Code
+		message = fmt.Sprint(he.Message)
+	} else {
+		c.Logger().Error(err)
+	}
+
+	if c.Response().Committed {
+		return
+	}
+	if c.Request().Method == http.MethodHead {
+		_ = c.NoContent(code)
+		return
+	}
+	_ = c.JSON(code, &models.Error{
+		Message: message,
+		Code:    code,
+	})
 }
 
 // TestServer starts a test server
@@ -128,6 +269,11 @@ func accessMiddleware(roles []string) echo.MiddlewareFunc {
 
 			user := c.Get("user").(*jwt.Token).Claims.(*models.UserClaims)
 
+			// Any logged in user may access routes with no specific roles.
+			if len(roles) == 0 {
+				return next(c)
+			}
+
 			for _, role := range roles {
 				for _, r := range user.Roles {
 					if strings.EqualFold(role, r) {
@@ -145,19 +291,11 @@ func accessMiddleware(roles []string) echo.MiddlewareFunc {
 	}
 }
 
-// Determine client app using the `User-Agent` header.
-// Android app is known to send `okhttp/X.Y.Z`.
+// appDetectionMiddleware determines the client app sending the request
 func appDetectionMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			userAgent := c.Request().Header.Get("User-Agent")
-			if strings.Contains(userAgent, "okhttp") {
-				c.Set("app", models.AndroidApp)
-			} else if utils.IsMobileWeb(userAgent) {
-				c.Set("app", models.MobileWebApp)
-			} else {
-				c.Set("app", models.WebApp)
-			}
+			c.Set("app", detectApp(c.Request().Header.Get("User-Agent")))
 			return next(c)
 		}
 	}
@@ -166,8 +304,17 @@ func appDetectionMiddleware() echo.MiddlewareFunc {
 type serverContainer struct {
 	configService contracts.IConfigService
 	logger        contracts.ILogger
+	rateLimiter   contracts.IRateLimiter
+	uploads       *uploader
 	groups        map[string]*echo.Group
+	roles         map[string][]string
+	routes        *routeRegistry
+	encoders      *encoderRegistry
 	e             *echo.Echo
+	closing       context.Context
+
+	mu         sync.RWMutex
+	middleware []contracts.Middleware
 }
 
 // NewServer returns a new instance of IServer
@@ -177,17 +324,51 @@ func NewServer(c echo.Context, configService contracts.IConfigService) contracts
 	return &echoServer{
 		c:             c,
 		configService: configService,
+		uploads:       newUploader(configService, storage.NewLocalStorage(configService, filepath.Join(configService.StorageDir(), "files"))),
+		encoders:      newEncoderRegistry(encoder.Defaults()...),
+		takeover:      takeover{closing: context.Background()},
 	}
 }
 
+// Page reads the requested page
+func (s *echoServer) Page() models.Page {
+	return pagination.ParsePage(s.configService, s.c.Request().URL.Query())
+}
+
+// Cursor decodes the cursor of the requested page
+func (s *echoServer) Cursor(v interface{}) (int, error) {
+	return readCursor(s, s.configService, v)
+}
+
+// Paginate sends a page linking the other pages
+func (s *echoServer) Paginate(status int, p *models.Pagination) error {
+	return paginate(s, s.configService, status, p)
+}
+
+// PaginateCursor sends a page linking the next page by a signed cursor
+func (s *echoServer) PaginateCursor(status, perPage int, data, next interface{}) error {
+	return paginateCursor(s, s.configService, status, perPage, data, next)
+}
+
+// Stream sends server-sent events
+func (s *echoServer) Stream(handler func(stream contracts.IEventStream) error) error {
+	return s.stream(s.c.Response(), s.c.Request(), s.configService, handler)
+}
+
+// Upgrade upgrades the connection to a WebSocket
+func (s *echoServer) Upgrade() (contracts.IWebSocket, error) {
+	return s.upgrade(s.c.Response(), s.c.Request(), s.configService)
+}
+
 // SetHeader sets a http header
 func (s *echoServer) SetHeader(key, value string) {
 	s.c.Response().Header().Set(key, value)
 }
 
-// Response return a proper http response to the client
+// Response return a proper http response to the client in the format
+// negotiated by the `Accept` header
 func (s *echoServer) Response(status int, v interface{}) error {
-	return s.JSON(status, v)
+	return respond(s.c.Response(), s.c.Request(), s.encoders, status, v)
 }
 
 // Query returns a query string parameter
@@ -228,9 +409,7 @@ func (s *echoServer) Upload(field string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-
-	imagesRoot := filepath.Join(s.configService.StorageDir(), "files")
-	return utils.UploadFile(imagesRoot, file)
+	return s.uploads.upload(s.c.Request().Context(), field, file, s.User())
 }
 
 // User returns the logged in user
@@ -257,7 +436,9 @@ func (s *echoServer) Param(key string) string {
 
 // Bind binds a http request (posted data or query params) to a struct
 func (s *echoServer) Bind(in interface{}) error {
-	s.c.Response()
+	if decoded, err := s.encoders.decode(s.c.Request(), in); decoded {
+		return err
+	}
 	return s.c.Bind(in)
 }
 
@@ -288,21 +469,12 @@ func (s *echoServer) Attachment(path, name string) error {
 
 // HTML serves a Go HTML template
 func (s *echoServer) HTML(name string, data interface{}) error {
-	tmplPath := filepath.Join(
-		s.configService.StaticDir(),
-		fmt.Sprintf("templates/%s.gohtml", name),
-	)
-	tmpl, err := template.ParseFiles(tmplPath)
+	b, err := renderTemplate(s.configService, name, data)
 	if err != nil {
 		return err
 	}
 
-	var b bytes.Buffer
-	if err := tmpl.Execute(&b, data); err != nil {
-		return err
-	}
-
-	return s.c.HTMLBlob(http.StatusOK, b.Bytes())
+	return s.c.HTMLBlob(http.StatusOK, b)
 }
 
 // Redirect redirects the client to a url
@@ -321,4 +493,7 @@ func (s *echoServer) ResponseWriter() http.ResponseWriter {
 type echoServer struct {
 	c             echo.Context
 	configService contracts.IConfigService
+	uploads       *uploader
+	encoders      *encoderRegistry
+	takeover
 }
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/logger"
+	"github.com/mostafasolati/leviathan/ratelimit"
+	"github.com/mostafasolati/leviathan/server/servertest"
+	"github.com/mostafasolati/leviathan/storage"
+)
+
+// factory adapts a container constructor to servertest, giving it a memory
+// rate limiter and a local storage in the directory of the test.
+func factory(newContainer func(contracts.IConfigService, contracts.ILogger, contracts.IRateLimiter, contracts.IStorage) contracts.IServerContainer) servertest.Factory {
+	return func(config contracts.IConfigService) contracts.IServerContainer {
+		l := logger.NewLogger(config)
+		return newContainer(config, l, ratelimit.NewRateLimiter(config, l, ratelimit.NewMemoryStore()),
+			storage.NewLocalStorage(config, filepath.Join(config.StorageDir(), "files")))
+	}
+}
+
+func TestEchoContainer(t *testing.T) {
+	servertest.TestContainer(t, factory(NewEchoServerContainer))
+}
+
+func TestHTTPContainer(t *testing.T) {
+	servertest.TestContainer(t, factory(NewHTTPServerContainer))
+}
@@ -0,0 +1,222 @@
+package servertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+)
+
+// tusStep is a request of a resumable upload, made to its location unless
+// it's a creation.
+type tusStep struct {
+	method string
+	header map[string]string
+	body   []byte
+	token  string
+	status int
+	offset string
+}
+
+func tusCreate(length string) tusStep {
+	return tusStep{
+		method: http.MethodPost,
+		header: map[string]string{
+			"Upload-Length":   length,
+			"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("photo.png")),
+		},
+		status: http.StatusCreated,
+	}
+}
+
+func tusPatch(offset string, body []byte, status int, want string) tusStep {
+	return tusStep{
+		method: http.MethodPatch,
+		header: map[string]string{
+			"Upload-Offset": offset,
+			"Content-Type":  "application/offset+octet-stream",
+		},
+		body:   body,
+		status: status,
+		offset: want,
+	}
+}
+
+func tusHead(status int, offset string) tusStep {
+	return tusStep{method: http.MethodHead, status: status, offset: offset}
+}
+
+// testResumableUpload checks IServerContainer.ResumableUpload, whose
+// uploads take several requests.
+func testResumableUpload(t *testing.T, newContainer Factory) {
+	half := len(pngImage) / 2
+	length := strconv.Itoa(len(pngImage))
+
+	cases := []struct {
+		name   string
+		config []string
+		steps  []tusStep
+		file   string
+	}{
+		{
+			name: "ResumableUpload",
+			steps: []tusStep{
+				tusCreate(length),
+				tusHead(http.StatusOK, "0"),
+				tusPatch("0", pngImage[:half], http.StatusNoContent, strconv.Itoa(half)),
+				tusHead(http.StatusOK, strconv.Itoa(half)),
+				tusPatch(strconv.Itoa(half), pngImage[half:], http.StatusNoContent, length),
+				tusHead(http.StatusNotFound, ""),
+			},
+			file: contentID,
+		},
+		{
+			name: "ResumableUploadOptions",
+			steps: []tusStep{
+				{method: http.MethodOptions, status: http.StatusNoContent},
+			},
+		},
+		{
+			name: "ResumableUploadConflict",
+			steps: []tusStep{
+				tusCreate(length),
+				tusPatch("5", pngImage[5:], http.StatusConflict, ""),
+				tusHead(http.StatusOK, "0"),
+			},
+		},
+		{
+			name:   "ResumableUploadTooLarge",
+			config: []string{"upload.fields.image.max_size", "10"},
+			steps: []tusStep{
+				{
+					method: http.MethodPost,
+					header: map[string]string{"Upload-Length": length},
+					status: http.StatusRequestEntityTooLarge,
+				},
+			},
+		},
+		{
+			name: "ResumableUploadChunkTooLarge",
+			steps: []tusStep{
+				tusCreate("10"),
+				tusPatch("0", pngImage, http.StatusRequestEntityTooLarge, ""),
+			},
+		},
+		{
+			name: "ResumableUploadInvalid",
+			steps: []tusStep{
+				tusCreate("7"),
+				tusPatch("0", []byte("content"), http.StatusBadRequest, ""),
+				tusHead(http.StatusNotFound, ""),
+			},
+		},
+		{
+			name: "ResumableUploadTerminate",
+			steps: []tusStep{
+				tusCreate(length),
+				tusPatch("0", pngImage[:half], http.StatusNoContent, strconv.Itoa(half)),
+				{method: http.MethodDelete, status: http.StatusNoContent},
+				tusHead(http.StatusNotFound, ""),
+			},
+		},
+		{
+			name: "ResumableUploadOtherUser",
+			steps: []tusStep{
+				tusCreate(length),
+				{method: http.MethodHead, token: userToken(8, "user"), status: http.StatusNotFound},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "servertest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			cfg := newConfig(t, dir)
+			for i := 0; i+1 < len(tc.config); i += 2 {
+				cfg.SetString(tc.config[i], tc.config[i+1])
+			}
+			c := newContainer(cfg)
+			var uploaded *models.File
+			c.ResumableUpload("/uploads", "image", func(server contracts.IServer, file *models.File) error {
+				uploaded = file
+				return nil
+			}, contracts.WithRoles("user"))
+
+			ts := c.TestServer()
+			defer ts.Close()
+
+			location := "/uploads"
+			for i, step := range tc.steps {
+				url := ts.URL + location
+				if step.method == http.MethodPost || step.method == http.MethodOptions {
+					url = ts.URL + "/uploads"
+				}
+				req, err := http.NewRequest(step.method, url, bytes.NewReader(step.body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for key, value := range step.header {
+					req.Header.Set(key, value)
+				}
+				if step.token == "" {
+					step.token = token("user")
+				}
+				req.Header.Set("Authorization", "Bearer "+step.token)
+				req.Header.Set("Tus-Resumable", "1.0.0")
+
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+
+				if res.StatusCode != step.status {
+					t.Fatalf("step %d: %s status = %d, want %d", i, step.method, res.StatusCode, step.status)
+				}
+				if got := res.Header.Get("Tus-Resumable"); got != "1.0.0" {
+					t.Errorf("step %d: Tus-Resumable = %q, want 1.0.0", i, got)
+				}
+				if step.offset != "" {
+					if got := res.Header.Get("Upload-Offset"); got != step.offset {
+						t.Errorf("step %d: Upload-Offset = %q, want %q", i, got, step.offset)
+					}
+				}
+				if step.method == http.MethodPost && res.StatusCode == http.StatusCreated {
+					location = res.Header.Get("Location")
+					if location == "" || res.Header.Get("Upload-Expires") == "" {
+						t.Fatalf("step %d: created without Location and Upload-Expires", i)
+					}
+				}
+				if step.method == http.MethodOptions && res.Header.Get("Tus-Version") != "1.0.0" {
+					t.Errorf("step %d: Tus-Version = %q, want 1.0.0", i, res.Header.Get("Tus-Version"))
+				}
+			}
+
+			if tc.file == "" {
+				if uploaded != nil {
+					t.Errorf("uploaded %+v, want no file", uploaded)
+				}
+				return
+			}
+			if uploaded == nil || uploaded.ID != tc.file || uploaded.Name != "photo.png" || uploaded.UploaderID != 7 {
+				t.Fatalf("uploaded %+v, want %s of the user 7", uploaded, tc.file)
+			}
+			if _, err := c.File(context.Background(), tc.file, 7); err != nil {
+				t.Errorf("File(%s) = %v", tc.file, err)
+			}
+		})
+	}
+}
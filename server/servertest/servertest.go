@@ -0,0 +1,1126 @@
+// Package servertest provides a conformance suite for implementations of
+// contracts.IServerContainer, so the server backends behave the same.
+package servertest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/pagination"
+	"github.com/mostafasolati/leviathan/utils"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// pngImage is a 40x20 PNG image, and contentID the ID of it once uploaded.
+var (
+	pngImage = func() []byte {
+		var buf bytes.Buffer
+		_ = png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)))
+		return buf.Bytes()
+	}()
+	contentID = fmt.Sprintf("%x.png", sha256.Sum256(pngImage))
+)
+
+// Factory creates the IServerContainer under test.
+type Factory func(config contracts.IConfigService) contracts.IServerContainer
+
+const secret = "servertest-secret"
+
+type testCase struct {
+	name     string
+	register func(c contracts.IServerContainer, cfg contracts.IConfigService)
+	request  func(url string) *http.Request
+	check    func(t *testing.T, res *http.Response, body string)
+}
+
+type item struct {
+	Name  string   `json:"name" xml:"name" query:"name" form:"name"`
+	Count int      `json:"count" xml:"count" query:"count" form:"count"`
+	Tags  []string `json:"tags" xml:"tags" query:"tags" form:"tags"`
+}
+
+// textEncoder is a custom encoder of `text/plain`.
+type textEncoder struct{}
+
+func (textEncoder) ContentType() string  { return "text/plain; charset=UTF-8" }
+func (textEncoder) MediaTypes() []string { return []string{"text/plain"} }
+
+func (textEncoder) Encode(w io.Writer, v interface{}) error {
+	_, err := fmt.Fprint(w, v)
+	return err
+}
+
+func (textEncoder) Decode(r io.Reader, v interface{}) error {
+	return contracts.ErrNotEncodable
+}
+
+// TestContainer runs the conformance suite against the containers created by
+// newContainer, covering every IServer method, authentication, middleware,
+// groups and named routes, e.g:
+//
+//	func TestEcho(t *testing.T) {
+//	    servertest.TestContainer(t, func(config contracts.IConfigService) contracts.IServerContainer {
+//	        return services.NewEchoServerContainer(config, logger, rateLimiter, storage.NewMemoryStorage(config))
+//	    })
+//	}
+func TestContainer(t *testing.T, newContainer Factory) {
+	for _, tc := range testCases() {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "servertest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			cfg := newConfig(t, dir)
+			c := newContainer(cfg)
+			tc.register(c, cfg)
+
+			ts := c.TestServer()
+			defer ts.Close()
+
+			client := &http.Client{
+				CheckRedirect: func(*http.Request, []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+			res, err := client.Do(tc.request(ts.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, res, string(body))
+		})
+	}
+
+	testWebSocket(t, newContainer)
+	testResumableUpload(t, newContainer)
+}
+
+// newConfig configures the container with a static directory holding a file
+// and a template, and an empty storage directory.
+func newConfig(t *testing.T, dir string) contracts.IConfigService {
+	static := filepath.Join(dir, "static")
+	files := map[string]string{
+		"file.txt":                  "file content",
+		"site/index.html":           "index page",
+		"templates/greeting.gohtml": "Hello {{.}}!",
+	}
+	for name, content := range files {
+		path := filepath.Join(static, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.NewConfigService()
+	cfg.SetString("static-dir", static)
+	cfg.SetString("storage-dir", filepath.Join(dir, "storage"))
+	cfg.SetString("auth.jwt.secret", secret)
+	return cfg
+}
+
+func testCases() []testCase {
+	ok := func(body string) func(server contracts.IServer) error {
+		return func(server contracts.IServer) error {
+			return server.String(http.StatusOK, body)
+		}
+	}
+
+	return []testCase{
+		{
+			name: "Request",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodPatch, "/request", func(server contracts.IServer) error {
+					r := server.Request()
+					return server.String(http.StatusOK, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Test"))
+				})
+			},
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodPatch, url+"/request", nil)
+				req.Header.Set("X-Test", "yes")
+				return req
+			},
+			check: expect(http.StatusOK, "PATCH /request yes"),
+		},
+		{
+			name: "ResponseWriter",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/writer", func(server contracts.IServer) error {
+					w := server.ResponseWriter()
+					w.Header().Set("X-Test", "yes")
+					w.WriteHeader(http.StatusAccepted)
+					_, err := w.Write([]byte("written"))
+					return err
+				})
+			},
+			request: get("/writer"),
+			check: all(
+				expect(http.StatusAccepted, "written"),
+				expectHeader("X-Test", "yes"),
+			),
+		},
+		{
+			name:     "BindJSON",
+			register: bindRoute(http.MethodPost),
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodPost, url+"/bind", strings.NewReader(`{"name":"a","count":2,"tags":["x","y"]}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			check: expectJSON(http.StatusOK, &item{Name: "a", Count: 2, Tags: []string{"x", "y"}}),
+		},
+		{
+			name:     "BindXML",
+			register: bindRoute(http.MethodPost),
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodPost, url+"/bind", strings.NewReader(`<item><name>a</name><count>2</count><tags>x</tags></item>`))
+				req.Header.Set("Content-Type", "application/xml")
+				return req
+			},
+			check: expectJSON(http.StatusOK, &item{Name: "a", Count: 2, Tags: []string{"x"}}),
+		},
+		{
+			name:     "BindForm",
+			register: bindRoute(http.MethodPost),
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodPost, url+"/bind", strings.NewReader("name=a&count=2&tags=x&tags=y"))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return req
+			},
+			check: expectJSON(http.StatusOK, &item{Name: "a", Count: 2, Tags: []string{"x", "y"}}),
+		},
+		{
+			name:     "BindQuery",
+			register: bindRoute(http.MethodGet),
+			request:  get("/bind?name=a&count=2&tags=x&tags=y"),
+			check:    expectJSON(http.StatusOK, &item{Name: "a", Count: 2, Tags: []string{"x", "y"}}),
+		},
+		{
+			name:     "BindEmptyBody",
+			register: bindRoute(http.MethodPost),
+			request: func(url string) *http.Request {
+				return newRequest(http.MethodPost, url+"/bind", nil)
+			},
+			check: expectError(http.StatusBadRequest),
+		},
+		{
+			name:     "BindInvalidJSON",
+			register: bindRoute(http.MethodPost),
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodPost, url+"/bind", strings.NewReader(`{"count":"two"}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			check: expectError(http.StatusBadRequest),
+		},
+		{
+			name:     "BindUnsupportedMediaType",
+			register: bindRoute(http.MethodPost),
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodPost, url+"/bind", strings.NewReader("a"))
+				req.Header.Set("Content-Type", "application/octet-stream")
+				return req
+			},
+			check: expectError(http.StatusBadRequest),
+		},
+		{
+			name: "RawBody",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodPut, "/raw", func(server contracts.IServer) error {
+					body, err := server.RawBody()
+					if err != nil {
+						return err
+					}
+					return server.String(http.StatusOK, string(body))
+				})
+			},
+			request: func(url string) *http.Request {
+				return newRequest(http.MethodPut, url+"/raw", strings.NewReader("raw body"))
+			},
+			check: expect(http.StatusOK, "raw body"),
+		},
+		{
+			name: "String",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/string", func(server contracts.IServer) error {
+					return server.String(http.StatusCreated, "created")
+				})
+			},
+			request: get("/string"),
+			check: all(
+				expect(http.StatusCreated, "created"),
+				expectContentType("text/plain"),
+			),
+		},
+		{
+			name: "JSON",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/json", func(server contracts.IServer) error {
+					return server.JSON(http.StatusCreated, &item{Name: "a"})
+				})
+			},
+			request: get("/json"),
+			check: all(
+				expectJSON(http.StatusCreated, &item{Name: "a"}),
+				expectContentType("application/json"),
+			),
+		},
+		{
+			name: "Response",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/response", func(server contracts.IServer) error {
+					return server.Response(http.StatusOK, &item{Name: "a"})
+				})
+			},
+			request: get("/response"),
+			check:   expectJSON(http.StatusOK, &item{Name: "a"}),
+		},
+		{
+			name:     "ResponseXML",
+			register: responseRoute(&item{Name: "a", Count: 2}),
+			request:  withAccept(get("/response"), "application/xml, */*;q=0.1"),
+			check: all(
+				expect(http.StatusOK, xml.Header+"<item><name>a</name><count>2</count></item>"),
+				expectContentType("application/xml"),
+			),
+		},
+		{
+			name:     "ResponseNotAcceptable",
+			register: responseRoute(&item{Name: "a"}),
+			request:  withAccept(get("/response"), "text/csv, application/json;q=0"),
+			check:    expectError(http.StatusNotAcceptable),
+		},
+		{
+			name: "RegisterEncoder",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.RegisterEncoder(textEncoder{})
+				responseRoute(&item{Name: "a"})(c, cfg)
+			},
+			request: withAccept(get("/response"), "text/plain"),
+			check: all(
+				expect(http.StatusOK, "&{a 0 []}"),
+				expectContentType("text/plain"),
+			),
+		},
+		{
+			name:     "File",
+			register: fileRoute("file.txt"),
+			request:  get("/file"),
+			check: all(
+				expect(http.StatusOK, "file content"),
+				expectContentType("text/plain"),
+			),
+		},
+		{
+			name:     "FileDirectoryIndex",
+			register: fileRoute("site"),
+			request:  get("/file"),
+			check:    expect(http.StatusOK, "index page"),
+		},
+		{
+			name:     "FileNotFound",
+			register: fileRoute("missing.txt"),
+			request:  get("/file"),
+			check:    expectError(http.StatusBadRequest),
+		},
+		{
+			name: "Attachment",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/attachment", func(server contracts.IServer) error {
+					return server.Attachment(filepath.Join(cfg.StaticDir(), "file.txt"), "report.txt")
+				})
+			},
+			request: get("/attachment"),
+			check: all(
+				expect(http.StatusOK, "file content"),
+				expectHeader("Content-Disposition", `attachment; filename="report.txt"`),
+			),
+		},
+		{
+			name: "HTML",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/html", func(server contracts.IServer) error {
+					return server.HTML("greeting", "world")
+				})
+			},
+			request: get("/html"),
+			check: all(
+				expect(http.StatusOK, "Hello world!"),
+				expectContentType("text/html"),
+			),
+		},
+		{
+			name: "Redirect",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/redirect", func(server contracts.IServer) error {
+					return server.Redirect(http.StatusFound, "/target")
+				})
+			},
+			request: get("/redirect"),
+			check: all(
+				expect(http.StatusFound, ""),
+				expectHeader("Location", "/target"),
+			),
+		},
+		{
+			name: "RedirectInvalidStatus",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/redirect", func(server contracts.IServer) error {
+					return server.Redirect(http.StatusOK, "/target")
+				})
+			},
+			request: get("/redirect"),
+			check:   expectError(http.StatusBadRequest),
+		},
+		{
+			name: "Upload",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodPost, "/upload", func(server contracts.IServer) error {
+					id, err := server.Upload("image")
+					if err != nil {
+						return err
+					}
+					r, _, err := c.Storage().Get(context.Background(), utils.OriginalKey(id))
+					if err != nil {
+						return err
+					}
+					defer r.Close()
+					content, err := ioutil.ReadAll(r)
+					if err != nil {
+						return err
+					}
+					return server.String(http.StatusOK, id+" "+strconv.FormatBool(bytes.Equal(content, pngImage)))
+				})
+			},
+			// The type is detected by the content, not the header.
+			request: upload("/upload", "image", "photo.png", "image/jpeg", pngImage),
+			check:   expect(http.StatusOK, contentID+" true"),
+		},
+		{
+			name: "UploadMetadata",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.SecureRoutes(map[string][]string{"/secure": {"user"}})
+				c.Route(http.MethodPost, "/secure/upload", func(server contracts.IServer) error {
+					id, err := server.Upload("image")
+					if err != nil {
+						return err
+					}
+					file, err := c.File(context.Background(), id, server.User().ID)
+					if err != nil {
+						return err
+					}
+					file.CreatedAt = time.Time{}
+					return server.JSON(http.StatusOK, file)
+				})
+			},
+			request: withToken(upload("/secure/upload", "image", "photo.png", "image/png", pngImage), "user"),
+			check: expectJSON(http.StatusOK, &models.File{
+				ID:          contentID,
+				Name:        "photo.png",
+				Size:        int64(len(pngImage)),
+				ContentType: "image/png",
+				UploaderID:  7,
+			}),
+		},
+		{
+			name:     "UploadTooLarge",
+			register: uploadRoute("upload.fields.image.max_size", "10"),
+			request:  upload("/upload", "image", "photo.png", "image/png", pngImage),
+			check:    expectError(http.StatusBadRequest),
+		},
+		{
+			name: "UploadedFileNotFound",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/files/:id", func(server contracts.IServer) error {
+					_, err := c.File(context.Background(), server.Param("id"), 0)
+					return err
+				})
+			},
+			request: get("/files/missing.png"),
+			check:   expectError(http.StatusBadRequest),
+		},
+		{
+			name: "UploadedFile",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodPost, "/upload", func(server contracts.IServer) error {
+					header, err := server.UploadedFile("document")
+					if err != nil {
+						return err
+					}
+					return server.String(http.StatusOK, header.Filename)
+				})
+			},
+			request: upload("/upload", "document", "report.pdf", "application/pdf", []byte("content")),
+			check:   expect(http.StatusOK, "report.pdf"),
+		},
+		{
+			name: "UploadedFileMissing",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodPost, "/upload", func(server contracts.IServer) error {
+					_, err := server.UploadedFile("missing")
+					return err
+				})
+			},
+			request: upload("/upload", "document", "report.pdf", "application/pdf", []byte("content")),
+			check:   expectError(http.StatusBadRequest),
+		},
+		{
+			name:     "UserAnonymous",
+			register: userRoute(),
+			request:  get("/user"),
+			check:    expect(http.StatusOK, "anonymous"),
+		},
+		{
+			name:     "UserAuthenticated",
+			register: userRoute(contracts.WithRoles()),
+			request:  withToken(get("/user"), "admin"),
+			check:    expect(http.StatusOK, "7"),
+		},
+		{
+			name:     "UserMissingToken",
+			register: userRoute(contracts.WithRoles()),
+			request:  get("/user"),
+			check:    expectError(http.StatusUnauthorized),
+		},
+		{
+			name:     "UserInvalidToken",
+			register: userRoute(contracts.WithRoles()),
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodGet, url+"/user", nil)
+				req.Header.Set("Authorization", "Bearer invalid")
+				return req
+			},
+			check: expectError(http.StatusUnauthorized),
+		},
+		{
+			name:     "UserRoleAllowed",
+			register: userRoute(contracts.WithRoles("admin", "operator")),
+			request:  withToken(get("/user"), "operator"),
+			check:    expect(http.StatusOK, "7"),
+		},
+		{
+			name:     "UserRoleForbidden",
+			register: userRoute(contracts.WithRoles("admin")),
+			request:  withToken(get("/user"), "customer"),
+			check:    expectError(http.StatusForbidden),
+		},
+		{
+			name: "SecureRoutes",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.SecureRoutes(map[string][]string{"/admin": {"admin"}})
+				c.Route(http.MethodGet, "/admin/user", ok("secured"))
+			},
+			request: withToken(get("/admin/user"), "customer"),
+			check:   expectError(http.StatusForbidden),
+		},
+		{
+			name: "SecureRoutesAllowed",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.SecureRoutes(map[string][]string{"/admin": {"admin"}})
+				c.Route(http.MethodGet, "/admin/user", ok("secured"))
+			},
+			request: withToken(get("/admin/user"), "admin"),
+			check:   expect(http.StatusOK, "secured"),
+		},
+		{
+			name: "App",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/app", func(server contracts.IServer) error {
+					return server.String(http.StatusOK, server.App().Name())
+				})
+			},
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodGet, url+"/app", nil)
+				req.Header.Set("User-Agent", "okhttp/4.9.0")
+				return req
+			},
+			check: expect(http.StatusOK, models.AndroidApp.Name()),
+		},
+		{
+			name: "Page",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				cfg.SetString("server.pagination.max_per_page", "50")
+				c.Route(http.MethodGet, "/page", func(server contracts.IServer) error {
+					return server.JSON(http.StatusOK, server.Page())
+				})
+			},
+			request: get("/page?page=3&per_page=500"),
+			check:   expectJSON(http.StatusOK, models.Page{Number: 3, PerPage: 50}),
+		},
+		{
+			name: "Paginate",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/items", func(server contracts.IServer) error {
+					page := server.Page()
+					return server.Paginate(http.StatusOK, pagination.New(25, page, []int{11, 12, 13, 14, 15}))
+				})
+			},
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodGet, url+"/items?page=3&per_page=5&q=a", nil)
+				req.Host = "example.com"
+				return req
+			},
+			check: all(
+				expectJSON(http.StatusOK, &models.Pagination{
+					Total: 25, PerPage: 5, CurrentPage: 3, LastPage: 5, From: 11, To: 15,
+					Data: []int{11, 12, 13, 14, 15},
+				}),
+				expectHeader("Link", strings.Join([]string{
+					`<http://example.com/items?page=1&per_page=5&q=a>; rel="first"`,
+					`<http://example.com/items?page=2&per_page=5&q=a>; rel="prev"`,
+					`<http://example.com/items?page=4&per_page=5&q=a>; rel="next"`,
+					`<http://example.com/items?page=5&per_page=5&q=a>; rel="last"`,
+				}, ", ")),
+			),
+		},
+		{
+			name:     "Cursor",
+			register: cursorRoute(),
+			request: func(url string) *http.Request {
+				res, err := http.Get(url + "/items?per_page=2")
+				if err != nil {
+					panic(err)
+				}
+				defer res.Body.Close()
+				var first models.CursorPagination
+				if err := json.NewDecoder(res.Body).Decode(&first); err != nil {
+					panic(err)
+				}
+				return newRequest(http.MethodGet, url+"/items?per_page=2&cursor="+first.NextCursor, nil)
+			},
+			check: func(t *testing.T, res *http.Response, body string) {
+				var page models.CursorPagination
+				if err := json.Unmarshal([]byte(body), &page); err != nil {
+					t.Fatal(err)
+				}
+				if got := mustJSON(page.Data); string(got) != "[3,4]\n" {
+					t.Errorf("data = %s, want [3,4]", got)
+				}
+				if page.NextCursor == "" || !strings.Contains(res.Header.Get("Link"), `rel="next"`) {
+					t.Errorf("next page isn't linked")
+				}
+			},
+		},
+		{
+			name:     "FilterDisallowed",
+			register: filterRoute(),
+			request:  get("/items?filter[secret]=x"),
+			check:    expectError(http.StatusBadRequest),
+		},
+		{
+			name:     "Stream",
+			register: streamRoute(),
+			request:  get("/events"),
+			check: all(
+				expect(http.StatusOK, "id: 1\nevent: order\ndata: {\"id\":1}\n\n"+
+					"id: 2\nevent: order\ndata: {\"id\":2}\n\n"+
+					"retry: 1000\ndata: two\ndata: lines\n\n"),
+				expectContentType("text/event-stream"),
+			),
+		},
+		{
+			name:     "StreamResume",
+			register: streamRoute(),
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodGet, url+"/events", nil)
+				req.Header.Set("Last-Event-ID", "1")
+				return req
+			},
+			check: expect(http.StatusOK, "id: 2\nevent: order\ndata: {\"id\":2}\n\n"+
+				"retry: 1000\ndata: two\ndata: lines\n\n"),
+		},
+		{
+			name: "StreamError",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/events", func(server contracts.IServer) error {
+					return server.Stream(func(stream contracts.IEventStream) error {
+						if err := stream.Send(contracts.Event{Data: "sent"}); err != nil {
+							return err
+						}
+						return errors.New("failed")
+					})
+				})
+			},
+			request: get("/events"),
+			// Errors can't be sent once the stream has started.
+			check: expect(http.StatusOK, "data: sent\n\n"),
+		},
+		{
+			name: "Param",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/items/:id/parts/:part", func(server contracts.IServer) error {
+					return server.String(http.StatusOK, server.Param("id")+" "+server.Param("part"))
+				})
+			},
+			request: get("/items/12/parts/wheel"),
+			check:   expect(http.StatusOK, "12 wheel"),
+		},
+		{
+			name: "ParamWildcard",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/files/*", func(server contracts.IServer) error {
+					return server.String(http.StatusOK, server.Param("*"))
+				})
+			},
+			request: get("/files/a/b.txt"),
+			check:   expect(http.StatusOK, "a/b.txt"),
+		},
+		{
+			name: "Query",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/query", func(server contracts.IServer) error {
+					return server.String(http.StatusOK, server.Query("q")+"|"+server.Query("missing"))
+				})
+			},
+			request: get("/query?q=search"),
+			check:   expect(http.StatusOK, "search|"),
+		},
+		{
+			name: "QueryParams",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/query", func(server contracts.IServer) error {
+					return server.JSON(http.StatusOK, server.QueryParams())
+				})
+			},
+			request: get("/query?a=1&b=2&a=3"),
+			check:   expectJSON(http.StatusOK, map[string]string{"a": "1", "b": "2"}),
+		},
+		{
+			name: "FormParams",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodPost, "/form", func(server contracts.IServer) error {
+					return server.JSON(http.StatusOK, server.FormParams())
+				})
+			},
+			request: func(url string) *http.Request {
+				req := newRequest(http.MethodPost, url+"/form?b=2", strings.NewReader("a=1"))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return req
+			},
+			check: expectJSON(http.StatusOK, map[string]string{"a": "1", "b": "2"}),
+		},
+		{
+			name: "SetHeader",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/header", func(server contracts.IServer) error {
+					server.SetHeader("X-Test", "yes")
+					return server.String(http.StatusOK, "")
+				})
+			},
+			request: get("/header"),
+			check:   expectHeader("X-Test", "yes"),
+		},
+		{
+			name: "HandlerError",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/error", func(server contracts.IServer) error {
+					return errors.New("failed")
+				})
+			},
+			request: get("/error"),
+			check:   expectJSON(http.StatusBadRequest, &models.Error{Message: "failed", Code: http.StatusBadRequest}),
+		},
+		{
+			name: "NotFound",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/found", ok("found"))
+			},
+			request: get("/missing"),
+			check:   expectError(http.StatusNotFound),
+		},
+		{
+			name: "MethodNotAllowed",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/found", ok("found"))
+			},
+			request: func(url string) *http.Request {
+				return newRequest(http.MethodPost, url+"/found", nil)
+			},
+			check: expectError(http.StatusMethodNotAllowed),
+		},
+		{
+			name: "ExactRootPath",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/", ok("root"))
+			},
+			request: get("/other"),
+			check:   expectError(http.StatusNotFound),
+		},
+		{
+			name: "Methods",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route(http.MethodGet, "/method", ok("get"))
+				c.Route(http.MethodDelete, "/method", ok("delete"))
+			},
+			request: func(url string) *http.Request {
+				return newRequest(http.MethodDelete, url+"/method", nil)
+			},
+			check: expect(http.StatusOK, "delete"),
+		},
+		{
+			name: "Middleware",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Use(trace("global"))
+				g := c.Group("/v1", contracts.WithMiddleware(trace("group")))
+				g.Route(http.MethodGet, "/trace", func(server contracts.IServer) error {
+					return server.String(http.StatusOK, server.Request().Header.Get("X-Trace"))
+				}, contracts.WithMiddleware(trace("route")))
+			},
+			request: get("/v1/trace"),
+			check:   expect(http.StatusOK, "global,group,route"),
+		},
+		{
+			name: "URL",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Group("/v1").Route(http.MethodGet, "/items/:id", ok(""), contracts.Named("item"))
+				c.Route(http.MethodGet, "/url", func(server contracts.IServer) error {
+					url, err := c.URL("item", "a b")
+					if err != nil {
+						return err
+					}
+					return server.String(http.StatusOK, url)
+				})
+			},
+			request: get("/url"),
+			check:   expect(http.StatusOK, "/v1/items/a%20b"),
+		},
+		{
+			name: "Routes",
+			register: func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+				c.Route("", "/a", ok(""), contracts.Named("a"))
+				c.Route(http.MethodPost, "/b", ok(""), contracts.WithRoles("admin"))
+				c.Route(http.MethodGet, "/routes", func(server contracts.IServer) error {
+					return server.JSON(http.StatusOK, c.Routes()[:2])
+				})
+			},
+			request: get("/routes"),
+			check: expectJSON(http.StatusOK, []contracts.RouteInfo{
+				{Method: http.MethodGet, Path: "/a", Name: "a"},
+				{Method: http.MethodPost, Path: "/b", Authenticated: true, Roles: []string{"admin"}},
+			}),
+		},
+	}
+}
+
+func bindRoute(method string) func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+	return func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+		c.Route(method, "/bind", func(server contracts.IServer) error {
+			var in item
+			if err := server.Bind(&in); err != nil {
+				return err
+			}
+			return server.JSON(http.StatusOK, &in)
+		})
+	}
+}
+
+func responseRoute(v interface{}) func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+	return func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+		c.Route(http.MethodGet, "/response", func(server contracts.IServer) error {
+			return server.Response(http.StatusOK, v)
+		})
+	}
+}
+
+// streamRoute streams the events after the last one the client received.
+func streamRoute() func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+	return func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+		c.Route(http.MethodGet, "/events", func(server contracts.IServer) error {
+			return server.Stream(func(stream contracts.IEventStream) error {
+				last, _ := strconv.Atoi(stream.LastEventID())
+				for id := last + 1; id <= 2; id++ {
+					event := contracts.Event{
+						ID:    strconv.Itoa(id),
+						Event: "order",
+						Data:  map[string]int{"id": id},
+					}
+					if err := stream.Send(event); err != nil {
+						return err
+					}
+				}
+				return stream.Send(contracts.Event{Data: "two\nlines", Retry: time.Second})
+			})
+		})
+	}
+}
+
+// filterRoute responds with the SQL built from the filters of the request.
+func filterRoute() func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+	spec := models.NewSpec(map[string]models.Field{
+		"name":   {Ops: []models.Op{models.Like}, Selectable: true},
+		"age":    {Type: models.IntField, Ops: []models.Op{models.Gte}, Sortable: true, Selectable: true},
+		"secret": {},
+	}, "")
+	return func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+		c.Route(http.MethodGet, "/items", func(server contracts.IServer) error {
+			q, err := spec.Parse(server.QueryParams())
+			if err != nil {
+				return err
+			}
+			where, args := q.Where(0)
+			return server.JSON(http.StatusOK, map[string]interface{}{
+				"where":    where,
+				"args":     args,
+				"order_by": q.OrderBy(),
+				"columns":  q.Columns(),
+			})
+		})
+	}
+}
+
+// cursorRoute lists 1 to 5 by cursors.
+func cursorRoute() func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+	return func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+		c.Route(http.MethodGet, "/items", func(server contracts.IServer) error {
+			var position struct {
+				After int `json:"after"`
+			}
+			perPage, err := server.Cursor(&position)
+			if err != nil {
+				return err
+			}
+
+			var items []int
+			for i := position.After + 1; i <= 5 && len(items) < perPage; i++ {
+				items = append(items, i)
+			}
+			var next interface{}
+			if len(items) > 0 && items[len(items)-1] < 5 {
+				next = map[string]int{"after": items[len(items)-1]}
+			}
+			return server.PaginateCursor(http.StatusOK, perPage, items, next)
+		})
+	}
+}
+
+func fileRoute(name string) func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+	return func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+		c.Route(http.MethodGet, "/file", func(server contracts.IServer) error {
+			return server.File(filepath.Join(cfg.StaticDir(), name))
+		})
+	}
+}
+
+func userRoute(options ...contracts.RouteOption) func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+	return func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+		c.Route(http.MethodGet, "/user", func(server contracts.IServer) error {
+			user := server.User()
+			if user == nil {
+				return server.String(http.StatusOK, "anonymous")
+			}
+			return server.String(http.StatusOK, strconv.Itoa(user.ID))
+		}, options...)
+	}
+}
+
+func trace(name string) contracts.Middleware {
+	return func(next contracts.Handler) contracts.Handler {
+		return func(server contracts.IServer) error {
+			header := server.Request().Header
+			trace := name
+			if previous := header.Get("X-Trace"); previous != "" {
+				trace = previous + "," + name
+			}
+			header.Set("X-Trace", trace)
+			return next(server)
+		}
+	}
+}
+
+func newRequest(method, url string, body *strings.Reader) *http.Request {
+	var req *http.Request
+	var err error
+	if body == nil {
+		req, err = http.NewRequest(method, url, nil)
+	} else {
+		req, err = http.NewRequest(method, url, body)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
+
+func get(path string) func(url string) *http.Request {
+	return func(url string) *http.Request {
+		return newRequest(http.MethodGet, url+path, nil)
+	}
+}
+
+func withAccept(request func(url string) *http.Request, accept string) func(url string) *http.Request {
+	return func(url string) *http.Request {
+		req := request(url)
+		req.Header.Set("Accept", accept)
+		return req
+	}
+}
+
+// uploadRoute registers a route uploading the field `image`, under the
+// configuration of key-value pairs.
+func uploadRoute(config ...string) func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+	return func(c contracts.IServerContainer, cfg contracts.IConfigService) {
+		for i := 0; i+1 < len(config); i += 2 {
+			cfg.SetString(config[i], config[i+1])
+		}
+		c.Route(http.MethodPost, "/upload", func(server contracts.IServer) error {
+			id, err := server.Upload("image")
+			if err != nil {
+				return err
+			}
+			return server.String(http.StatusOK, id)
+		})
+	}
+}
+
+func withHeader(request func(url string) *http.Request, key, value string) func(url string) *http.Request {
+	return func(url string) *http.Request {
+		req := request(url)
+		req.Header.Set(key, value)
+		return req
+	}
+}
+
+func withToken(request func(url string) *http.Request, roles ...string) func(url string) *http.Request {
+	return func(url string) *http.Request {
+		req := request(url)
+		req.Header.Set("Authorization", "Bearer "+token(roles...))
+		return req
+	}
+}
+
+// token signs a token of the user 7 having roles.
+func token(roles ...string) string {
+	return userToken(7, roles...)
+}
+
+// userToken signs a token of the user id having roles.
+func userToken(id int, roles ...string) string {
+	claims := &models.UserClaims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		ID:    id,
+		Roles: roles,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+func upload(path, field, filename, contentType string, content []byte) func(url string) *http.Request {
+	return func(url string) *http.Request {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="`+filename+`"`)
+		header.Set("Content-Type", contentType)
+		part, err := w.CreatePart(header)
+		if err != nil {
+			panic(err)
+		}
+		_, _ = part.Write(content)
+		_ = w.Close()
+
+		req, err := http.NewRequest(http.MethodPost, url+path, &body)
+		if err != nil {
+			panic(err)
+		}
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		return req
+	}
+}
+
+func all(checks ...func(t *testing.T, res *http.Response, body string)) func(t *testing.T, res *http.Response, body string) {
+	return func(t *testing.T, res *http.Response, body string) {
+		for _, check := range checks {
+			check(t, res, body)
+		}
+	}
+}
+
+func expect(status int, want string) func(t *testing.T, res *http.Response, body string) {
+	return func(t *testing.T, res *http.Response, body string) {
+		if res.StatusCode != status {
+			t.Errorf("status = %d, want %d (body %q)", res.StatusCode, status, body)
+		}
+		if body != want {
+			t.Errorf("body = %q, want %q", body, want)
+		}
+	}
+}
+
+func expectJSON(status int, want interface{}) func(t *testing.T, res *http.Response, body string) {
+	return expect(status, string(mustJSON(want)))
+}
+
+// expectError checks the status of an error response in the format of
+// models.Error, ignoring the message which may vary by backend.
+func expectError(status int) func(t *testing.T, res *http.Response, body string) {
+	return func(t *testing.T, res *http.Response, body string) {
+		if res.StatusCode != status {
+			t.Errorf("status = %d, want %d (body %q)", res.StatusCode, status, body)
+		}
+		var e models.Error
+		if err := json.Unmarshal([]byte(body), &e); err != nil || e.Code != status || e.Message == "" {
+			t.Errorf("body = %q, want an error with code %d", body, status)
+		}
+	}
+}
+
+func expectHeader(key, want string) func(t *testing.T, res *http.Response, body string) {
+	return func(t *testing.T, res *http.Response, body string) {
+		if got := res.Header.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func expectContentType(want string) func(t *testing.T, res *http.Response, body string) {
+	return func(t *testing.T, res *http.Response, body string) {
+		if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, want) {
+			t.Errorf("Content-Type = %q, want %q", got, want)
+		}
+	}
+}
+
+func mustJSON(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return append(b, '\n')
+}
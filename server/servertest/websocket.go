@@ -0,0 +1,136 @@
+package servertest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/contracts"
+
+	"github.com/gorilla/websocket"
+)
+
+type message struct {
+	User int    `json:"user"`
+	Text string `json:"text"`
+}
+
+// testWebSocket checks IServer.Upgrade, whose requests the test cases of
+// TestContainer can't make.
+func testWebSocket(t *testing.T, newContainer Factory) {
+	cases := []struct {
+		name   string
+		url    string
+		header http.Header
+		status int
+	}{
+		{
+			name:   "WebSocket",
+			url:    "/ws",
+			header: http.Header{"Authorization": {"Bearer " + token("user")}},
+		},
+		{
+			name: "WebSocketQueryToken",
+			url:  "/ws?access_token=" + token("user"),
+			header: http.Header{
+				"Origin": {"https://app.example.com"},
+			},
+		},
+		{
+			name:   "WebSocketUnauthorized",
+			url:    "/ws",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "WebSocketOrigin",
+			url:  "/ws?access_token=" + token("user"),
+			header: http.Header{
+				"Origin": {"https://evil.example.com"},
+			},
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "servertest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			cfg := newConfig(t, dir)
+			cfg.SetString("server.cors.allow_origins", "https://app.example.com")
+			c := newContainer(cfg)
+			c.SecureRoutes(map[string][]string{"/ws": {"user"}})
+			c.Route(http.MethodGet, "/ws", echoSocket)
+
+			ts := c.TestServer()
+			defer ts.Close()
+
+			url := "ws" + strings.TrimPrefix(ts.URL, "http") + tc.url
+			conn, res, err := websocket.DefaultDialer.Dial(url, tc.header)
+			if tc.status != 0 {
+				if err == nil {
+					conn.Close()
+					t.Fatal("connected, want the handshake to fail")
+				}
+				if res == nil || res.StatusCode != tc.status {
+					t.Fatalf("handshake failed with %v, want status %d", err, tc.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			for _, text := range []string{"hello", "world"} {
+				if err := conn.WriteJSON(message{Text: text}); err != nil {
+					t.Fatal(err)
+				}
+				var got message
+				if err := conn.ReadJSON(&got); err != nil {
+					t.Fatal(err)
+				}
+				if want := (message{User: 7, Text: text}); got != want {
+					t.Errorf("message = %+v, want %+v", got, want)
+				}
+			}
+
+			// The server closes the connection once the handler returns.
+			if err := conn.WriteJSON(message{Text: "bye"}); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = conn.ReadMessage()
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("read %v, want a normal closure", err)
+			}
+		})
+	}
+}
+
+// echoSocket sends the messages of the client back, stamped with its user,
+// until it says bye.
+func echoSocket(server contracts.IServer) error {
+	socket, err := server.Upgrade()
+	if err != nil {
+		return err
+	}
+	for {
+		var m message
+		if err := socket.ReadJSON(&m); err != nil {
+			return err
+		}
+		if m.Text == "bye" {
+			return nil
+		}
+		m.User = server.User().ID
+		if err := socket.WriteJSON(m); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+const defaultHeartbeat = 15 * time.Second
+
+var errStreamingUnsupported = errors.New("response writer doesn't support streaming")
+
+// takeover is the state of a handler which takes over its connection for a
+// stream or a WebSocket, so nothing is written after it.
+type takeover struct {
+	// closing is done when the server shuts down
+	closing  context.Context
+	detached bool
+	socket   *webSocket
+}
+
+// onShutdown returns a context which is done once server shuts down, which
+// ends the streams and WebSockets it doesn't wait for.
+func onShutdown(server *http.Server) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	server.RegisterOnShutdown(cancel)
+	return ctx
+}
+
+// release closes the WebSocket of a handler which has returned
+func (t *takeover) release() {
+	if t.socket != nil {
+		_ = t.socket.Close()
+	}
+}
+
+func (t *takeover) stream(
+	w http.ResponseWriter,
+	r *http.Request,
+	config contracts.IConfigService,
+	handler func(stream contracts.IEventStream) error,
+) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errStreamingUnsupported
+	}
+	t.detached = true
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// Stop nginx from buffering the stream.
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-t.closing.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	s := &eventStream{
+		w:           w,
+		flusher:     flusher,
+		ctx:         ctx,
+		cancel:      cancel,
+		lastEventID: r.Header.Get("Last-Event-ID"),
+	}
+
+	heartbeat := time.Duration(config.Int("server.stream.heartbeat")) * time.Second
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = s.write(": ping\n\n")
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err := handler(s)
+	// The response can't be written once the handler returns.
+	cancel()
+	<-stopped
+	return err
+}
+
+type eventStream struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	ctx         context.Context
+	cancel      context.CancelFunc
+	lastEventID string
+}
+
+// Send implements IEventStream.Send
+func (s *eventStream) Send(event contracts.Event) error {
+	if strings.ContainsAny(event.ID, "\r\n") || strings.ContainsAny(event.Event, "\r\n") {
+		return errors.New("event id and type can't contain line breaks")
+	}
+
+	var data string
+	switch v := event.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+
+	var b strings.Builder
+	if event.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", event.ID)
+	}
+	if event.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event.Event)
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	data = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// write writes to the stream unless the client has disconnected, which
+// ends the stream if writing fails.
+func (s *eventStream) write(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write([]byte(text)); err != nil {
+		s.cancel()
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// LastEventID implements IEventStream.LastEventID
+func (s *eventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done implements IEventStream.Done
+func (s *eventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// changes. Checks happen during TLS handshakes, so an idle server doesn't
+// touch the files at all.
+const certCheckInterval = 2 * time.Second
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// newTLSConfig builds the TLS configuration from the `server.tls.*`
+// configuration parameters. It returns nil if no certificate is configured.
+//
+// The certificate, key and client CA files are reloaded when they change, so
+// renewed certificates are picked up without a restart.
+func newTLSConfig(config contracts.IConfigService, logger contracts.ILogger) (*tls.Config, error) {
+	certFile := config.String("server.tls.cert_file")
+	keyFile := config.String("server.tls.key_file")
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if name := config.String("server.tls.min_version"); name != "" {
+		version, ok := tlsVersions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls version %q", name)
+		}
+		base.MinVersion = version
+	}
+
+	if names := config.String("server.tls.cipher_suites"); names != "" {
+		suites, err := parseCipherSuites(names)
+		if err != nil {
+			return nil, err
+		}
+		base.CipherSuites = suites
+	}
+
+	caFile := config.String("server.tls.client_ca_file")
+	if caFile != "" {
+		// Internal callers present certificates, the others don't.
+		base.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if name := config.String("server.tls.client_auth"); name != "" {
+		clientAuth, ok := clientAuthTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls client auth %q", name)
+		}
+		base.ClientAuth = clientAuth
+	}
+
+	reloader := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		logger:   logger,
+	}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		NextProtos: base.NextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := reloader.get()
+			return cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := reloader.get()
+			c := base.Clone()
+			c.Certificates = []tls.Certificate{*cert}
+			c.ClientCAs = clientCAs
+			return c, nil
+		},
+	}, nil
+}
+
+func parseCipherSuites(names string) ([]uint16, error) {
+	ids := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := ids[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+// certReloader keeps the latest certificate and client CAs read from disk.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	logger   contracts.ILogger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	digests   [][sha256.Size]byte
+	checkedAt time.Time
+}
+
+// get returns the current certificate and client CAs, reloading them first
+// if the files have changed since the last check.
+func (r *certReloader) get() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	due := time.Since(r.checkedAt) > certCheckInterval
+	r.mu.RUnlock()
+
+	if due {
+		if err := r.reload(); err != nil {
+			// Keep serving the previous certificate.
+			r.logger.WithFields(contracts.LogFields{
+				"cert_file": r.certFile,
+				"error":     err.Error(),
+			}).Error("cannot reload tls certificate")
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.clientCAs
+}
+
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkedAt = time.Now()
+
+	// The contents are compared, rather than the modification times, so a
+	// file replaced by an older one, e.g. rolled back or copied keeping its
+	// time, is reloaded too.
+	digests, err := r.fileDigests()
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && equalDigests(digests, r.digests) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		pem, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", r.caFile)
+		}
+	}
+
+	if r.cert != nil {
+		r.logger.WithFields(contracts.LogFields{
+			"cert_file": r.certFile,
+		}).Info("tls certificate reloaded")
+	}
+
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.digests = digests
+	return nil
+}
+
+func (r *certReloader) fileDigests() ([][sha256.Size]byte, error) {
+	var digests [][sha256.Size]byte
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, sha256.Sum256(content))
+	}
+	return digests, nil
+}
+
+func equalDigests(a, b [][sha256.Size]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/logger"
+)
+
+// writeCert writes a self-signed certificate of name and its key, dated
+// modTime.
+func writeCert(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	}
+	for file, block := range files {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	now := time.Now().Truncate(time.Second)
+
+	cases := []struct {
+		name    string
+		modTime time.Time
+	}{
+		{name: "first", modTime: now},
+		{name: "newer", modTime: now.Add(time.Minute)},
+		// Rolled back to a certificate dated before the current one.
+		{name: "older", modTime: now.Add(-time.Hour)},
+		// Copied keeping the time of the current one.
+		{name: "same-time", modTime: now.Add(-time.Hour)},
+	}
+	reloader := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger.NewLogger(config.NewConfigService()),
+	}
+	for _, tc := range cases {
+		writeCert(t, certFile, keyFile, tc.name, tc.modTime)
+		reloader.checkedAt = time.Time{}
+		cert, _ := reloader.get()
+		if cert == nil {
+			t.Fatalf("%s: no certificate loaded", tc.name)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if leaf.Subject.CommonName != tc.name {
+			t.Errorf("certificate = %s, want %s", leaf.Subject.CommonName, tc.name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"sync"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+// uploader stores the files uploaded by IServer.Upload, once validated and
+// processed.
+type uploader struct {
+	config  contracts.IConfigService
+	storage contracts.IStorage
+
+	mu         sync.RWMutex
+	processors []contracts.IUploadProcessor
+
+	// Resumable uploads are locked by the hash of their ID, and the expired
+	// ones are swept once any are registered.
+	locks     [64]sync.Mutex
+	sweepOnce sync.Once
+}
+
+func newUploader(config contracts.IConfigService, storage contracts.IStorage) *uploader {
+	return &uploader{config: config, storage: storage}
+}
+
+func (u *uploader) register(processors ...contracts.IUploadProcessor) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.processors = append(u.processors, processors...)
+}
+
+// upload stores a file uploaded in field by user, who may be nil, returning
+// its ID.
+func (u *uploader) upload(ctx context.Context, field string, h *multipart.FileHeader, user *models.UserClaims) (string, error) {
+	content, contentType, err := utils.ValidateUpload(u.config, field, h)
+	if err != nil {
+		return "", err
+	}
+
+	uploaderID := 0
+	if user != nil {
+		uploaderID = user.ID
+	}
+	file, err := u.store(ctx, field, h.Filename, content, contentType, uploaderID)
+	if err != nil {
+		return "", err
+	}
+	return file.ID, nil
+}
+
+// delete deletes a file uploaded by uploaderID, running the delete
+// processors once its content is deleted.
+func (u *uploader) delete(ctx context.Context, id string, uploaderID int) error {
+	deleted, err := utils.DeleteFile(ctx, u.storage, id, uploaderID)
+	if err != nil || !deleted {
+		return err
+	}
+
+	u.mu.RLock()
+	processors := u.processors
+	u.mu.RUnlock()
+	for _, processor := range processors {
+		if deleter, ok := processor.(contracts.IDeleteProcessor); ok {
+			if err := deleter.Deleted(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// store processes and stores the validated content of a file uploaded in
+// field.
+func (u *uploader) store(ctx context.Context, field, name string, content []byte, contentType string, uploaderID int) (*models.File, error) {
+	u.mu.RLock()
+	processors := u.processors
+	u.mu.RUnlock()
+
+	var err error
+	for _, processor := range processors {
+		content, contentType, err = processor.Process(ctx, field, content, contentType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := utils.StoreFile(ctx, u.storage, name, bytes.NewReader(content), contentType, uploaderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, processor := range processors {
+		if err := processor.Uploaded(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
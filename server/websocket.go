@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+
+	"github.com/gorilla/websocket"
+)
+
+// Defaults of `server.websocket.ping_interval`, in seconds, and
+// `server.websocket.read_limit`, in bytes.
+const (
+	defaultPingInterval = 30 * time.Second
+	defaultReadLimit    = 1 << 20
+)
+
+// websocketTokenMiddleware lets WebSocket requests send their token in the
+// `access_token` parameter, since browsers can't set their headers, by
+// moving it to the `Authorization` header.
+func websocketTokenMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if websocket.IsWebSocketUpgrade(r) && r.Header.Get("Authorization") == "" {
+				if token := r.URL.Query().Get("access_token"); token != "" {
+					r.Header.Set("Authorization", "Bearer "+token)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (t *takeover) upgrade(
+	w http.ResponseWriter,
+	r *http.Request,
+	config contracts.IConfigService,
+) (contracts.IWebSocket, error) {
+	cors := newCORSPolicy(config)
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			// Only browsers send the origin, which is checked to stop other
+			// sites from connecting with the cookies of their users.
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			_, ok := cors.allowOrigin(origin)
+			return ok
+		},
+	}
+
+	// The upgrader responds to failed upgrades itself.
+	t.detached = true
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(config.Int("server.websocket.ping_interval")) * time.Second
+	if interval <= 0 {
+		interval = defaultPingInterval
+	}
+	readLimit := int64(config.Int("server.websocket.read_limit"))
+	if readLimit <= 0 {
+		readLimit = defaultReadLimit
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.socket = &webSocket{conn: conn, ctx: ctx, cancel: cancel}
+
+	// Clients which don't answer pings are gone.
+	conn.SetReadLimit(readLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * interval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * interval))
+	})
+
+	go t.socket.keepAlive(interval, t.closing)
+	return t.socket, nil
+}
+
+type webSocket struct {
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// keepAlive pings the client every interval until the connection is closed,
+// and closes it when the server shuts down.
+func (s *webSocket) keepAlive(interval time.Duration, closing context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval))
+			if err != nil {
+				_ = s.Close()
+				return
+			}
+		case <-closing.Done():
+			s.closeWith(websocket.CloseGoingAway, "server is shutting down")
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// ReadMessage implements IWebSocket.ReadMessage
+func (s *webSocket) ReadMessage() (int, []byte, error) {
+	messageType, data, err := s.conn.ReadMessage()
+	if err != nil {
+		_ = s.Close()
+	}
+	return messageType, data, err
+}
+
+// ReadJSON implements IWebSocket.ReadJSON
+func (s *webSocket) ReadJSON(v interface{}) error {
+	_, data, err := s.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage implements IWebSocket.WriteMessage
+func (s *webSocket) WriteMessage(messageType int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.WriteMessage(messageType, data)
+}
+
+// WriteJSON implements IWebSocket.WriteJSON
+func (s *webSocket) WriteJSON(v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.WriteJSON(v)
+}
+
+// Close implements IWebSocket.Close
+func (s *webSocket) Close() error {
+	s.closeWith(websocket.CloseNormalClosure, "")
+	return nil
+}
+
+// closeWith sends a close message, which is allowed to fail if the client
+// is gone, and closes the connection.
+func (s *webSocket) closeWith(code int, reason string) {
+	s.once.Do(func() {
+		message := websocket.FormatCloseMessage(code, reason)
+		_ = s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		_ = s.conn.Close()
+		s.cancel()
+	})
+}
+
+// Done implements IWebSocket.Done
+func (s *webSocket) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
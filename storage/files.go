@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+)
+
+// UserFilesPath is the path of the route serving the files of signed URLs
+// bound to a user, which requires the user to be logged in.
+const UserFilesPath = "/v1/user-files/"
+
+// SignURL returns a URL of the files route downloading the file stored under
+// key until expiry. Unless userID is zero, the URL is bound to the user, who
+// must be logged in to download it.
+func SignURL(config contracts.IConfigService, key string, expiry time.Duration, userID int) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	secret := Secret(config)
+	if len(secret) == 0 {
+		return "", fmt.Errorf("storage secret is not configured")
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	filesPath := FilesPath
+	if userID != 0 {
+		query.Set("user", strconv.Itoa(userID))
+		filesPath = UserFilesPath
+	}
+	query.Set("signature", sign(secret, key, expires, query.Get("user")))
+	return config.BaseURL() + filesPath + escapeKey(key) + "?" + query.Encode(), nil
+}
+
+// VerifyURL checks the signature of a URL of key by its query. It returns
+// ErrURLExpired once it's expired, and ErrInvalidSignature if it's forged,
+// or bound to another user than userID.
+func VerifyURL(config contracts.IConfigService, key string, query url.Values, userID int) error {
+	secret := Secret(config)
+	expires := query.Get("expires")
+	user := query.Get("user")
+	want := sign(secret, key, expires, user)
+	if len(secret) == 0 || !hmac.Equal([]byte(query.Get("signature")), []byte(want)) {
+		return contracts.ErrInvalidSignature
+	}
+	if user != "" && user != strconv.Itoa(userID) {
+		return contracts.ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return contracts.ErrInvalidSignature
+	}
+	if time.Now().Unix() > unix {
+		return contracts.ErrURLExpired
+	}
+	return nil
+}
+
+// Register registers the routes downloading the files of container.Storage
+// by signed URLs: FilesPath, and UserFilesPath for the URLs bound to a user.
+// They support range requests, e.g. for resuming downloads and seeking
+// videos, reading only the ranges of the storages implementing
+// IRangeStorage, or the files whose readers seek.
+func Register(config contracts.IConfigService, container contracts.IServerContainer) {
+	h := &filesHandler{config: config, storage: container.Storage()}
+	files := func(server contracts.IServer) error {
+		return h.serve(server, FilesPath, 0)
+	}
+	userFiles := func(server contracts.IServer) error {
+		return h.serve(server, UserFilesPath, server.User().ID)
+	}
+
+	container.Route(http.MethodGet, FilesPath+"*", files, contracts.Named("files"), contracts.WithTags("files"))
+	container.Route(http.MethodHead, FilesPath+"*", files, contracts.Hidden())
+	container.Route(http.MethodGet, UserFilesPath+"*", userFiles, contracts.Named("user-files"),
+		contracts.WithTags("files"), contracts.WithRoles())
+	container.Route(http.MethodHead, UserFilesPath+"*", userFiles, contracts.WithRoles(), contracts.Hidden())
+}
+
+type filesHandler struct {
+	config  contracts.IConfigService
+	storage contracts.IStorage
+}
+
+// serve sends the file of a signed URL under prefix, if it's bound to
+// userID or to no user.
+func (h *filesHandler) serve(server contracts.IServer, prefix string, userID int) error {
+	req := server.Request()
+
+	// The key is taken from the decoded path, rather than the parameter
+	// which the backends decode differently, so encoded dots and slashes
+	// are checked too.
+	key, err := CleanKey(strings.TrimPrefix(req.URL.Path, prefix))
+	if err != nil {
+		return filesError(server, http.StatusBadRequest, err)
+	}
+	query := req.URL.Query()
+	if userID == 0 && query.Get("user") != "" {
+		return filesError(server, http.StatusForbidden, contracts.ErrInvalidSignature)
+	}
+	if err := VerifyURL(h.config, key, query, userID); err != nil {
+		return filesError(server, http.StatusForbidden, err)
+	}
+
+	// Ranges need seeking, which the files of IRangeStorage do by reading
+	// from the offsets seeked to, and the others if their readers can.
+	var r io.ReadCloser
+	var info *contracts.FileInfo
+	if ranges, ok := h.storage.(contracts.IRangeStorage); ok {
+		info, err = h.storage.Stat(req.Context(), key)
+		if err == nil {
+			r = &rangeReader{ctx: req.Context(), storage: ranges, key: key, size: info.Size}
+		}
+	} else {
+		r, info, err = h.storage.Get(req.Context(), key)
+	}
+	if err == contracts.ErrFileNotFound {
+		return filesError(server, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	server.SetHeader("Content-Type", info.ContentType)
+	server.SetHeader("Cache-Control", "private, no-transform")
+	server.SetHeader("X-Content-Type-Options", "nosniff")
+	// Files such as SVG images are documents, which mustn't run scripts nor
+	// load anything when opened.
+	server.SetHeader("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	if info.ETag != "" {
+		server.SetHeader("ETag", `"`+info.ETag+`"`)
+	}
+	if content, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(server.ResponseWriter(), req, path.Base(key), info.ModTime, content)
+		return nil
+	}
+
+	// Otherwise the whole file is sent, ignoring ranges.
+	server.SetHeader("Content-Length", strconv.FormatInt(info.Size, 10))
+	if !info.ModTime.IsZero() {
+		server.SetHeader("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	server.ResponseWriter().WriteHeader(http.StatusOK)
+	if req.Method != http.MethodHead {
+		_, err = io.Copy(server.ResponseWriter(), r)
+	}
+	return err
+}
+
+// rangeReader reads a file of an IRangeStorage from the offset it's seeked
+// to, so http.ServeContent reads only the ranges requested.
+type rangeReader struct {
+	ctx     context.Context
+	storage contracts.IRangeStorage
+	key     string
+	size    int64
+	offset  int64
+	r       io.ReadCloser
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.r == nil {
+		rc, err := r.storage.GetRange(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.r = rc
+	}
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek to a negative offset")
+	}
+	if offset != r.offset {
+		_ = r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+func (r *rangeReader) Close() error {
+	if r.r == nil {
+		return nil
+	}
+	err := r.r.Close()
+	r.r = nil
+	return err
+}
+
+func filesError(server contracts.IServer, status int, err error) error {
+	return server.JSON(status, &models.Error{
+		Message: err.Error(),
+		Code:    status,
+	})
+}
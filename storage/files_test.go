@@ -0,0 +1,271 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/logger"
+	"github.com/mostafasolati/leviathan/models"
+	"github.com/mostafasolati/leviathan/pagination"
+	"github.com/mostafasolati/leviathan/ratelimit"
+	services "github.com/mostafasolati/leviathan/server"
+	"github.com/mostafasolati/leviathan/storage"
+	"github.com/mostafasolati/leviathan/storage/storagetest"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// unseekable hides the seeking of the readers of a storage.
+type unseekable struct {
+	contracts.IStorage
+}
+
+func (s unseekable) Get(ctx context.Context, key string) (io.ReadCloser, *contracts.FileInfo, error) {
+	r, info, err := s.IStorage.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ioutil.NopCloser(r), info, nil
+}
+
+func TestFiles(t *testing.T) {
+	cfg := config.NewConfigService()
+	cfg.SetString("auth.jwt.secret", "secret")
+	s := storage.NewMemoryStorage(cfg)
+	if err := s.Put(context.Background(), "private/report 1.txt", strings.NewReader("report content"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	l := logger.NewLogger(cfg)
+	c := services.NewHTTPServerContainer(cfg, l, ratelimit.NewRateLimiter(cfg, l, ratelimit.NewMemoryStore()), s)
+	storage.Register(cfg, c)
+	ts := c.TestServer()
+	defer ts.Close()
+
+	signed := func(key string, expiry time.Duration, userID int) string {
+		signed, err := storage.SignURL(cfg, key, expiry, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	// token signs a token of the user id.
+	token := func(id int) string {
+		claims := &models.UserClaims{
+			StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()},
+			ID:             id,
+			Roles:          []string{"user"},
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	forged := strings.Replace(signed("private/report 1.txt", time.Minute, 0), "report%201.txt", "other.txt", 1)
+
+	cases := []struct {
+		name   string
+		url    string
+		ranges string
+		user   int
+		status int
+		want   string
+		header map[string]string
+	}{
+		{
+			name: "Signed", url: signed("private/report 1.txt", time.Minute, 0), status: http.StatusOK, want: "report content",
+			header: map[string]string{"Content-Type": "text/plain", "Accept-Ranges": "bytes"},
+		},
+		{
+			name: "Range", url: signed("private/report 1.txt", time.Minute, 0), ranges: "bytes=7-",
+			status: http.StatusPartialContent, want: "content", header: map[string]string{"Content-Range": "bytes 7-13/14"},
+		},
+		{name: "Expired", url: signed("private/report 1.txt", -time.Minute, 0), status: http.StatusForbidden},
+		{name: "Forged", url: forged, status: http.StatusForbidden},
+		{name: "Traversal", url: "/v1/files/private/..%2f..%2fsecret.txt", status: http.StatusBadRequest},
+		{name: "NotFound", url: signed("private/missing.txt", time.Minute, 0), status: http.StatusNotFound},
+		{name: "User", url: signed("private/report 1.txt", time.Minute, 7), user: 7, status: http.StatusOK, want: "report content"},
+		{name: "OtherUser", url: signed("private/report 1.txt", time.Minute, 8), user: 7, status: http.StatusForbidden},
+		{name: "Anonymous", url: signed("private/report 1.txt", time.Minute, 7), status: http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tc.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.ranges != "" {
+				req.Header.Set("Range", tc.ranges)
+			}
+			if tc.user != 0 {
+				req.Header.Set("Authorization", "Bearer "+token(tc.user))
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tc.status {
+				t.Fatalf("GET = %d %q, want %d", res.StatusCode, body, tc.status)
+			}
+			if tc.status < http.StatusBadRequest && string(body) != tc.want {
+				t.Errorf("GET = %q, want %q", body, tc.want)
+			}
+			for key, want := range tc.header {
+				if got := res.Header.Get(key); !strings.HasPrefix(got, want) {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilesRange(t *testing.T) {
+	server := storagetest.NewS3Server("access")
+	defer server.Close()
+	s3, err := storage.NewS3Storage(storage.S3Options{
+		Endpoint:  server.URL,
+		Bucket:    "test",
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		storage contracts.IStorage
+		ranges  string
+		status  int
+		want    string
+	}{
+		{name: "S3", storage: s3, status: http.StatusOK, want: "0123456789"},
+		{name: "S3Range", storage: s3, ranges: "bytes=2-4", status: http.StatusPartialContent, want: "234"},
+		{name: "S3Suffix", storage: s3, ranges: "bytes=-3", status: http.StatusPartialContent, want: "789"},
+		{name: "Memory", storage: storage.NewMemoryStorage(config.NewConfigService()), ranges: "bytes=2-4",
+			status: http.StatusPartialContent, want: "234"},
+		{name: "Unseekable", storage: unseekable{storage.NewMemoryStorage(config.NewConfigService())}, ranges: "bytes=2-4",
+			status: http.StatusOK, want: "0123456789"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			cfg.SetString("auth.jwt.secret", "secret")
+			l := logger.NewLogger(cfg)
+			c := services.NewHTTPServerContainer(cfg, l, ratelimit.NewRateLimiter(cfg, l, ratelimit.NewMemoryStore()), tc.storage)
+			storage.Register(cfg, c)
+			ts := c.TestServer()
+			defer ts.Close()
+
+			if err := tc.storage.Put(context.Background(), "videos/clip.mp4", strings.NewReader("0123456789"), "video/mp4"); err != nil {
+				t.Fatal(err)
+			}
+			signed, err := storage.SignURL(cfg, "videos/clip.mp4", time.Minute, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+u.RequestURI(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.ranges != "" {
+				req.Header.Set("Range", tc.ranges)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tc.status || string(body) != tc.want {
+				t.Errorf("GET = %d %q, want %d %q", res.StatusCode, body, tc.status, tc.want)
+			}
+			if csp := res.Header.Get("Content-Security-Policy"); !strings.HasPrefix(csp, "default-src 'none'") {
+				t.Errorf("Content-Security-Policy = %q, want default-src 'none'", csp)
+			}
+		})
+	}
+}
+
+func TestVerifyURL(t *testing.T) {
+	cfg := config.NewConfigService()
+	cfg.SetString("auth.jwt.secret", "secret")
+	signed, err := storage.SignURL(cfg, "private/report.pdf", time.Minute, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+
+	// The user-bound signature must not pass for other fields which join
+	// into the same text.
+	forged := url.Values{}
+	forged.Set("expires", query.Get("expires")+"\n7")
+	forged.Set("signature", query.Get("signature"))
+
+	cases := []struct {
+		name   string
+		key    string
+		query  url.Values
+		userID int
+		want   error
+	}{
+		{name: "Valid", key: "private/report.pdf", query: query, userID: 7},
+		{name: "OtherUser", key: "private/report.pdf", query: query, userID: 8, want: contracts.ErrInvalidSignature},
+		{name: "OtherKey", key: "private/other.pdf", query: query, userID: 7, want: contracts.ErrInvalidSignature},
+		{name: "FieldsShifted", key: "private/report.pdf", query: forged, want: contracts.ErrInvalidSignature},
+	}
+	for _, tc := range cases {
+		if err := storage.VerifyURL(cfg, tc.key, tc.query, tc.userID); err != tc.want {
+			t.Errorf("%s: VerifyURL() = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	cfg := config.NewConfigService()
+	if secret := storage.Secret(cfg); len(secret) != 0 {
+		t.Errorf("Secret = %x, want none without secrets", secret)
+	}
+
+	cfg.SetString("auth.jwt.secret", "jwt")
+	derived := storage.Secret(cfg)
+	if len(derived) == 0 || string(derived) == "jwt" {
+		t.Errorf("Secret = %x, want a key derived from the JWT secret", derived)
+	}
+	if cursors := pagination.Secret(cfg); string(cursors) == string(derived) {
+		t.Errorf("Secret = %x, want another key than cursors", derived)
+	}
+
+	cfg.SetString("storage.secret", "downloads")
+	if secret := storage.Secret(cfg); string(secret) != "downloads" {
+		t.Errorf("Secret = %q, want the configured one", secret)
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+type localStorage struct {
+	config contracts.IConfigService
+	root   string
+}
+
+// NewLocalStorage creates an IStorage which keeps files under the root
+// directory, e.g. `original/photo.png` at `<root>/original/photo.png`.
+// Signed URLs are served by the files route.
+func NewLocalStorage(config contracts.IConfigService, root string) contracts.IStorage {
+	return &localStorage{config: config, root: root}
+}
+
+// path returns the path of the file of key.
+func (s *localStorage) path(key string) (string, string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return key, filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put implements IStorage.Put
+func (s *localStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	_, path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// The file is written aside and renamed, so readers never see it
+	// partially written.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get implements IStorage.Get
+func (s *localStorage) Get(ctx context.Context, key string) (io.ReadCloser, *contracts.FileInfo, error) {
+	key, path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, contracts.ErrFileNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if stat.IsDir() {
+		f.Close()
+		return nil, nil, contracts.ErrFileNotFound
+	}
+	return f, fileInfo(key, stat), nil
+}
+
+// Delete implements IStorage.Delete
+func (s *localStorage) Delete(ctx context.Context, key string) error {
+	_, path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Stat implements IStorage.Stat
+func (s *localStorage) Stat(ctx context.Context, key string) (*contracts.FileInfo, error) {
+	key, path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if os.IsNotExist(err) || err == nil && stat.IsDir() {
+		return nil, contracts.ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo(key, stat), nil
+}
+
+// List implements IStorage.List
+func (s *localStorage) List(ctx context.Context, prefix string) ([]contracts.FileInfo, error) {
+	// Only the directory which the prefix falls in is walked.
+	dir := s.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		_, path, err := s.path(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		dir = path
+	}
+
+	files := []contracts.FileInfo{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			files = append(files, *fileInfo(key, info))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Key < files[j].Key
+	})
+	return files, nil
+}
+
+// SignedURL implements IStorage.SignedURL
+func (s *localStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return signedURL(s.config, key, expiry)
+}
+
+func fileInfo(key string, stat os.FileInfo) *contracts.FileInfo {
+	return &contracts.FileInfo{
+		Key:         key,
+		Size:        stat.Size(),
+		ContentType: typeByKey(key),
+		ModTime:     stat.ModTime(),
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+type memoryFile struct {
+	data []byte
+	info contracts.FileInfo
+}
+
+type memoryStorage struct {
+	config contracts.IConfigService
+
+	mu    sync.RWMutex
+	files map[string]*memoryFile
+}
+
+// NewMemoryStorage creates an IStorage which keeps files in memory, e.g.
+// for tests. Signed URLs are served by the files route.
+func NewMemoryStorage(config contracts.IConfigService) contracts.IStorage {
+	return &memoryStorage{
+		config: config,
+		files:  make(map[string]*memoryFile),
+	}
+}
+
+// Put implements IStorage.Put
+func (s *memoryStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = typeByKey(key)
+	}
+
+	sum := md5.Sum(data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = &memoryFile{
+		data: data,
+		info: contracts.FileInfo{
+			Key:         key,
+			Size:        int64(len(data)),
+			ContentType: contentType,
+			ModTime:     time.Now(),
+			ETag:        hex.EncodeToString(sum[:]),
+		},
+	}
+	return nil
+}
+
+// Get implements IStorage.Get
+func (s *memoryStorage) Get(ctx context.Context, key string) (io.ReadCloser, *contracts.FileInfo, error) {
+	f, err := s.file(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	info := f.info
+	return memoryReader{bytes.NewReader(f.data)}, &info, nil
+}
+
+// memoryReader reads a file in memory, seeking for range requests.
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error {
+	return nil
+}
+
+// Delete implements IStorage.Delete
+func (s *memoryStorage) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, key)
+	return nil
+}
+
+// Stat implements IStorage.Stat
+func (s *memoryStorage) Stat(ctx context.Context, key string) (*contracts.FileInfo, error) {
+	f, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	info := f.info
+	return &info, nil
+}
+
+// List implements IStorage.List
+func (s *memoryStorage) List(ctx context.Context, prefix string) ([]contracts.FileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	files := []contracts.FileInfo{}
+	for key, f := range s.files {
+		if strings.HasPrefix(key, prefix) {
+			files = append(files, f.info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Key < files[j].Key
+	})
+	return files, nil
+}
+
+// SignedURL implements IStorage.SignedURL
+func (s *memoryStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return signedURL(s.config, key, expiry)
+}
+
+func (s *memoryStorage) file(key string) (*memoryFile, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.files[key]
+	if !ok {
+		return nil, contracts.ErrFileNotFound
+	}
+	return f, nil
+}
@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// S3Options configure an S3-compatible storage.
+type S3Options struct {
+	// Endpoint is the URL of the store, e.g. `https://s3.amazonaws.com` or
+	// `http://localhost:9000` for MinIO. The scheme defaults to https.
+	Endpoint string
+
+	// Region defaults to us-east-1, which MinIO uses by default.
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+
+	// PathStyle addresses the bucket in the path, e.g.
+	// `http://localhost:9000/bucket/key`, instead of the host.
+	PathStyle bool
+
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type s3Storage struct {
+	endpoint  *url.URL
+	bucket    string
+	pathStyle bool
+	signer    *signer
+	client    *http.Client
+}
+
+// NewS3Storage creates an IStorage which keeps files as the objects of a
+// bucket of S3 or any compatible store, e.g. MinIO. Requests are signed by
+// Signature Version 4, and signed URLs are presigned by the store itself.
+func NewS3Storage(options S3Options) (contracts.IStorage, error) {
+	if options.Endpoint == "" || options.Bucket == "" {
+		return nil, errors.New("s3 storage needs an endpoint and a bucket")
+	}
+	if !strings.Contains(options.Endpoint, "://") {
+		options.Endpoint = "https://" + options.Endpoint
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(options.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	return &s3Storage{
+		endpoint:  endpoint,
+		bucket:    options.Bucket,
+		pathStyle: options.PathStyle,
+		signer: &signer{
+			accessKey: options.AccessKey,
+			secretKey: options.SecretKey,
+			region:    options.Region,
+		},
+		client: options.Client,
+	}, nil
+}
+
+// url returns the URL of an object, or of the bucket if key is empty.
+func (s *s3Storage) url(key string) *url.URL {
+	u := *s.endpoint
+	path := "/" + key
+	if s.pathStyle {
+		path = "/" + s.bucket + path
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = u.Path + path
+	u.RawPath = escapePath(u.Path)
+	return &u
+}
+
+func (s *s3Storage) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = u
+	req.ContentLength = size
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	s.signer.sign(req, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound && method != http.MethodDelete {
+		res.Body.Close()
+		return nil, contracts.ErrFileNotFound
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, s3Error(res)
+	}
+	return res, nil
+}
+
+// Put implements IStorage.Put
+func (s *s3Storage) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	// S3 needs the size of the object in advance.
+	size := int64(-1)
+	if seeker, ok := r.(io.Seeker); ok {
+		current, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			if end, err := seeker.Seek(0, io.SeekEnd); err == nil {
+				size = end - current
+			}
+			if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	}
+	if size < 0 {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	if contentType == "" {
+		contentType = typeByKey(key)
+	}
+	header := http.Header{"Content-Type": {contentType}}
+	res, err := s.do(ctx, http.MethodPut, s.url(key), r, size, header)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Get implements IStorage.Get
+func (s *s3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *contracts.FileInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := s.do(ctx, http.MethodGet, s.url(key), nil, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Body, objectInfo(key, res), nil
+}
+
+// GetRange implements IRangeStorage.GetRange
+func (s *s3Storage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	if length < 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	}
+	res, err := s.do(ctx, http.MethodGet, s.url(key), nil, 0, header)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusPartialContent {
+		return res.Body, nil
+	}
+
+	// The store ignored the range and sends the whole object.
+	if _, err := io.CopyN(ioutil.Discard, res.Body, offset); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return res.Body, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(res.Body, length), res.Body}, nil
+}
+
+// Delete implements IStorage.Delete
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodDelete, s.url(key), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Stat implements IStorage.Stat
+func (s *s3Storage) Stat(ctx context.Context, key string) (*contracts.FileInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.do(ctx, http.MethodHead, s.url(key), nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	return objectInfo(key, res), nil
+}
+
+// List implements IStorage.List
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]contracts.FileInfo, error) {
+	files := []contracts.FileInfo{}
+	token := ""
+	for {
+		u := s.url("")
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = canonicalQuery(query)
+
+		res, err := s.do(ctx, http.MethodGet, u, nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			files = append(files, contracts.FileInfo{
+				Key:         object.Key,
+				Size:        object.Size,
+				ContentType: typeByKey(object.Key),
+				ModTime:     object.LastModified,
+				ETag:        strings.Trim(object.ETag, `"`),
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// SignedURL implements IStorage.SignedURL
+func (s *s3Storage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(key).String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.URL = s.url(key)
+	return s.signer.presign(req, time.Now(), expiry), nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func objectInfo(key string, res *http.Response) *contracts.FileInfo {
+	info := &contracts.FileInfo{
+		Key:         key,
+		ContentType: res.Header.Get("Content-Type"),
+		ETag:        strings.Trim(res.Header.Get("ETag"), `"`),
+	}
+	info.Size, _ = strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	info.ModTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	return info
+}
+
+// s3Error reads the error of a failed request.
+func s3Error(res *http.Response) error {
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&e); err != nil || e.Code == "" {
+		return fmt.Errorf("s3: %s", res.Status)
+	}
+	return fmt.Errorf("s3: %s: %s", e.Code, e.Message)
+}
+
+// escapePath escapes a path like Signature Version 4 expects, i.e.
+// everything but unreserved characters and slashes.
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
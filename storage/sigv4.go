@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature Version 4 of AWS, which S3-compatible stores authenticate
+// requests by.
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateFormat   = "20060102T150405Z"
+)
+
+type signer struct {
+	accessKey string
+	secretKey string
+	region    string
+}
+
+// sign signs a request in its `Authorization` header. The payload isn't
+// signed, so it can be streamed.
+func (s *signer) sign(req *http.Request, now time.Time) {
+	date := now.UTC().Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonical := s.canonicalRequest(req, req.URL.Query(), headers, unsignedPayload)
+	signature := s.signature(canonical, date)
+
+	req.Header.Set("Authorization", sigV4Algorithm+
+		" Credential="+s.accessKey+"/"+s.scope(date)+
+		", SignedHeaders="+strings.Join(headers, ";")+
+		", Signature="+signature)
+}
+
+// presign returns the URL of a request signed in its query, which is valid
+// for expiry.
+func (s *signer) presign(req *http.Request, now time.Time, expiry time.Duration) string {
+	date := now.UTC().Format(amzDateFormat)
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(date))
+	query.Set("X-Amz-Date", date)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonical := s.canonicalRequest(req, query, []string{"host"}, unsignedPayload)
+	query.Set("X-Amz-Signature", s.signature(canonical, date))
+
+	u := *req.URL
+	u.RawQuery = canonicalQuery(query)
+	return u.String()
+}
+
+func (s *signer) scope(date string) string {
+	return date[:8] + "/" + s.region + "/s3/aws4_request"
+}
+
+func (s *signer) canonicalRequest(req *http.Request, query url.Values, headers []string, payload string) string {
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	b.WriteString(req.URL.EscapedPath() + "\n")
+	b.WriteString(canonicalQuery(query) + "\n")
+	for _, name := range headers {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		b.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	b.WriteString("\n" + strings.Join(headers, ";") + "\n")
+	b.WriteString(payload)
+	return b.String()
+}
+
+func (s *signer) signature(canonical, date string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := sigV4Algorithm + "\n" + date + "\n" + s.scope(date) + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date[:8])
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalQuery encodes a query sorted by key, escaping spaces as `%20`.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
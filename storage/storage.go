@@ -0,0 +1,114 @@
+// Package storage implements contracts.IStorage on the local disk, in
+// memory and on S3-compatible object stores.
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+// NewStorage creates the IStorage selected by the `storage.backend`
+// configuration parameter; either "local" (default), "s3" or "memory".
+//
+// The local storage keeps files under `storage.local.root`, which defaults
+// to the `files` directory of StorageDir. The s3 storage is configured by
+// `storage.s3.endpoint`, `region`, `bucket`, `access_key`, `secret_key` and
+// `path_style`, which MinIO and most other S3-compatible stores need.
+func NewStorage(config contracts.IConfigService) (contracts.IStorage, error) {
+	switch config.String("storage.backend") {
+	case "s3":
+		return NewS3Storage(S3Options{
+			Endpoint:  config.String("storage.s3.endpoint"),
+			Region:    config.String("storage.s3.region"),
+			Bucket:    config.String("storage.s3.bucket"),
+			AccessKey: config.String("storage.s3.access_key"),
+			SecretKey: config.String("storage.s3.secret_key"),
+			PathStyle: config.Bool("storage.s3.path_style"),
+		})
+	case "memory":
+		return NewMemoryStorage(config), nil
+	}
+
+	root := config.String("storage.local.root")
+	if root == "" {
+		root = filepath.Join(config.StorageDir(), "files")
+	}
+	return NewLocalStorage(config, root), nil
+}
+
+// CleanKey checks a key, returning it without redundant slashes. It returns
+// ErrInvalidStorageKey if the key is empty, absolute or contains `..`, so
+// it can't reach out of the storage, or control characters.
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) ||
+		strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return "", contracts.ErrInvalidStorageKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." || segment == "." {
+			return "", contracts.ErrInvalidStorageKey
+		}
+	}
+	key = path.Clean(key)
+	if key == "." {
+		return "", contracts.ErrInvalidStorageKey
+	}
+	return key, nil
+}
+
+// Secret returns the key which download URLs are signed by, i.e.
+// `storage.secret`, or else a key derived from `auth.jwt.secret`.
+func Secret(config contracts.IConfigService) []byte {
+	return utils.DeriveSecret(config, "storage.secret", downloadKeyInfo)
+}
+
+// downloadKeyInfo binds the keys derived by Secret to signing download URLs.
+const downloadKeyInfo = "leviathan storage download"
+
+// FilesPath is the path of the route serving the files of signed URLs.
+const FilesPath = "/v1/files/"
+
+// signedURL returns the URL of the files route downloading key until
+// expiry, for backends which can't sign URLs themselves.
+func signedURL(config contracts.IConfigService, key string, expiry time.Duration) (string, error) {
+	return SignURL(config, key, expiry, 0)
+}
+
+// sign signs key until expires, bound to user unless it's empty. Each field
+// is prefixed by its length, so no fields sign the same as others.
+func sign(secret []byte, key, expires, user string) string {
+	mac := hmac.New(sha256.New, secret)
+	for _, field := range []string{key, expires, user} {
+		fmt.Fprintf(mac, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// escapeKey escapes the segments of a key for the path of a URL.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// typeByKey guesses the content type of a key by its extension.
+func typeByKey(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,38 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/storage"
+	"github.com/mostafasolati/leviathan/storage/storagetest"
+)
+
+func TestLocalStorage(t *testing.T) {
+	storagetest.TestStorage(t, func(config contracts.IConfigService) contracts.IStorage {
+		return storage.NewLocalStorage(config, filepath.Join(config.StorageDir(), "files"))
+	})
+}
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.TestStorage(t, storage.NewMemoryStorage)
+}
+
+func TestS3Storage(t *testing.T) {
+	storagetest.TestStorage(t, func(config contracts.IConfigService) contracts.IStorage {
+		server := storagetest.NewS3Server("access")
+		t.Cleanup(server.Close)
+		s, err := storage.NewS3Storage(storage.S3Options{
+			Endpoint:  server.URL,
+			Bucket:    "test",
+			AccessKey: "access",
+			SecretKey: "secret",
+			PathStyle: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,152 @@
+package storagetest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type object struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// s3Server keeps the objects of path-style buckets in memory.
+type s3Server struct {
+	accessKey string
+
+	mu      sync.Mutex
+	objects map[string]*object
+}
+
+// NewS3Server starts an in-memory stand-in of S3 serving path-style
+// requests, e.g. `PUT /bucket/key`. It supports putting, getting (with
+// ranges), deleting and listing (v2) objects, and rejects requests which aren't signed by
+// accessKey, either in the `Authorization` header or presigned.
+func NewS3Server(accessKey string) *httptest.Server {
+	s := &s3Server{
+		accessKey: accessKey,
+		objects:   make(map[string]*object),
+	}
+	return httptest.NewServer(s)
+}
+
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.signed(r) {
+		writeError(w, http.StatusForbidden, "AccessDenied", "Access Denied.")
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) < 2 || parts[1] == "" {
+		if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+			s.list(w, parts[0], r.URL.Query().Get("prefix"))
+			return
+		}
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "Not implemented.")
+		return
+	}
+	name := parts[0] + "/" + parts[1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		s.objects[name] = &object{
+			data:        data,
+			contentType: r.Header.Get("Content-Type"),
+			modTime:     time.Now().UTC().Truncate(time.Second),
+		}
+		w.Header().Set("ETag", etag(data))
+	case http.MethodGet, http.MethodHead:
+		o, ok := s.objects[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		w.Header().Set("Content-Type", o.contentType)
+		w.Header().Set("ETag", etag(o.data))
+		http.ServeContent(w, r, "", o.modTime, bytes.NewReader(o.data))
+	case http.MethodDelete:
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method not allowed.")
+	}
+}
+
+func (s *s3Server) signed(r *http.Request) bool {
+	credential := "Credential=" + s.accessKey + "/"
+	if strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "+credential) {
+		return true
+	}
+	query := r.URL.Query()
+	return strings.HasPrefix("Credential="+query.Get("X-Amz-Credential"), credential) &&
+		query.Get("X-Amz-Signature") != ""
+}
+
+func (s *s3Server) list(w http.ResponseWriter, bucket, prefix string) {
+	type content struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int    `xml:"Size"`
+	}
+	var result struct {
+		XMLName     xml.Name  `xml:"ListBucketResult"`
+		Name        string    `xml:"Name"`
+		Prefix      string    `xml:"Prefix"`
+		IsTruncated bool      `xml:"IsTruncated"`
+		Contents    []content `xml:"Contents"`
+	}
+	result.Name = bucket
+	result.Prefix = prefix
+
+	s.mu.Lock()
+	for name, o := range s.objects {
+		if key := strings.TrimPrefix(name, bucket+"/"); key != name && strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, content{
+				Key:          key,
+				LastModified: o.modTime.Format(time.RFC3339),
+				ETag:         etag(o.data),
+				Size:         len(o.data),
+			})
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(result.Contents, func(i, j int) bool {
+		return result.Contents[i].Key < result.Contents[j].Key
+	})
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: message})
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
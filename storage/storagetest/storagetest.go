@@ -0,0 +1,201 @@
+// Package storagetest provides a conformance suite for implementations of
+// contracts.IStorage, and an in-memory stand-in of S3 to run it against the
+// S3 storage.
+package storagetest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// Factory creates the IStorage under test.
+type Factory func(config contracts.IConfigService) contracts.IStorage
+
+// TestStorage runs the conformance suite against the storages created by
+// newStorage, which is called for each case. The cases expect an empty
+// storage, so one shared by the cases must be emptied in between, e.g. by
+// starting a server for each:
+//
+//	func TestS3(t *testing.T) {
+//	    storagetest.TestStorage(t, func(config contracts.IConfigService) contracts.IStorage {
+//	        server := storagetest.NewS3Server("access")
+//	        t.Cleanup(server.Close)
+//	        s, _ := storage.NewS3Storage(storage.S3Options{
+//	            Endpoint: server.URL, Bucket: "test", AccessKey: "access", PathStyle: true,
+//	        })
+//	        return s
+//	    })
+//	}
+func TestStorage(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		test func(t *testing.T, s contracts.IStorage)
+	}{
+		{"PutGet", func(t *testing.T, s contracts.IStorage) {
+			put(t, s, "original/photo.png", "content")
+			expectContent(t, s, "original/photo.png", "content")
+
+			info, err := s.Stat(ctx, "original/photo.png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Key != "original/photo.png" || info.Size != 7 || info.ContentType != "image/png" {
+				t.Errorf("info = %+v", info)
+			}
+		}},
+		{"Overwrite", func(t *testing.T, s contracts.IStorage) {
+			put(t, s, "a.txt", "old")
+			put(t, s, "a.txt", "new content")
+			expectContent(t, s, "a.txt", "new content")
+		}},
+		{"Missing", func(t *testing.T, s contracts.IStorage) {
+			if _, _, err := s.Get(ctx, "missing.txt"); err != contracts.ErrFileNotFound {
+				t.Errorf("Get = %v, want ErrFileNotFound", err)
+			}
+			if _, err := s.Stat(ctx, "missing.txt"); err != contracts.ErrFileNotFound {
+				t.Errorf("Stat = %v, want ErrFileNotFound", err)
+			}
+		}},
+		{"Delete", func(t *testing.T, s contracts.IStorage) {
+			put(t, s, "a.txt", "content")
+			if err := s.Delete(ctx, "a.txt"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Stat(ctx, "a.txt"); err != contracts.ErrFileNotFound {
+				t.Errorf("Stat = %v, want ErrFileNotFound", err)
+			}
+			if err := s.Delete(ctx, "a.txt"); err != nil {
+				t.Errorf("deleting a missing file: %v", err)
+			}
+		}},
+		{"List", func(t *testing.T, s contracts.IStorage) {
+			for _, key := range []string{"thumbs/b.png", "original/b.png", "original/a.png", "original/sub/c.png"} {
+				put(t, s, key, key)
+			}
+			files, err := s.List(ctx, "original/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, f := range files {
+				keys = append(keys, f.Key)
+			}
+			if got, want := strings.Join(keys, ","), "original/a.png,original/b.png,original/sub/c.png"; got != want {
+				t.Errorf("keys = %s, want %s", got, want)
+			}
+			if len(files) > 0 && files[0].Size != int64(len("original/a.png")) {
+				t.Errorf("size = %d", files[0].Size)
+			}
+
+			files, err = s.List(ctx, "missing/")
+			if err != nil || len(files) != 0 {
+				t.Errorf("List = %v, %v, want no files", files, err)
+			}
+		}},
+		{"InvalidKey", func(t *testing.T, s contracts.IStorage) {
+			for _, key := range []string{"", "/etc/passwd", "../secret", "a/../../b", `a\b`, "a\nb"} {
+				err := s.Put(ctx, key, strings.NewReader("x"), "")
+				if err != contracts.ErrInvalidStorageKey {
+					t.Errorf("Put(%q) = %v, want ErrInvalidStorageKey", key, err)
+				}
+				if _, _, err := s.Get(ctx, key); err != contracts.ErrInvalidStorageKey {
+					t.Errorf("Get(%q) = %v, want ErrInvalidStorageKey", key, err)
+				}
+			}
+		}},
+		{"GetRange", func(t *testing.T, s contracts.IStorage) {
+			ranges, ok := s.(contracts.IRangeStorage)
+			if !ok {
+				t.Skip("the storage doesn't implement IRangeStorage")
+			}
+			put(t, s, "video.mp4", "0123456789")
+			for _, tc := range []struct {
+				offset, length int64
+				want           string
+			}{
+				{0, 3, "012"},
+				{4, 2, "45"},
+				{7, -1, "789"},
+			} {
+				r, err := ranges.GetRange(ctx, "video.mp4", tc.offset, tc.length)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := ioutil.ReadAll(r)
+				r.Close()
+				if err != nil || string(data) != tc.want {
+					t.Errorf("GetRange(%d, %d) = %q, %v, want %q", tc.offset, tc.length, data, err, tc.want)
+				}
+			}
+			if _, err := ranges.GetRange(ctx, "missing.mp4", 0, 1); err != contracts.ErrFileNotFound {
+				t.Errorf("GetRange of a missing file = %v, want ErrFileNotFound", err)
+			}
+		}},
+		{"SignedURL", func(t *testing.T, s contracts.IStorage) {
+			put(t, s, "private/report 1.pdf", "content")
+			signed, err := s.SignedURL(ctx, "private/report 1.pdf", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(u.Path, "/private/report 1.pdf") || u.RawQuery == "" {
+				t.Errorf("signed URL = %s", signed)
+			}
+		}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "storagetest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			cfg := config.NewConfigService()
+			cfg.SetString("storage-dir", filepath.Join(dir, "storage"))
+			cfg.SetString("storage.secret", "storagetest-secret")
+			tc.test(t, newStorage(cfg))
+		})
+	}
+}
+
+func put(t *testing.T, s contracts.IStorage, key, content string) {
+	t.Helper()
+	if err := s.Put(context.Background(), key, strings.NewReader(content), ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectContent(t *testing.T, s contracts.IStorage, key, want string) {
+	t.Helper()
+	r, info, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+	if info.Size != int64(len(want)) {
+		t.Errorf("size = %d, want %d", info.Size, len(want))
+	}
+}
@@ -0,0 +1,67 @@
+package user
+
+import (
+	"strings"
+	"time"
+)
+
+// model is a row of the `users` table implementing contracts.IUser.
+type model struct {
+	id                 int
+	phone              string
+	firstName          string
+	lastName           string
+	roles              []string
+	refreshToken       string
+	refreshTokenExpiry *time.Time
+	createdAt          time.Time
+	deletedAt          *time.Time
+}
+
+func (u *model) ID() int {
+	return u.id
+}
+
+func (u *model) DeletedAt() *time.Time {
+	return u.deletedAt
+}
+
+func (u *model) FullName() string {
+	return strings.TrimSpace(u.firstName + " " + u.lastName)
+}
+
+func (u *model) FirstName() string {
+	return u.firstName
+}
+
+func (u *model) LastName() string {
+	return u.lastName
+}
+
+func (u *model) Phone() string {
+	return u.phone
+}
+
+func (u *model) Roles() []string {
+	return u.roles
+}
+
+func (u *model) CreatedAt() time.Time {
+	return u.createdAt
+}
+
+func (u *model) RefreshTokenExpiry() *time.Time {
+	return u.refreshTokenExpiry
+}
+
+func (u *model) RefreshToken() string {
+	return u.refreshToken
+}
+
+func (u *model) SetRefreshToken(token string) {
+	u.refreshToken = token
+}
+
+func (u *model) SetRefreshTokenExpiry(t time.Time) {
+	u.refreshTokenExpiry = &t
+}
@@ -1,21 +1,243 @@
 package user
 
-import "github.com/mostafasolati/leviathan/contracts"
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
 
-type user struct{}
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/models"
+)
 
-func NewUserService() contracts.IUserService {
-	return &user{}
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations returns the database migrations of the user service.
+func Migrations() fs.FS {
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
 }
 
-func (s *user) FindByRefreshToken(token string) (contracts.IUser, error) {
-	panic("implement me")
+const userColumns = `id, phone, first_name, last_name, roles, refresh_token,
+	refresh_token_expiry, created_at, deleted_at`
+
+// Filters is the allowlist of the filters and sort keys of users, e.g. for
+//
+//	q, err := user.Filters.Parse(server.QueryParams())
+//	users, total, err := userService.List(q, server.Page())
+var Filters = models.NewSpec(map[string]models.Field{
+	"id":         {Type: models.IntField, Ops: []models.Op{models.Eq, models.In}, Sortable: true, Selectable: true},
+	"phone":      {Ops: []models.Op{models.Eq, models.Like}, Selectable: true},
+	"first_name": {Ops: []models.Op{models.Eq, models.Like}, Sortable: true, Selectable: true},
+	"last_name":  {Ops: []models.Op{models.Eq, models.Like}, Sortable: true, Selectable: true},
+	"roles":      {Ops: []models.Op{models.Like}, Selectable: true},
+	"created_at": {Type: models.TimeField, Ops: []models.Op{models.Lt, models.Lte, models.Gt, models.Gte}, Sortable: true, Selectable: true},
+	"deleted_at": {Type: models.TimeField, Ops: []models.Op{models.Null, models.Lt, models.Gt}, Sortable: true, Selectable: true},
+}, "id")
+
+type user struct {
+	db *sql.DB
 }
 
-func (s *user) Create() error {
-	panic("implement me")
+// NewUserService creates a new IUserService backed by the `users` table.
+func NewUserService(db *sql.DB) contracts.IUserService {
+	return &user{db: db}
+}
+
+// FindByID implements IUserService.FindByID
+func (s *user) FindByID(id int) (contracts.IUser, error) {
+	return s.findBy("id", id)
+}
+
+// FindByRefreshToken implements IUserService.FindByRefreshToken
+func (s *user) FindByRefreshToken(token string) (contracts.IUser, error) {
+	if token == "" {
+		return nil, contracts.ErrUserNotFound
+	}
+	return s.findBy("refresh_token", token)
 }
 
+// FindByPhone implements IUserService.FindByPhone
 func (s *user) FindByPhone(phone string) (contracts.IUser, error) {
-	panic("implement me")
+	return s.findBy("phone", phone)
+}
+
+// Create implements IUserService.Create
+func (s *user) Create(phone, firstName, lastName string) (contracts.IUser, error) {
+	var id int
+	err := s.db.QueryRow(
+		`INSERT INTO users(phone, first_name, last_name) VALUES($1, $2, $3) RETURNING id`,
+		phone, firstName, lastName,
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FindByID(id)
+}
+
+// Update implements IUserService.Update
+func (s *user) Update(u contracts.IUser) error {
+	_, err := s.db.Exec(
+		`UPDATE users SET first_name = $1, last_name = $2, refresh_token = $3,
+			refresh_token_expiry = $4, updated_at = $5 WHERE id = $6`,
+		u.FirstName(), u.LastName(), u.RefreshToken(), u.RefreshTokenExpiry(), time.Now(), u.ID(),
+	)
+	return err
+}
+
+// Deactivate implements IUserService.Deactivate
+func (s *user) Deactivate(id int) error {
+	return s.exec(
+		`UPDATE users SET deleted_at = $1, refresh_token = '', updated_at = $1
+			WHERE id = $2 AND deleted_at IS NULL`,
+		time.Now(), id,
+	)
+}
+
+// GrantRole implements IUserService.GrantRole
+func (s *user) GrantRole(id int, role string) error {
+	u, err := s.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	role = strings.ToLower(strings.TrimSpace(role))
+	roles := u.Roles()
+	for _, r := range roles {
+		if r == role {
+			return nil
+		}
+	}
+
+	return s.exec(
+		`UPDATE users SET roles = $1, updated_at = $2 WHERE id = $3`,
+		strings.Join(append(roles, role), ","), time.Now(), id,
+	)
+}
+
+// List implements IUserService.List
+func (s *user) List(q *models.Query, page models.Page) ([]contracts.IUser, int, error) {
+	where, args := q.Where(0)
+
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// id breaks ties, so pages don't overlap.
+	orderBy := q.OrderBy()
+	if orderBy != "" {
+		orderBy += ", "
+	}
+	// Only the selected fields are read, or all of them if q selects none.
+	columns := userColumns
+	if len(q.Fields) > 0 {
+		columns = q.Columns()
+	}
+	rows, err := s.db.Query(
+		fmt.Sprintf(`SELECT `+columns+` FROM users WHERE `+where+` ORDER BY `+orderBy+`id
+			LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2),
+		append(args, page.PerPage, page.Offset())...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []contracts.IUser{}
+	for rows.Next() {
+		var u *model
+		if len(q.Fields) > 0 {
+			u, err = scanFields(rows, q.Fields)
+		} else {
+			u, err = scan(rows)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	return users, total, rows.Err()
+}
+
+func (s *user) findBy(column string, value interface{}) (contracts.IUser, error) {
+	u, err := scan(s.db.QueryRow(
+		`SELECT `+userColumns+` FROM users WHERE `+column+` = $1`, value,
+	))
+	if err == sql.ErrNoRows {
+		return nil, contracts.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// scan reads a user selected by userColumns.
+func scan(row interface{ Scan(...interface{}) error }) (*model, error) {
+	var u model
+	var roles string
+	err := row.Scan(
+		&u.id, &u.phone, &u.firstName, &u.lastName, &roles, &u.refreshToken,
+		&u.refreshTokenExpiry, &u.createdAt, &u.deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	u.roles = strings.Split(roles, ",")
+	return &u, nil
+}
+
+// scanFields reads a user selected by the columns of fields of Filters,
+// leaving the others zero.
+func scanFields(row interface{ Scan(...interface{}) error }, fields []string) (*model, error) {
+	var u model
+	var roles string
+	dest := make([]interface{}, len(fields))
+	for i, field := range fields {
+		switch field {
+		case "id":
+			dest[i] = &u.id
+		case "phone":
+			dest[i] = &u.phone
+		case "first_name":
+			dest[i] = &u.firstName
+		case "last_name":
+			dest[i] = &u.lastName
+		case "roles":
+			dest[i] = &roles
+		case "created_at":
+			dest[i] = &u.createdAt
+		case "deleted_at":
+			dest[i] = &u.deletedAt
+		default:
+			return nil, fmt.Errorf("cannot read the field %q of users", field)
+		}
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if roles != "" {
+		u.roles = strings.Split(roles, ",")
+	}
+	return &u, nil
+}
+
+// exec runs an update query and reports ErrUserNotFound if no row is affected.
+func (s *user) exec(query string, args ...interface{}) error {
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return contracts.ErrUserNotFound
+	}
+	return nil
 }
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// UploadFile saves an uploaded image in the `original` directory of
+// imagesRoot, returning its file name.
+//
+// Deprecated: Use StoreFile, which stores the file in an IStorage by the hash
+// of its content and records its metadata, or IServer.Upload.
+func UploadFile(imagesRoot string, h *multipart.FileHeader) (string, error) {
+	ext, err := ParseImageExtFromMIMEType(h.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+	src, err := h.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dir := filepath.Join(imagesRoot, "original")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	filename := fmt.Sprintf("%d.%s", time.Now().Unix(), ext)
+	dest, err := os.Create(filepath.Join(dir, filename))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return "", err
+	}
+	return filename, dest.Close()
+}
+
+// Thumb resizes an image saved by UploadFile in imagesRoot to w×h, returning
+// the path of the thumbnail, which is reused by later calls.
+//
+// Deprecated: Use images.Thumb, which resizes the files of an IStorage and
+// caches the thumbnails in it.
+func Thumb(imagesRoot, file string, w, h int) (string, error) {
+	if w > DefaultMaxImageDimension || h > DefaultMaxImageDimension {
+		return "", contracts.ErrFileDimensionQuota
+	}
+	if file != filepath.Base(file) {
+		return "", contracts.ErrFileNotFound
+	}
+
+	dir := filepath.Join(imagesRoot, "thumbs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	thumb := filepath.Join(dir, fmt.Sprintf("%d_%d_%s", w, h, file))
+	if _, err := os.Stat(thumb); !os.IsNotExist(err) {
+		return thumb, err
+	}
+
+	image, err := imaging.Open(filepath.Join(imagesRoot, "original", file))
+	if err != nil {
+		return "", fmt.Errorf("error in opening %s file: %v", file, err)
+	}
+	image = imaging.Fill(image, w, h, imaging.Center, imaging.Lanczos)
+	if err := imaging.Save(image, thumb); err != nil {
+		return "", fmt.Errorf("error in saving %s file %v", file, err)
+	}
+	return thumb, nil
+}
@@ -0,0 +1,70 @@
+package utils_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+func TestDeprecatedUploadFileThumb(t *testing.T) {
+	content := &bytes.Buffer{}
+	if err := png.Encode(content, image.NewGray(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatal(err)
+	}
+	form := &bytes.Buffer{}
+	w := multipart.NewWriter(form)
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="image"; filename="a.png"`},
+		"Content-Type":        {"image/png"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content.Bytes())
+	w.Close()
+	parsed, err := multipart.NewReader(form, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	name, err := utils.UploadFile(root, parsed.File["image"][0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(name) != ".png" {
+		t.Errorf("UploadFile = %s, want a png", name)
+	}
+
+	thumb, err := utils.Thumb(root, name, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(thumb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	config, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 10 || config.Height != 10 {
+		t.Errorf("Thumb = %dx%d, want 10x10", config.Width, config.Height)
+	}
+
+	if _, err := utils.Thumb(root, name, utils.DefaultMaxImageDimension+1, 10); err != contracts.ErrFileDimensionQuota {
+		t.Errorf("Thumb of a large size = %v, want %v", err, contracts.ErrFileDimensionQuota)
+	}
+	if _, err := utils.Thumb(root, "../"+name, 10, 10); err != contracts.ErrFileNotFound {
+		t.Errorf("Thumb outside the root = %v, want %v", err, contracts.ErrFileNotFound)
+	}
+}
@@ -1,20 +1,24 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mostafasolati/leviathan/contracts"
-
-	"github.com/disintegration/imaging"
+	"github.com/mostafasolati/leviathan/models"
 )
 
-// ImageURL returns absolute url of the image
+// ImageURL returns absolute url of the image, which is served by the route of
+// images.Register, e.g. resized by appending `?w=200&h=200`.
 func ImageURL(baseUrl, image string) string {
 	if image == "" {
 		return ""
@@ -22,7 +26,8 @@ func ImageURL(baseUrl, image string) string {
 	return fmt.Sprintf("%s/v1/image/%s", baseUrl, image)
 }
 
-// BannerURL returns the fully-qualified URL for a banner.
+// BannerURL returns the fully-qualified URL for a banner, which is served by
+// the route of images.Register.
 func BannerURL(baseURL, image string) string {
 	if image == "" {
 		return ""
@@ -30,68 +35,196 @@ func BannerURL(baseURL, image string) string {
 	return fmt.Sprintf("%s/v1/image/banner/%s", baseURL, image)
 }
 
-func UploadFile(imagesRoot string, h *multipart.FileHeader) (string, error) {
-	src, err := h.Open()
+// AvatarURL returns the fully-qualified URL for an avatar, which is served by
+// the route of images.Register.
+func AvatarURL(baseURL, image string) string {
+	if image == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/v1/image/avatar/%s", baseURL, image)
+}
+
+// StoreFile stores the content of an uploaded file of contentType, e.g. as
+// detected by ValidateUpload, in the storage, named by the SHA-256 of its
+// content, returning its metadata. The content is stored once however many
+// users upload it, while the name and uploader are recorded for each of them,
+// so uploaders see only their own metadata.
+func StoreFile(ctx context.Context, storage contracts.IStorage, name string, src io.ReadSeeker, contentType string, uploaderID int) (*models.File, error) {
+	ext, err := ExtFromMIMEType(contentType)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	var destFilename string
-	var filename string
+	hash := sha256.New()
+	size, err := io.Copy(hash, src)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(hash.Sum(nil)) + "." + ext
 
-	ext, err := ParseImageExtFromMIMEType(h.Header.Get("Content-Type"))
+	now := time.Now().UTC()
+	_, err = LookupFile(ctx, storage, id)
+	if err == contracts.ErrFileNotFound {
+		if err := storage.Put(ctx, OriginalKey(id), src, contentType); err != nil {
+			return nil, err
+		}
+		err = putMetadata(ctx, storage, metadataKey(id), &models.File{
+			ID:          id,
+			Size:        size,
+			ContentType: contentType,
+			CreatedAt:   now,
+		})
+	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	path := filepath.Join(imagesRoot, "original")
-	_ = os.MkdirAll(path, 0755)
-	filename = fmt.Sprintf("%d.%s", time.Now().Unix(), ext)
-	destFilename = fmt.Sprintf("%s/%s", path, filename)
+	file := &models.File{
+		ID:          id,
+		Name:        filepath.Base(name),
+		Size:        size,
+		ContentType: contentType,
+		UploaderID:  uploaderID,
+		CreatedAt:   now,
+	}
+	if err := putMetadata(ctx, storage, uploadKey(id, uploaderID), file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
 
-	dest, err := os.Create(destFilename)
+// LookupFile returns the metadata of the content of an uploaded file by its
+// ID, which is shared by its uploaders, so it has no name nor uploader.
+func LookupFile(ctx context.Context, storage contracts.IStorage, id string) (*models.File, error) {
+	if !validFileID(id) {
+		return nil, contracts.ErrFileNotFound
+	}
+	file, err := getMetadata(ctx, storage, metadataKey(id))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	file.Name, file.UploaderID = "", 0
+	return file, nil
+}
 
-	_, err = io.Copy(dest, src)
+// LookupUpload returns the metadata of a file uploaded by uploaderID by its
+// ID, i.e. the name the user uploaded it by. It returns ErrFileNotFound if
+// the user didn't upload it, even if others did.
+func LookupUpload(ctx context.Context, storage contracts.IStorage, id string, uploaderID int) (*models.File, error) {
+	if !validFileID(id) {
+		return nil, contracts.ErrFileNotFound
+	}
+	return getMetadata(ctx, storage, uploadKey(id, uploaderID))
+}
 
-	return filename, err
+// DeleteFile deletes the metadata of a file uploaded by uploaderID, and its
+// content once no user has uploaded it, reporting whether the content was
+// deleted. It returns ErrFileNotFound if the user didn't upload it.
+func DeleteFile(ctx context.Context, storage contracts.IStorage, id string, uploaderID int) (bool, error) {
+	if _, err := LookupUpload(ctx, storage, id, uploaderID); err != nil {
+		return false, err
+	}
+	if err := storage.Delete(ctx, uploadKey(id, uploaderID)); err != nil {
+		return false, err
+	}
 
+	uploads, err := storage.List(ctx, uploadsPrefix(id))
+	if err != nil || len(uploads) > 0 {
+		return false, err
+	}
+	// The metadata goes first, so the file is never found without content.
+	if err := storage.Delete(ctx, metadataKey(id)); err != nil {
+		return false, err
+	}
+	if err := storage.Delete(ctx, OriginalKey(id)); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-func Thumb(imagesRoot, file string, w, h int) (string, error) {
-	if w > 1000 || h > 1000 {
-		return "", contracts.ErrFileDimensionQuota
+func putMetadata(ctx context.Context, storage contracts.IStorage, key string, file *models.File) error {
+	metadata, err := json.Marshal(file)
+	if err != nil {
+		return err
 	}
+	return storage.Put(ctx, key, bytes.NewReader(metadata), "application/json")
+}
 
-	thumb := filepath.Join(imagesRoot, "thumbs")
-	_ = os.Mkdir(thumb, 0755)
-	thumb += fmt.Sprintf("/%d_%d_%s", w, h, file)
+func getMetadata(ctx context.Context, storage contracts.IStorage, key string) (*models.File, error) {
+	r, _, err := storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
 
-	_, err := os.Stat(thumb)
-	if os.IsNotExist(err) {
-		imagePath := filepath.Join(imagesRoot, "original/"+file)
-		image, err := imaging.Open(imagePath)
-		if err != nil {
-			return "", fmt.Errorf("error in opening %s file: %v", file, err)
-		}
-		image = imaging.Fill(image, w, h, imaging.Center, imaging.Lanczos)
-		err = imaging.Save(image, thumb)
-		if err != nil {
-			return "", fmt.Errorf("error in saving %s file %v", file, err)
-		}
+	file := &models.File{}
+	if err := json.NewDecoder(r).Decode(file); err != nil {
+		return nil, err
 	}
+	return file, nil
+}
+
+// OriginalKey returns the storage key of an uploaded file, which is sharded
+// by the first bytes of its ID, e.g. `original/ab/cd/abcd….png`.
+func OriginalKey(id string) string {
+	return "original/" + shard(id) + id
+}
+
+// VariantsPrefix returns the prefix of the storage keys of the variants of an
+// uploaded file, e.g. the images resized on request.
+func VariantsPrefix(id string) string {
+	return "variants/" + shard(id) + id + "/"
+}
+
+// metadataKey returns the storage key of the metadata of an uploaded file.
+func metadataKey(id string) string {
+	return "metadata/" + shard(id) + id + ".json"
+}
 
-	return thumb, nil
+// uploadKey returns the storage key of the metadata of an uploaded file
+// recorded for one of its uploaders.
+func uploadKey(id string, uploaderID int) string {
+	return uploadsPrefix(id) + strconv.Itoa(uploaderID) + ".json"
 }
 
-// ParseImageExtFromMIMEType determines the image file extension based on its
-// MIME type.
-func ParseImageExtFromMIMEType(mimeType string) (string, error) {
-	mime := strings.Split(mimeType, "/")
-	//if mime[0] != "image" {
-	//	return "", errors.New("mime type is not an image")
-	//}
-	return mime[1], nil
+// uploadsPrefix returns the prefix of the storage keys of the metadata of an
+// uploaded file recorded for its uploaders.
+func uploadsPrefix(id string) string {
+	return "metadata/" + shard(id) + id + "/"
+}
+
+// shard returns the directories of an ID, so no directory holds too many
+// files.
+func shard(id string) string {
+	if len(id) < 4 {
+		return ""
+	}
+	return id[:2] + "/" + id[2:4] + "/"
+}
+
+// validFileID reports whether id is the hex SHA-256 of a content followed by
+// an extension.
+func validFileID(id string) bool {
+	i := strings.IndexByte(id, '.')
+	if i != sha256.Size*2 || i == len(id)-1 || strings.ContainsAny(id[i+1:], "./\\") {
+		return false
+	}
+	_, err := hex.DecodeString(id[:i])
+	return err == nil
+}
+
+// DefaultMaxImageDimension is the largest width or height of the images
+// resized on request, unless configured by `image.max_dimension`.
+const DefaultMaxImageDimension = 1000
+
+// MaxImageDimension returns the largest width or height of the images
+// resized on request.
+func MaxImageDimension(config contracts.IConfigService) int {
+	if max := config.Int("image.max_dimension"); max > 0 {
+		return max
+	}
+	return DefaultMaxImageDimension
 }
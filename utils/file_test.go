@@ -0,0 +1,109 @@
+package utils_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/storage"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+func TestStoreFileUploaders(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage(config.NewConfigService())
+	content := []byte("GIF89a")
+
+	uploads := []struct {
+		name       string
+		uploaderID int
+	}{
+		{name: "first.gif", uploaderID: 1},
+		{name: "second.gif", uploaderID: 2},
+		{name: "anonymous.gif", uploaderID: 0},
+	}
+	var id string
+	for _, u := range uploads {
+		file, err := utils.StoreFile(ctx, store, u.name, bytes.NewReader(content), "image/gif", u.uploaderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Name != u.name || file.UploaderID != u.uploaderID {
+			t.Errorf("StoreFile = %s of %d, want %s of %d", file.Name, file.UploaderID, u.name, u.uploaderID)
+		}
+		id = file.ID
+	}
+
+	originals, err := store.List(ctx, "original/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(originals) != 1 {
+		t.Errorf("stored %d originals, want 1", len(originals))
+	}
+
+	for _, u := range uploads {
+		file, err := utils.LookupUpload(ctx, store, id, u.uploaderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Name != u.name || file.UploaderID != u.uploaderID {
+			t.Errorf("LookupUpload(%d) = %s of %d, want %s", u.uploaderID, file.Name, file.UploaderID, u.name)
+		}
+	}
+	if _, err := utils.LookupUpload(ctx, store, id, 3); err != contracts.ErrFileNotFound {
+		t.Errorf("LookupUpload of another user = %v, want %v", err, contracts.ErrFileNotFound)
+	}
+
+	file, err := utils.LookupFile(ctx, store, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != "" || file.UploaderID != 0 || file.ContentType != "image/gif" || file.Size != int64(len(content)) {
+		t.Errorf("LookupFile = %+v, want the content without its uploaders", file)
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage(config.NewConfigService())
+	content := []byte("GIF89a")
+	var id string
+	for _, uploaderID := range []int{1, 2} {
+		file, err := utils.StoreFile(ctx, store, "a.gif", bytes.NewReader(content), "image/gif", uploaderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id = file.ID
+	}
+
+	cases := []struct {
+		name       string
+		uploaderID int
+		deleted    bool
+		err        error
+	}{
+		{name: "OtherUser", uploaderID: 3, err: contracts.ErrFileNotFound},
+		{name: "Shared", uploaderID: 1},
+		{name: "Again", uploaderID: 1, err: contracts.ErrFileNotFound},
+		{name: "Last", uploaderID: 2, deleted: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			deleted, err := utils.DeleteFile(ctx, store, id, tc.uploaderID)
+			if deleted != tc.deleted || err != tc.err {
+				t.Errorf("DeleteFile = %v, %v, want %v, %v", deleted, err, tc.deleted, tc.err)
+			}
+			_, err = store.Stat(ctx, utils.OriginalKey(id))
+			if found := err == nil; found == tc.deleted {
+				t.Errorf("content found = %v after DeleteFile", found)
+			}
+		})
+	}
+
+	if _, err := utils.LookupFile(ctx, store, id); err != contracts.ErrFileNotFound {
+		t.Errorf("LookupFile = %v, want %v", err, contracts.ErrFileNotFound)
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"github.com/mostafasolati/leviathan/contracts"
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveSecret returns the secret configured by key, or else a key derived
+// from `auth.jwt.secret` by HKDF for the purpose described by info, so the
+// key of tokens signs nothing else and keys of other purposes differ. It
+// returns nil if neither is configured.
+func DeriveSecret(config contracts.IConfigService, key, info string) []byte {
+	if secret := config.String(key); secret != "" {
+		return []byte(secret)
+	}
+	jwtSecret := config.String("auth.jwt.secret")
+	if jwtSecret == "" {
+		return nil
+	}
+	derived := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(jwtSecret), nil, []byte(info)), derived); err != nil {
+		return nil
+	}
+	return derived
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// The namespaces of the elements which browsers render or run in SVG files.
+const (
+	svgNamespace    = "http://www.w3.org/2000/svg"
+	xlinkNamespace  = "http://www.w3.org/1999/xlink"
+	xhtmlNamespace  = "http://www.w3.org/1999/xhtml"
+	mathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+	xmlNamespace    = "http://www.w3.org/XML/1998/namespace"
+)
+
+var (
+	// svgElements are the elements of static SVG images, leaving out those
+	// which run scripts, embed HTML, link out or animate attributes, e.g.
+	// `script`, `foreignObject`, `a` and `set`.
+	svgElements = setOf(
+		"svg", "g", "defs", "symbol", "use", "title", "desc", "metadata", "style", "switch",
+		"path", "rect", "circle", "ellipse", "line", "polyline", "polygon", "image",
+		"text", "tspan", "textPath",
+		"linearGradient", "radialGradient", "stop", "pattern", "clipPath", "mask", "marker",
+		"filter", "feBlend", "feColorMatrix", "feComponentTransfer", "feComposite",
+		"feConvolveMatrix", "feDiffuseLighting", "feDisplacementMap", "feDistantLight",
+		"feDropShadow", "feFlood", "feFuncA", "feFuncB", "feFuncG", "feFuncR",
+		"feGaussianBlur", "feMerge", "feMergeNode", "feMorphology", "feOffset",
+		"fePointLight", "feSpecularLighting", "feSpotLight", "feTile", "feTurbulence",
+	)
+
+	// svgAttributes are the attributes of no namespace allowed on the
+	// elements, leaving out event handlers. Links, i.e. href, are checked to
+	// point within the image.
+	svgAttributes = setOf(
+		"id", "class", "style", "lang", "role", "tabindex", "transform",
+		"version", "baseProfile", "viewBox", "preserveAspectRatio", "zoomAndPan",
+		"x", "y", "x1", "y1", "x2", "y2", "cx", "cy", "r", "rx", "ry", "fx", "fy", "fr",
+		"dx", "dy", "width", "height", "d", "points", "pathLength", "rotate",
+		"fill", "fill-opacity", "fill-rule", "stroke", "stroke-width", "stroke-linecap",
+		"stroke-linejoin", "stroke-miterlimit", "stroke-dasharray", "stroke-dashoffset",
+		"stroke-opacity", "opacity", "color", "display", "visibility", "overflow",
+		"clip", "clip-path", "clip-rule", "clipPathUnits", "mask", "maskUnits",
+		"maskContentUnits", "filter", "filterUnits", "primitiveUnits",
+		"gradientUnits", "gradientTransform", "spreadMethod", "offset", "stop-color",
+		"stop-opacity", "patternUnits", "patternContentUnits", "patternTransform",
+		"marker-start", "marker-mid", "marker-end", "markerWidth", "markerHeight",
+		"markerUnits", "refX", "refY", "orient",
+		"font-family", "font-size", "font-size-adjust", "font-style", "font-weight",
+		"font-variant", "font-stretch", "text-anchor", "text-decoration",
+		"letter-spacing", "word-spacing", "dominant-baseline", "alignment-baseline",
+		"baseline-shift", "writing-mode", "direction", "unicode-bidi", "textLength",
+		"lengthAdjust", "startOffset", "method", "spacing", "href",
+		"in", "in2", "result", "stdDeviation", "mode", "operator", "k1", "k2", "k3", "k4",
+		"type", "values", "tableValues", "slope", "intercept", "amplitude", "exponent",
+		"flood-color", "flood-opacity", "lighting-color", "radius", "scale",
+		"xChannelSelector", "yChannelSelector", "baseFrequency", "numOctaves", "seed",
+		"stitchTiles", "order", "kernelMatrix", "divisor", "bias", "targetX", "targetY",
+		"edgeMode", "preserveAlpha", "surfaceScale", "diffuseConstant",
+		"specularConstant", "specularExponent", "azimuth", "elevation", "z",
+		"pointsAtX", "pointsAtY", "pointsAtZ", "limitingConeAngle",
+		"mix-blend-mode", "isolation", "shape-rendering", "text-rendering",
+		"image-rendering", "color-interpolation", "color-interpolation-filters",
+		"color-rendering", "vector-effect", "paint-order", "enable-background",
+		"requiredFeatures", "requiredExtensions", "systemLanguage", "media", "xmlns",
+	)
+)
+
+func setOf(items ...string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// safeSVG reports whether an SVG image is static, parsing it and checking
+// its elements and attributes against an allowlist, after the entities and
+// character references in them are decoded. Document types, which declare
+// entities, and processing instructions other than the XML declaration are
+// rejected, and so are links and CSS loading anything outside the image.
+func safeSVG(content []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = true
+	root, style := true, false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return !root
+		}
+		if err != nil {
+			return false
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if root && t.Name.Local != "svg" {
+				return false
+			}
+			root = false
+			if !safeSVGElement(t) {
+				return false
+			}
+			style = t.Name.Local == "style"
+		case xml.EndElement:
+			style = false
+		case xml.CharData:
+			if style && !safeCSS(string(t)) {
+				return false
+			}
+		case xml.Directive:
+			return false
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				return false
+			}
+		}
+	}
+}
+
+// safeSVGElement reports whether an element and its attributes are allowed.
+// Elements of other namespaces than SVG, HTML and MathML, e.g. the metadata
+// of editors, are neither rendered nor run.
+func safeSVGElement(e xml.StartElement) bool {
+	switch e.Name.Space {
+	case "", svgNamespace:
+		if !svgElements[e.Name.Local] {
+			return false
+		}
+	case xhtmlNamespace, mathMLNamespace:
+		return false
+	}
+
+	for _, attr := range e.Attr {
+		switch attr.Name.Space {
+		case "":
+			if !svgAttributes[attr.Name.Local] && !strings.HasPrefix(attr.Name.Local, "data-") &&
+				!strings.HasPrefix(attr.Name.Local, "aria-") {
+				return false
+			}
+			if attr.Name.Local == "href" && !safeHref(e.Name.Local, attr.Value) {
+				return false
+			}
+		case xlinkNamespace:
+			if attr.Name.Local != "href" && attr.Name.Local != "title" {
+				return false
+			}
+			if attr.Name.Local == "href" && !safeHref(e.Name.Local, attr.Value) {
+				return false
+			}
+		case xmlNamespace:
+			if attr.Name.Local != "space" && attr.Name.Local != "lang" {
+				return false
+			}
+		case "xmlns":
+			continue
+		}
+		if !safeCSS(attr.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// safeHref reports whether a link of an element points within the image, or
+// embeds a raster image in it.
+func safeHref(element, href string) bool {
+	href = strings.TrimSpace(href)
+	if strings.HasPrefix(href, "#") {
+		return true
+	}
+	if element != "image" {
+		return false
+	}
+	for _, prefix := range []string{"data:image/png;", "data:image/jpeg;", "data:image/gif;", "data:image/webp;"} {
+		if strings.HasPrefix(strings.ToLower(href), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeCSS reports whether a value, which may be CSS, loads nothing outside
+// the image, i.e. it imports no style sheets and its `url()` point to
+// fragments. Escapes, which may hide them, aren't allowed.
+func safeCSS(value string) bool {
+	value = strings.ToLower(strings.Join(strings.Fields(value), ""))
+	if strings.Contains(value, `\`) || strings.Contains(value, "@import") ||
+		strings.Contains(value, "expression(") || strings.Contains(value, "javascript:") {
+		return false
+	}
+	for {
+		i := strings.Index(value, "url(")
+		if i < 0 {
+			return true
+		}
+		value = strings.TrimLeft(value[i+len("url("):], `"'`)
+		if !strings.HasPrefix(value, "#") {
+			return false
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/mostafasolati/leviathan/contracts"
+)
+
+// Limits of uploaded files, unless configured by the `upload.max_size` (in
+// bytes) and `upload.types` (comma-separated MIME types) configuration
+// parameters, or per form field by `upload.fields.<field>.max_size` and
+// `upload.fields.<field>.types`.
+const (
+	DefaultUploadMaxSize = 10 << 20
+	DefaultUploadTypes   = "image/jpeg,image/png,image/gif,image/webp"
+)
+
+var (
+	// Markers of content which browsers or interpreters run, found in
+	// polyglots, i.e. files which are valid in more than one format.
+	polyglotMarkers = [][]byte{
+		[]byte("<script"),
+		[]byte("<html"),
+		[]byte("<!doctype html"),
+		[]byte("<?php"),
+		[]byte("<iframe"),
+	}
+
+	// zipMarker is the signature of the end of the central directory of ZIP,
+	// JAR, etc.
+	zipMarker = []byte("PK\x05\x06")
+
+	extensions = map[string]string{
+		"image/svg+xml":            "svg",
+		"image/x-icon":             "ico",
+		"image/vnd.microsoft.icon": "ico",
+		"application/pdf":          "pdf",
+		"text/plain":               "txt",
+	}
+)
+
+// ValidateUpload checks a file uploaded in a form field against the size
+// limit and the allowed types of the field like ValidateContent, returning
+// its content and MIME type.
+func ValidateUpload(config contracts.IConfigService, field string, h *multipart.FileHeader) ([]byte, string, error) {
+	maxSize := MaxUploadSize(config, field)
+	if h.Size > maxSize {
+		return nil, "", contracts.ErrFileTooLarge
+	}
+
+	src, err := h.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	contentType, err := ValidateContent(config, field, content)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, contentType, nil
+}
+
+// ValidateContent checks the content of a file uploaded in a form field
+// against the size limit and the allowed types of the field, returning its
+// MIME type which is detected by the magic bytes of its content rather than
+// trusting the client. Polyglots and SVG files which aren't static images are
+// rejected.
+func ValidateContent(config contracts.IConfigService, field string, content []byte) (string, error) {
+	maxSize, types := uploadLimits(config, field)
+	if int64(len(content)) > maxSize {
+		return "", contracts.ErrFileTooLarge
+	}
+
+	contentType := SniffContentType(content)
+	if !allowedType(types, contentType) {
+		return "", contracts.ErrFileTypeNotAllowed
+	}
+	if !safeContent(contentType, content) {
+		return "", contracts.ErrUnsafeFile
+	}
+	return contentType, nil
+}
+
+// MaxUploadSize returns the size limit of the files uploaded in field.
+func MaxUploadSize(config contracts.IConfigService, field string) int64 {
+	maxSize, _ := uploadLimits(config, field)
+	return maxSize
+}
+
+// SniffContentType detects the MIME type of content by its magic bytes,
+// like http.DetectContentType, but also detects SVG and AVIF images.
+func SniffContentType(content []byte) string {
+	if len(content) >= 12 && string(content[4:12]) == "ftypavif" {
+		return "image/avif"
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+	if strings.HasPrefix(contentType, "text/") && isSVG(content) {
+		return "image/svg+xml"
+	}
+	return contentType
+}
+
+// uploadLimits returns the maximum size and the allowed types of the files
+// uploaded in field.
+func uploadLimits(config contracts.IConfigService, field string) (int64, []string) {
+	maxSize := config.Int("upload.fields." + field + ".max_size")
+	if maxSize <= 0 {
+		maxSize = config.Int("upload.max_size")
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultUploadMaxSize
+	}
+
+	types := config.String("upload.fields." + field + ".types")
+	if types == "" {
+		types = config.String("upload.types")
+	}
+	if types == "" {
+		types = DefaultUploadTypes
+	}
+
+	var allowed []string
+	for _, t := range strings.Split(types, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			allowed = append(allowed, t)
+		}
+	}
+	return int64(maxSize), allowed
+}
+
+// allowedType reports whether contentType matches one of types, which may be
+// wildcards like `image/*`.
+func allowedType(types []string, contentType string) bool {
+	for _, t := range types {
+		if t == contentType || t == "*/*" ||
+			strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeContent reports whether content of contentType carries no markup or
+// archive which may be run as another format. Only the data appended to the
+// images whose end is found is checked, i.e. past the end marker of JPEG,
+// PNG and GIF or the RIFF chunk of WebP, which the images don't contain.
+func safeContent(contentType string, content []byte) bool {
+	if contentType == "image/svg+xml" {
+		return safeSVG(content)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return true
+	}
+	if end := imageEnd(contentType, content); end >= 0 {
+		content = content[end:]
+	}
+	if bytes.Contains(content, zipMarker) {
+		return false
+	}
+	content = bytes.ToLower(content)
+	for _, marker := range polyglotMarkers {
+		if bytes.Contains(content, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+// imageEnd returns the offset of the end of the image of content, or -1 if
+// it isn't found.
+func imageEnd(contentType string, content []byte) int {
+	switch contentType {
+	case "image/jpeg":
+		return jpegEnd(content)
+	case "image/png":
+		return pngEnd(content)
+	case "image/gif":
+		return gifEnd(content)
+	case "image/webp":
+		if len(content) < 12 || string(content[:4]) != "RIFF" {
+			return -1
+		}
+		end := 8 + int(binary.LittleEndian.Uint32(content[4:8]))
+		if end > len(content) {
+			return -1
+		}
+		return end
+	}
+	return -1
+}
+
+// jpegEnd returns the offset past the EOI marker of a JPEG image, skipping
+// the segments and the entropy-coded data of its scans, in which markers
+// are escaped.
+func jpegEnd(content []byte) int {
+	if len(content) < 4 || content[0] != 0xff || content[1] != 0xd8 {
+		return -1
+	}
+	i := 2
+	for i+4 <= len(content) {
+		if content[i] != 0xff {
+			return -1
+		}
+		marker := content[i+1]
+		switch {
+		case marker == 0xd9:
+			return i + 2
+		case marker == 0xff:
+			// Fill bytes.
+			i++
+			continue
+		case marker >= 0xd0 && marker <= 0xd7 || marker == 0x01:
+			i += 2
+			continue
+		}
+		i += 2 + int(binary.BigEndian.Uint16(content[i+2:]))
+		if marker != 0xda {
+			continue
+		}
+		// The scan runs up to the next marker other than a stuffed byte or
+		// a restart.
+		for ; i+1 < len(content); i++ {
+			if content[i] == 0xff && content[i+1] != 0 && (content[i+1] < 0xd0 || content[i+1] > 0xd7) {
+				break
+			}
+		}
+	}
+	if i+2 == len(content) && content[i] == 0xff && content[i+1] == 0xd9 {
+		return i + 2
+	}
+	return -1
+}
+
+// pngEnd returns the offset past the IEND chunk of a PNG image.
+func pngEnd(content []byte) int {
+	i := 8
+	for i+12 <= len(content) {
+		length := int(binary.BigEndian.Uint32(content[i:]))
+		end := i + 12 + length
+		if end > len(content) {
+			return -1
+		}
+		if string(content[i+4:i+8]) == "IEND" {
+			return end
+		}
+		i = end
+	}
+	return -1
+}
+
+// gifEnd returns the offset past the trailer of a GIF image.
+func gifEnd(content []byte) int {
+	if len(content) < 13 {
+		return -1
+	}
+	i := 13
+	if content[10]&0x80 != 0 {
+		i += 3 << (content[10]&7 + 1)
+	}
+	for i < len(content) {
+		switch content[i] {
+		case 0x3b:
+			return i + 1
+		case 0x21:
+			// An extension of a label and sub-blocks.
+			i += 2
+		case 0x2c:
+			// An image descriptor, the local color table and the minimum
+			// code size of the sub-blocks of the image data.
+			if i+10 > len(content) {
+				return -1
+			}
+			flags := content[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&7 + 1)
+			}
+			i++
+		default:
+			return -1
+		}
+		for i < len(content) && content[i] != 0 {
+			i += 1 + int(content[i])
+		}
+		i++
+	}
+	return -1
+}
+
+func isSVG(content []byte) bool {
+	if len(content) > 1024 {
+		content = content[:1024]
+	}
+	return bytes.Contains(bytes.ToLower(content), []byte("<svg"))
+}
+
+// ParseImageExtFromMIMEType determines the image file extension based on its
+// MIME type.
+func ParseImageExtFromMIMEType(mimeType string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(mimeType)), "image/") {
+		return "", contracts.ErrFileTypeNotAllowed
+	}
+	return ExtFromMIMEType(mimeType)
+}
+
+// ExtFromMIMEType determines the file extension based on its MIME type, e.g.
+// `png` for `image/png`.
+func ExtFromMIMEType(mimeType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return "", contracts.ErrFileTypeNotAllowed
+	}
+	if ext, ok := extensions[mediaType]; ok {
+		return ext, nil
+	}
+
+	i := strings.IndexByte(mediaType, '/')
+	if i <= 0 || i == len(mediaType)-1 {
+		return "", contracts.ErrFileTypeNotAllowed
+	}
+	ext := mediaType[i+1:]
+	for _, c := range ext {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return "", contracts.ErrFileTypeNotAllowed
+		}
+	}
+	return ext, nil
+}
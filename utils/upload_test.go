@@ -0,0 +1,150 @@
+package utils_test
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/mostafasolati/leviathan/config"
+	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/utils"
+)
+
+func encoded(encode func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// withComment inserts a COM segment into a JPEG image, after its SOI.
+func withComment(content []byte, comment string) []byte {
+	segment := []byte{0xff, 0xfe, byte((len(comment) + 2) >> 8), byte(len(comment) + 2)}
+	segment = append(segment, comment...)
+	return append(append(append([]byte{}, content[:2]...), segment...), content[2:]...)
+}
+
+func appended(content []byte, data string) []byte {
+	return append(append([]byte{}, content...), data...)
+}
+
+func TestValidateContent(t *testing.T) {
+	jpegImage := encoded(func(buf *bytes.Buffer, img image.Image) error {
+		return jpeg.Encode(buf, img, nil)
+	})
+	pngImage := encoded(func(buf *bytes.Buffer, img image.Image) error {
+		return png.Encode(buf, img)
+	})
+	gifImage := encoded(func(buf *bytes.Buffer, img image.Image) error {
+		return gif.Encode(buf, img, &gif.Options{NumColors: 4})
+	})
+	webpImage := []byte("RIFF\x0c\x00\x00\x00WEBPVP8 \x00\x00\x00\x00")
+
+	const svgHeader = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">`
+	cases := []struct {
+		name    string
+		content []byte
+		want    string
+		err     error
+	}{
+		{name: "JPEG", content: jpegImage, want: "image/jpeg"},
+		{name: "JPEGMarkersInside", content: withComment(jpegImage, "PK\x05\x06<script>"), want: "image/jpeg"},
+		{name: "JPEGZip", content: appended(jpegImage, "PK\x05\x06"), err: contracts.ErrUnsafeFile},
+		{name: "JPEGScript", content: appended(jpegImage, "<SCRIPT>"), err: contracts.ErrUnsafeFile},
+		{name: "PNG", content: pngImage, want: "image/png"},
+		{name: "PNGHTML", content: appended(pngImage, "<!DOCTYPE html>"), err: contracts.ErrUnsafeFile},
+		{name: "GIF", content: gifImage, want: "image/gif"},
+		{name: "GIFPHP", content: appended(gifImage, "<?php"), err: contracts.ErrUnsafeFile},
+		{name: "WebP", content: webpImage, want: "image/webp"},
+		{name: "WebPZip", content: appended(webpImage, "PK\x05\x06"), err: contracts.ErrUnsafeFile},
+		{name: "NotAllowed", content: []byte("%PDF-1.4"), err: contracts.ErrFileTypeNotAllowed},
+
+		{name: "SVG", want: "image/svg+xml", content: []byte(`<?xml version="1.0"?>` + svgHeader +
+			`<defs><linearGradient id="g"><stop offset="0" stop-color="red"/></linearGradient></defs>` +
+			`<style>.a { fill: url(#g) }</style><rect class="a" width="1" height="1" fill="url(#g)"/>` +
+			`<use xlink:href="#g"/><text x="0" y="1">javascript: is text</text></svg>`)},
+		{name: "SVGEditorMetadata", want: "image/svg+xml", content: []byte(
+			`<svg xmlns="http://www.w3.org/2000/svg" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" ` +
+				`inkscape:version="1.0"><inkscape:grid type="xygrid"/><rect width="1" height="1"/></svg>`)},
+		{name: "SVGScript", content: []byte(svgHeader + `<script>alert(1)</script></svg>`), err: contracts.ErrUnsafeFile},
+		{name: "SVGHandler", content: []byte(svgHeader + `<rect onclick="alert(1)"/></svg>`), err: contracts.ErrUnsafeFile},
+		{name: "SVGEntityLink", content: []byte(svgHeader + `<a href="javascript&#58;alert(1)"><rect/></a></svg>`),
+			err: contracts.ErrUnsafeFile},
+		{name: "SVGSet", content: []byte(svgHeader +
+			`<set attributeName="href" to="javascript&#x3a;alert(1)"/></svg>`), err: contracts.ErrUnsafeFile},
+		{name: "SVGExternalUse", content: []byte(svgHeader + `<use xlink:href="https://example.com/a.svg#x"/></svg>`),
+			err: contracts.ErrUnsafeFile},
+		{name: "SVGForeignObject", content: []byte(svgHeader +
+			`<foreignObject><body xmlns="http://www.w3.org/1999/xhtml"/></foreignObject></svg>`), err: contracts.ErrUnsafeFile},
+		{name: "SVGHTMLNamespace", content: []byte(svgHeader +
+			`<metadata><h:script xmlns:h="http://www.w3.org/1999/xhtml">alert(1)</h:script></metadata></svg>`),
+			err: contracts.ErrUnsafeFile},
+		{name: "SVGDoctype", content: []byte(`<!DOCTYPE svg [<!ENTITY x "y">]>` + svgHeader + `</svg>`),
+			err: contracts.ErrUnsafeFile},
+		{name: "SVGStyleImport", content: []byte(svgHeader + `<style>@import url(https://example.com/a.css);</style></svg>`),
+			err: contracts.ErrUnsafeFile},
+		{name: "SVGStyleEscape", content: []byte(svgHeader + `<rect style="fill: \75 rl(https://example.com)"/></svg>`),
+			err: contracts.ErrUnsafeFile},
+		{name: "SVGExternalFill", content: []byte(svgHeader + `<rect fill="url('https://example.com/#a')"/></svg>`),
+			err: contracts.ErrUnsafeFile},
+		{name: "SVGStylesheet", content: []byte(`<?xml-stylesheet href="https://example.com/a.css"?>` + svgHeader + `</svg>`),
+			err: contracts.ErrUnsafeFile},
+		{name: "SVGMalformed", content: []byte(svgHeader + `<rect>`), err: contracts.ErrUnsafeFile},
+	}
+
+	cfg := config.NewConfigService()
+	cfg.SetString("upload.types", "image/jpeg,image/png,image/gif,image/webp,image/svg+xml")
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := utils.ValidateContent(cfg, "image", tc.content)
+			if got != tc.want || err != tc.err {
+				t.Errorf("ValidateContent = %q, %v, want %q, %v", got, err, tc.want, tc.err)
+			}
+		})
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	pngImage := encoded(func(buf *bytes.Buffer, img image.Image) error {
+		return png.Encode(buf, img)
+	})
+	cases := []struct {
+		name    string
+		config  map[string]string
+		field   string
+		content []byte
+		want    string
+		err     error
+	}{
+		{name: "Default", field: "image", content: pngImage, want: "image/png"},
+		{name: "DefaultTypes", field: "image", content: []byte("content"), err: contracts.ErrFileTypeNotAllowed},
+		{name: "FieldTypes", config: map[string]string{"upload.fields.notes.types": "text/plain"},
+			field: "notes", content: []byte("content"), want: "text/plain"},
+		{name: "OtherFieldTypes", config: map[string]string{"upload.fields.notes.types": "text/plain"},
+			field: "image", content: []byte("content"), err: contracts.ErrFileTypeNotAllowed},
+		{name: "MaxSize", config: map[string]string{"upload.max_size": "10"},
+			field: "image", content: pngImage, err: contracts.ErrFileTooLarge},
+		{name: "FieldMaxSize", config: map[string]string{"upload.max_size": "10", "upload.fields.image.max_size": "100000"},
+			field: "image", content: pngImage, want: "image/png"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewConfigService()
+			for key, value := range tc.config {
+				cfg.SetString(key, value)
+			}
+			got, err := utils.ValidateContent(cfg, tc.field, tc.content)
+			if got != tc.want || err != tc.err {
+				t.Errorf("ValidateContent = %q, %v, want %q, %v", got, err, tc.want, tc.err)
+			}
+		})
+	}
+}
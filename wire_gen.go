@@ -6,26 +6,55 @@
 package leviathan
 
 import (
+	"context"
+	"database/sql"
 	"github.com/mostafasolati/leviathan/auth"
 	"github.com/mostafasolati/leviathan/config"
 	"github.com/mostafasolati/leviathan/contracts"
+	"github.com/mostafasolati/leviathan/database"
+	"github.com/mostafasolati/leviathan/health"
+	"github.com/mostafasolati/leviathan/images"
 	"github.com/mostafasolati/leviathan/logger"
+	"github.com/mostafasolati/leviathan/models"
 	"github.com/mostafasolati/leviathan/notification"
+	"github.com/mostafasolati/leviathan/openapi"
+	"github.com/mostafasolati/leviathan/pubsub"
+	"github.com/mostafasolati/leviathan/ratelimit"
 	"github.com/mostafasolati/leviathan/server"
+	"github.com/mostafasolati/leviathan/storage"
 	"github.com/mostafasolati/leviathan/user"
+	"net/http"
+	"sync"
 )
 
 // Injectors from main.go:
 
-func Init() contracts.ILeviathan {
+func Init() (contracts.ILeviathan, error) {
 	iConfigService := config.NewConfigService()
 	iLogger := logger.NewLogger(iConfigService)
-	iServerContainer := services.NewEchoServerContainer(iConfigService, iLogger)
-	iUserService := user.NewUserService()
-	iNotificationService := notification.NewKavenegar(iConfigService)
-	iAuth := auth.NewAuthService(iConfigService, iLogger, iUserService, iNotificationService)
-	iLeviathan := NewLeviathan(iConfigService, iLogger, iServerContainer, iUserService, iAuth)
-	return iLeviathan
+	db, err := database.NewDatabase(iConfigService)
+	if err != nil {
+		return nil, err
+	}
+	iRateLimitStore := ratelimit.NewStore(iConfigService, db)
+	iRateLimiter := ratelimit.NewRateLimiter(iConfigService, iLogger, iRateLimitStore)
+	iStorage, err := storage.NewStorage(iConfigService)
+	if err != nil {
+		return nil, err
+	}
+	iServerContainer := services.NewServerContainer(iConfigService, iLogger, iRateLimiter, iStorage)
+	iUserService := user.NewUserService(db)
+	iNotificationService, err := notification.NewNotificationService(iConfigService, iLogger)
+	if err != nil {
+		return nil, err
+	}
+	iotpStore := auth.NewOTPStore(iConfigService, db)
+	iAuth := auth.NewAuthService(iConfigService, iLogger, iUserService, iNotificationService, iotpStore)
+	iHealth := health.NewHealthService(iConfigService)
+	iPubSub := pubsub.NewPubSub(iConfigService, iLogger, db)
+	iThumbnailCache := images.NewThumbnailCache(iConfigService, iStorage)
+	iLeviathan := NewLeviathan(iConfigService, iLogger, iServerContainer, iUserService, iAuth, db, iHealth, iotpStore, iNotificationService, iRateLimiter, iPubSub, iStorage, iThumbnailCache)
+	return iLeviathan, nil
 }
 
 // main.go:
@@ -36,6 +65,17 @@ type leviathan struct {
 	serverContainer contracts.IServerContainer
 	user            contracts.IUserService
 	auth            contracts.IAuth
+	db              *sql.DB
+	health          contracts.IHealth
+	rateLimiter     contracts.IRateLimiter
+	pubSub          contracts.IPubSub
+	storage         contracts.IStorage
+	thumbnails      contracts.IThumbnailCache
+
+	hooksMu    sync.Mutex
+	startHooks []contracts.Hook
+	stopHooks  []stopHook
+	started    int
 }
 
 func NewLeviathan(config2 contracts.IConfigService, logger2 contracts.ILogger,
@@ -43,13 +83,56 @@ func NewLeviathan(config2 contracts.IConfigService, logger2 contracts.ILogger,
 	serverContainer contracts.IServerContainer,
 	userService contracts.IUserService, auth2 contracts.IAuth,
 
+	db *sql.DB,
+	healthService contracts.IHealth,
+	otpStore contracts.IOTPStore, notification2 contracts.INotificationService,
+
+	rateLimiter contracts.IRateLimiter,
+	pubSub contracts.IPubSub,
+	fileStorage contracts.IStorage,
+	thumbnails contracts.IThumbnailCache,
 ) contracts.ILeviathan {
-	return &leviathan{
-		config: config2,
-		logger: logger2,
-		user:   userService,
-		auth:   auth2,
+	lev := &leviathan{
+		config:          config2,
+		logger:          logger2,
+		serverContainer: serverContainer,
+		user:            userService,
+		auth:            auth2,
+		db:              db,
+		health:          healthService,
+		rateLimiter:     rateLimiter,
+		pubSub:          pubSub,
+		storage:         fileStorage,
+		thumbnails:      thumbnails,
+	}
+
+	healthService.AddCheck("database", db.PingContext)
+	subsystems := map[string]interface{}{
+		"config":    config2,
+		"otp_store": otpStore,
+		"sms":       notification2,
+	}
+	for name, subsystem := range subsystems {
+		if checker, ok := subsystem.(contracts.IHealthChecker); ok {
+			healthService.AddCheck(name, checker.HealthCheck)
+		}
 	}
+
+	serverContainer.Route(http.MethodGet, "/healthz", healthService.Liveness, contracts.Named("healthz"), contracts.WithTags("health"), contracts.WithoutGlobalRateLimit(), contracts.WithResponse(http.StatusOK, &models.Health{}))
+	serverContainer.Route(http.MethodGet, "/readyz", healthService.Readiness, contracts.Named("readyz"), contracts.WithTags("health"), contracts.WithoutGlobalRateLimit(), contracts.WithResponse(http.StatusOK, &models.Health{}), contracts.WithResponse(http.StatusServiceUnavailable, &models.Health{}))
+	serverContainer.Route(http.MethodGet, "/version", healthService.Version, contracts.Named("version"), contracts.WithTags("health"), contracts.WithResponse(http.StatusOK, &models.BuildInfo{}))
+	openapi.Register(config2, serverContainer)
+	images.Register(config2, serverContainer, thumbnails)
+	storage.Register(config2, serverContainer)
+
+	lev.OnStop(func(ctx context.Context) error {
+		return db.Close()
+	})
+	lev.OnStop(func(ctx context.Context) error {
+		return pubSub.Close()
+	})
+
+	return lev
 }
 
 func (s *leviathan) Auth() contracts.IAuth {
@@ -68,9 +151,33 @@ func (s *leviathan) Config() contracts.IConfigService {
 	return s.config
 }
 
+func (s *leviathan) DB() *sql.DB {
+	return s.db
+}
+
+func (s *leviathan) Health() contracts.IHealth {
+	return s.health
+}
+
+func (s *leviathan) RateLimiter() contracts.IRateLimiter {
+	return s.rateLimiter
+}
+
+func (s *leviathan) PubSub() contracts.IPubSub {
+	return s.pubSub
+}
+
+func (s *leviathan) Storage() contracts.IStorage {
+	return s.storage
+}
+
+func (s *leviathan) Thumbnails() contracts.IThumbnailCache {
+	return s.thumbnails
+}
+
 func (s *leviathan) Server() contracts.IServerContainer {
 	if s.serverContainer == nil {
-		s.serverContainer = services.NewEchoServerContainer(s.config, s.logger)
+		s.serverContainer = services.NewServerContainer(s.config, s.logger, s.rateLimiter, s.storage)
 	}
 	return s.serverContainer
 }